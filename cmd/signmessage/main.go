@@ -0,0 +1,129 @@
+//signmessage proves ownership of an address by signing a message with its
+//key, or checks such a proof.
+//
+//	signmessage sign -secret <hex> -message <text> [-uncompressed] [-network <name>] [-bip322] [-taproot]
+//	signmessage verify -address <address> -message <text> -signature <base64>
+//	signmessage verify -script <hex> -message <text> -signature <base64>
+//
+//Without -bip322 the legacy BIP137 format for p2pkh addresses is used,
+//with it a BIP322 simple signature for the key's p2wpkh output, or with
+//-taproot for the key path of its p2tr output. A segwit -address is
+//verified as BIP322, the same as passing its -script.
+package main
+
+import (
+	"encoding/hex"
+	"flag"
+	"fmt"
+	"math/big"
+	"os"
+
+	"github.com/opakaj/ch12/ecc"
+)
+
+func usage() {
+	fmt.Fprintln(os.Stderr, "usage: signmessage sign|verify [flags]")
+	os.Exit(2)
+}
+
+func fail(err error) {
+	fmt.Fprintln(os.Stderr, err)
+	os.Exit(1)
+}
+
+func sign(args []string) {
+	fs := flag.NewFlagSet("sign", flag.ExitOnError)
+	secret := fs.String("secret", "", "private key as hex")
+	message := fs.String("message", "", "message to sign")
+	uncompressed := fs.Bool("uncompressed", false, "sign for the uncompressed p2pkh address")
+	network := fs.String("network", "mainnet", "mainnet, testnet3, testnet4, signet or regtest")
+	bip322 := fs.Bool("bip322", false, "make a BIP322 signature for the p2wpkh output")
+	taproot := fs.Bool("taproot", false, "with -bip322, sign for the p2tr output instead")
+	fs.Parse(args)
+
+	net, err := ecc.NetworkByName(*network)
+	if err != nil {
+		fail(err)
+	}
+	num, ok := new(big.Int).SetString(*secret, 16)
+	if !ok {
+		fail(fmt.Errorf("ValueError: %v", "secret must be hex"))
+	}
+	//NewPrivateKey panics outside 1 to N-1, a bad flag is not a bug
+	if num.Sign() <= 0 || num.Cmp(ecc.N) >= 0 {
+		fail(fmt.Errorf("ValueError: %v", "secret not in range 1 to N-1"))
+	}
+	privateKey := ecc.NewPrivateKey(num)
+	if *bip322 && *taproot {
+		scriptPubkey, signature, err := ecc.SignMessageBIP322Taproot(privateKey, *message)
+		if err != nil {
+			fail(err)
+		}
+		printBIP322(scriptPubkey, signature, net)
+		return
+	}
+	if *bip322 {
+		scriptPubkey, signature := ecc.SignMessageBIP322(privateKey, *message)
+		printBIP322(scriptPubkey, signature, net)
+		return
+	}
+	address, signature := ecc.SignMessage(privateKey, *message, !*uncompressed, net)
+	fmt.Println("address:", address)
+	fmt.Println("signature:", signature)
+}
+
+//printBIP322 shows the segwit address on net a BIP322 signature was made for
+func printBIP322(scriptPubkey []byte, signature string, net *ecc.NetworkParams) {
+	address, err := ecc.SegwitAddress(net, scriptPubkey)
+	if err != nil {
+		fail(err)
+	}
+	fmt.Println("address:", address)
+	fmt.Println("signature:", signature)
+}
+
+func verify(args []string) {
+	fs := flag.NewFlagSet("verify", flag.ExitOnError)
+	address := fs.String("address", "", "p2pkh (BIP137) or segwit (BIP322) address that signed")
+	script := fs.String("script", "", "p2wpkh, p2wsh or p2tr ScriptPubKey as hex that signed (BIP322)")
+	message := fs.String("message", "", "message that was signed")
+	signature := fs.String("signature", "", "base64 signature")
+	fs.Parse(args)
+
+	var ok bool
+	var err error
+	switch {
+	case *script != "":
+		scriptPubkey, decodeErr := hex.DecodeString(*script)
+		if decodeErr != nil {
+			fail(fmt.Errorf("ValueError: script must be hex: %v", decodeErr))
+		}
+		ok, err = ecc.VerifyMessageBIP322(scriptPubkey, *message, *signature)
+	case *address != "":
+		ok, err = ecc.VerifyMessage(*address, *message, *signature)
+	default:
+		usage()
+	}
+	if err != nil {
+		fail(err)
+	}
+	if !ok {
+		fmt.Println("invalid")
+		os.Exit(1)
+	}
+	fmt.Println("valid")
+}
+
+func main() {
+	if len(os.Args) < 2 {
+		usage()
+	}
+	switch os.Args[1] {
+	case "sign":
+		sign(os.Args[2:])
+	case "verify":
+		verify(os.Args[2:])
+	default:
+		usage()
+	}
+}
@@ -0,0 +1,42 @@
+package ecc
+
+import "fmt"
+
+//AddressType is the kind of output script an address pays to
+type AddressType int
+
+const (
+	P2PKH AddressType = iota
+	P2SH
+)
+
+func (t AddressType) String() string {
+	switch t {
+	case P2PKH:
+		return "p2pkh"
+	case P2SH:
+		return "p2sh"
+	}
+	return fmt.Sprintf("AddressType(%d)", int(t))
+}
+
+//decodeAddress parses a Base58Check address into its network, the type
+//of script it pays to and the 20 byte hash in that script
+func decodeAddress(address string) (net *NetworkParams, addressType AddressType, h160 []byte, err error) {
+	payload, err := decodeBase58Checksum(address)
+	if err != nil {
+		return nil, 0, nil, err
+	}
+	if len(payload) != 21 {
+		return nil, 0, nil, fmt.Errorf("SyntaxError: %v", "address payload must be 21 bytes")
+	}
+	for _, candidate := range NETWORKS {
+		switch payload[0] {
+		case candidate.p2pkhPrefix:
+			return candidate, P2PKH, payload[1:], nil
+		case candidate.p2shPrefix:
+			return candidate, P2SH, payload[1:], nil
+		}
+	}
+	return nil, 0, nil, fmt.Errorf("SyntaxError: unknown address version %#02x", payload[0])
+}
@@ -0,0 +1,117 @@
+package ecc
+
+import (
+	"bytes"
+	"encoding/hex"
+	"math/big"
+	"testing"
+)
+
+func TestWIF(t *testing.T) {
+	tests := []struct {
+		secret     int64
+		compressed bool
+		net        *NetworkParams
+		wif        string
+	}{
+		{1, false, MAINNET, "5HpHagT65TZzG1PH3CSu63k8DbpvD8s5ip4nEB3kEsreAnchuDf"},
+		{1, true, MAINNET, "KwDiBf89QgGbjEhKnhXJuH7LrciVrZi3qYjgd9M7rFU73sVHnoWn"},
+	}
+	for _, test := range tests {
+		privateKey := NewPrivateKey(big.NewInt(test.secret))
+		if got := privateKey.wif(test.compressed, test.net); got != test.wif {
+			t.Errorf("wif %s", got)
+		}
+		parsed, compressed, net, err := parseWIF(test.wif)
+		if err != nil || compressed != test.compressed || net != test.net || parsed.secret.Cmp(privateKey.secret) != 0 {
+			t.Errorf("%s parsed %v %v %v", test.wif, compressed, net, err)
+		}
+	}
+	//every network and both compressions round trip, the test networks
+	//share a prefix so their keys come back as TESTNET3
+	privateKey := NewPrivateKey(new(big.Int).Sub(N, big.NewInt(1)))
+	for _, net := range NETWORKS {
+		want := TESTNET3
+		if net == MAINNET {
+			want = MAINNET
+		}
+		for _, compressed := range []bool{true, false} {
+			wif := privateKey.wif(compressed, net)
+			parsed, gotCompressed, gotNet, err := parseWIF(wif)
+			if err != nil || gotCompressed != compressed || gotNet != want || parsed.secret.Cmp(privateKey.secret) != 0 {
+				t.Errorf("%s %v: %v %v", net, compressed, gotNet, err)
+			}
+		}
+	}
+}
+
+func TestWIFErrors(t *testing.T) {
+	good := "KwDiBf89QgGbjEhKnhXJuH7LrciVrZi3qYjgd9M7rFU73sVHnoWn"
+	//changing the last character breaks the checksum
+	badChecksum := good[:len(good)-1] + "o"
+	tests := []string{
+		badChecksum,
+		"",
+		"0" + good[1:],
+		//a valid checksum over a payload of the wrong length
+		encodeBase58Checksum(string(append([]byte{0x80}, make([]byte, 31)...))),
+		//compressed flag other than 1
+		encodeBase58Checksum(string(append(append([]byte{0x80}, intToBytes32(big.NewInt(1))...), 2))),
+		//an address version is not a WIF prefix
+		encodeBase58Checksum(string(append([]byte{0x00}, intToBytes32(big.NewInt(1))...))),
+		//secrets 0 and N
+		encodeBase58Checksum(string(append([]byte{0x80}, make([]byte, 32)...))),
+		encodeBase58Checksum(string(append([]byte{0x80}, intToBytes32(N)...))),
+	}
+	for i, wif := range tests {
+		if _, _, _, err := parseWIF(wif); err == nil {
+			t.Errorf("%d: %s parsed", i, wif)
+		}
+	}
+}
+
+func TestDecodeAddress(t *testing.T) {
+	//the chapter 8 vectors from Programming Bitcoin
+	h160 := mustHex("74d691da1574e6b3c192ecfb52cc8984ee7b6c56")
+	tests := []struct {
+		address     string
+		net         *NetworkParams
+		addressType AddressType
+	}{
+		{"1BenRpVUFK65JFWcQSuHnJKzc4M8ZP8Eqa", MAINNET, P2PKH},
+		{"mrAjisaT4LXL5MzE81sfcDYKU3wqWSvf9q", TESTNET3, P2PKH},
+		{"3CLoMMyuoDQTPRD3XYZtCvgvkadrAdvdXh", MAINNET, P2SH},
+		{"2N3u1R6uwQfuobCqbCgBkpsgBxvr1tZpe7B", TESTNET3, P2SH},
+	}
+	for _, test := range tests {
+		net, addressType, got, err := decodeAddress(test.address)
+		if err != nil || net != test.net || addressType != test.addressType || !bytes.Equal(got, h160) {
+			t.Errorf("%s: %v %v %x %v", test.address, net, addressType, got, err)
+		}
+	}
+	//the generator's addresses, compressed and not
+	point := NewPrivateKey(big.NewInt(1)).point
+	for compressed, want := range map[bool]string{true: "1BgGZ9tcN4rm9KBzDn7KprQz87SZ26SAMH", false: "1EHNa6Q4Jz2uvNExL497mE43ikXhwF6kZm"} {
+		address := point.address(compressed, MAINNET)
+		if address != want {
+			t.Errorf("address %s", address)
+		}
+		_, _, got, err := decodeAddress(address)
+		if err != nil || hex.EncodeToString(got) != hex.EncodeToString([]byte(point.hash160(compressed))) {
+			t.Errorf("%s decoded %x %v", address, got, err)
+		}
+	}
+	bad := []string{
+		//last character changed, the checksum fails
+		"1BenRpVUFK65JFWcQSuHnJKzc4M8ZP8Eqb",
+		"1BenRpVUFK65JFWcQSuHnJKzc4M8ZP8Eq0",
+		"",
+		//a WIF is not an address
+		"KwDiBf89QgGbjEhKnhXJuH7LrciVrZi3qYjgd9M7rFU73sVHnoWn",
+	}
+	for _, address := range bad {
+		if _, _, _, err := decodeAddress(address); err == nil {
+			t.Errorf("%s decoded", address)
+		}
+	}
+}
@@ -0,0 +1,111 @@
+package ecc
+
+import (
+	"fmt"
+	"math/big"
+	"runtime"
+	"sort"
+	"sync"
+)
+
+type batchItem struct {
+	index int
+	point *S256Point
+	z     *big.Int
+	sig   *Signature
+}
+
+//BatchVerifier checks many ECDSA signatures at once, e.g. all the inputs
+//of a block. Signatures are handed to a pool of workers through a bounded
+//queue so add blocks instead of buffering a whole block in memory.
+type BatchVerifier struct {
+	queue  chan batchItem
+	wg     sync.WaitGroup
+	mu     sync.Mutex //guards failed
+	failed []int
+	//addMu guards count and closed and is held while sending so wait can't
+	//close the queue under a concurrent add. It is separate from mu because
+	//the workers need mu to drain the queue.
+	addMu  sync.Mutex
+	count  int
+	closed bool
+}
+
+//NewBatchVerifier starts the workers, workers <= 0 means one per CPU
+//and queueSize is how many signatures may wait for a free worker
+func NewBatchVerifier(workers int, queueSize int) (Bv *BatchVerifier) {
+	if workers <= 0 {
+		workers = runtime.NumCPU()
+	}
+	if queueSize < 0 {
+		queueSize = 0
+	}
+	Bv = new(BatchVerifier)
+	Bv.queue = make(chan batchItem, queueSize)
+	Bv.wg.Add(workers)
+	for i := 0; i < workers; i++ {
+		go Bv.work()
+	}
+	return
+}
+
+func (Bv *BatchVerifier) work() {
+	defer Bv.wg.Done()
+	for item := range Bv.queue {
+		if !item.verify() {
+			Bv.mu.Lock()
+			Bv.failed = append(Bv.failed, item.index)
+			Bv.mu.Unlock()
+		}
+	}
+}
+
+func (item *batchItem) verify() bool {
+	//a missing pubkey or signature counts as a failure, not a crash
+	if item.point == nil || item.z == nil || item.sig == nil || item.sig.r == nil || item.sig.s == nil {
+		return false
+	}
+	return item.point.verify(item.z, item.sig)
+}
+
+//add queues a signature and returns its index in the batch, it is safe to
+//call from several goroutines
+func (Bv *BatchVerifier) add(point *S256Point, z *big.Int, sig *Signature) int {
+	Bv.addMu.Lock()
+	defer Bv.addMu.Unlock()
+	if Bv.closed {
+		panic(fmt.Errorf("RuntimeError: %v", "batch verifier already finished"))
+	}
+	index := Bv.count
+	Bv.count += 1
+	Bv.queue <- batchItem{index, point, z, sig}
+	return index
+}
+
+//wait blocks until every queued signature is checked and returns the
+//indices of the ones that failed in ascending order, empty if all passed
+func (Bv *BatchVerifier) wait() []int {
+	Bv.addMu.Lock()
+	if !Bv.closed {
+		Bv.closed = true
+		close(Bv.queue)
+	}
+	Bv.addMu.Unlock()
+	Bv.wg.Wait()
+	Bv.mu.Lock()
+	defer Bv.mu.Unlock()
+	sort.Ints(Bv.failed)
+	return Bv.failed
+}
+
+//verifyBatch is a convenience for checking a fixed list of signatures
+func verifyBatch(points []*S256Point, zs []*big.Int, sigs []*Signature) []int {
+	if len(points) != len(zs) || len(points) != len(sigs) {
+		panic(fmt.Errorf("ValueError: %v", "points, zs and sigs must have the same length"))
+	}
+	Bv := NewBatchVerifier(0, 2*runtime.NumCPU())
+	for i := range points {
+		Bv.add(points[i], zs[i], sigs[i])
+	}
+	return Bv.wait()
+}
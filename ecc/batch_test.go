@@ -0,0 +1,93 @@
+package ecc
+
+import (
+	"math/big"
+	"reflect"
+	"sync"
+	"testing"
+)
+
+func TestVerifyBatch(t *testing.T) {
+	var points []*S256Point
+	var zs []*big.Int
+	var sigs []*Signature
+	var want []int
+	for i := 0; i < 40; i++ {
+		privateKey := NewPrivateKey(big.NewInt(int64(1000 + i)))
+		z := big.NewInt(int64(5000 + i))
+		sig := privateKey.sign(z, false)
+		switch i % 5 {
+		case 1:
+			//signed a different z
+			z = new(big.Int).Add(z, big.NewInt(1))
+			want = append(want, i)
+		case 3:
+			//someone else's key
+			privateKey = NewPrivateKey(big.NewInt(int64(9000 + i)))
+			want = append(want, i)
+		}
+		points = append(points, privateKey.point)
+		zs = append(zs, z)
+		sigs = append(sigs, sig)
+	}
+	//missing pieces fail instead of crashing a worker
+	points, zs, sigs = append(points, nil), append(zs, big.NewInt(1)), append(sigs, sigs[0])
+	points, zs, sigs = append(points, points[0]), append(zs, zs[0]), append(sigs, nil)
+	want = append(want, 40, 41)
+	if got := verifyBatch(points, zs, sigs); !reflect.DeepEqual(got, want) {
+		t.Fatalf("failed %v, want %v", got, want)
+	}
+	if got := verifyBatch(points[:1], zs[:1], sigs[:1]); len(got) != 0 {
+		t.Fatalf("failed %v", got)
+	}
+}
+
+func TestBatchVerifierConcurrentAdd(t *testing.T) {
+	privateKey := NewPrivateKey(big.NewInt(424242))
+	z := big.NewInt(7)
+	sig := privateKey.sign(z, false)
+	Bv := NewBatchVerifier(4, 1)
+	var wg sync.WaitGroup
+	var mu sync.Mutex
+	var want []int
+	for g := 0; g < 8; g++ {
+		wg.Add(1)
+		go func(g int) {
+			defer wg.Done()
+			for i := 0; i < 10; i++ {
+				if i == g {
+					index := Bv.add(privateKey.point, big.NewInt(8), sig)
+					mu.Lock()
+					want = append(want, index)
+					mu.Unlock()
+				} else {
+					Bv.add(privateKey.point, z, sig)
+				}
+			}
+		}(g)
+	}
+	wg.Wait()
+	got := Bv.wait()
+	if len(got) != len(want) || Bv.count != 80 {
+		t.Fatalf("failed %v, want %v of %d", got, want, Bv.count)
+	}
+	seen := make(map[int]bool)
+	for _, index := range want {
+		seen[index] = true
+	}
+	for _, index := range got {
+		if !seen[index] {
+			t.Fatalf("failed %v, want %v", got, want)
+		}
+	}
+	//wait is idempotent and add afterwards is a programming error
+	if again := Bv.wait(); !reflect.DeepEqual(again, got) {
+		t.Fatal("second wait")
+	}
+	defer func() {
+		if recover() == nil {
+			t.Fatal("add after wait did not panic")
+		}
+	}()
+	Bv.add(privateKey.point, z, sig)
+}
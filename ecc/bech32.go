@@ -0,0 +1,297 @@
+package ecc
+
+import (
+	"crypto/sha256"
+	"fmt"
+	"strings"
+)
+
+//BIP173 bech32 and BIP350 bech32m, the checksummed base32 format of segwit
+//addresses. Version 0 outputs use bech32, version 1 and up use bech32m.
+
+var BECH32CHARSET = "qpzry9x8gf2tvdw0s3jn54khce6mua7l"
+
+//bech32Encoding picks the constant the checksum is xored with
+type bech32Encoding int
+
+const (
+	BECH32 bech32Encoding = iota
+	BECH32M
+)
+
+func (enc bech32Encoding) constant() uint32 {
+	if enc == BECH32M {
+		return 0x2bc830a3
+	}
+	return 1
+}
+
+//Bech32Error is returned for malformed strings, positions holds the
+//indices of the characters that are wrong when they could be located
+type Bech32Error struct {
+	msg       string
+	positions []int
+}
+
+func (e *Bech32Error) Error() string {
+	if len(e.positions) == 0 {
+		return "SyntaxError: " + e.msg
+	}
+	return fmt.Sprintf("SyntaxError: %s, check position(s) %v", e.msg, e.positions)
+}
+
+func bech32Polymod(values []byte) uint32 {
+	generator := [5]uint32{0x3b6a57b2, 0x26508e6d, 0x1ea119fa, 0x3d4233dd, 0x2a1462b3}
+	chk := uint32(1)
+	for _, v := range values {
+		top := chk >> 25
+		chk = (chk&0x1ffffff)<<5 ^ uint32(v)
+		for i := 0; i < 5; i++ {
+			if (top>>uint(i))&1 == 1 {
+				chk ^= generator[i]
+			}
+		}
+	}
+	return chk
+}
+
+//hrpExpand spreads the human readable part over 5 bit values for the checksum
+func hrpExpand(hrp string) []byte {
+	result := make([]byte, 0, 2*len(hrp)+1)
+	for i := 0; i < len(hrp); i++ {
+		result = append(result, hrp[i]>>5)
+	}
+	result = append(result, 0)
+	for i := 0; i < len(hrp); i++ {
+		result = append(result, hrp[i]&31)
+	}
+	return result
+}
+
+func bech32Checksum(hrp string, data []byte, enc bech32Encoding) []byte {
+	values := append(hrpExpand(hrp), data...)
+	polymod := bech32Polymod(append(values, 0, 0, 0, 0, 0, 0)) ^ enc.constant()
+	checksum := make([]byte, 6)
+	for i := 0; i < 6; i++ {
+		checksum[i] = byte(polymod>>uint(5*(5-i))) & 31
+	}
+	return checksum
+}
+
+//bech32Encode joins hrp and the 5 bit values in data into a lowercase string
+func bech32Encode(hrp string, data []byte, enc bech32Encoding) (string, error) {
+	if len(hrp) < 1 || len(hrp)+7+len(data) > 90 {
+		return "", &Bech32Error{msg: "bech32 string would be too long or has no hrp"}
+	}
+	for i := 0; i < len(hrp); i++ {
+		if hrp[i] < 33 || hrp[i] > 126 || (hrp[i] >= 'A' && hrp[i] <= 'Z') {
+			return "", &Bech32Error{msg: "hrp must be lowercase printable ascii"}
+		}
+	}
+	var sb strings.Builder
+	sb.WriteString(hrp)
+	sb.WriteByte('1')
+	for _, v := range append(append([]byte{}, data...), bech32Checksum(hrp, data, enc)...) {
+		if v > 31 {
+			return "", &Bech32Error{msg: "data values must be 5 bits"}
+		}
+		sb.WriteByte(BECH32CHARSET[v])
+	}
+	return sb.String(), nil
+}
+
+//bech32Decode splits s into its hrp and 5 bit data values, without the
+//checksum, and tells which of the two checksums it carries
+func bech32Decode(s string) (string, []byte, bech32Encoding, error) {
+	if len(s) > 90 {
+		return "", nil, 0, &Bech32Error{msg: "bech32 string longer than 90 characters"}
+	}
+	if strings.ToLower(s) != s && strings.ToUpper(s) != s {
+		return "", nil, 0, &Bech32Error{msg: "mixed case"}
+	}
+	for i := 0; i < len(s); i++ {
+		if s[i] < 33 || s[i] > 126 {
+			return "", nil, 0, &Bech32Error{"invalid character", []int{i}}
+		}
+	}
+	s = strings.ToLower(s)
+	sep := strings.LastIndexByte(s, '1')
+	if sep < 1 {
+		return "", nil, 0, &Bech32Error{msg: "missing separator or empty hrp"}
+	}
+	if sep+7 > len(s) {
+		return "", nil, 0, &Bech32Error{msg: "checksum too short"}
+	}
+	hrp := s[:sep]
+	data := make([]byte, 0, len(s)-sep-1)
+	for i := sep + 1; i < len(s); i++ {
+		v := strings.IndexByte(BECH32CHARSET, s[i])
+		if v < 0 {
+			return "", nil, 0, &Bech32Error{"invalid data character", []int{i}}
+		}
+		data = append(data, byte(v))
+	}
+	switch bech32Polymod(append(hrpExpand(hrp), data...)) {
+	case BECH32.constant():
+		return hrp, data[:len(data)-6], BECH32, nil
+	case BECH32M.constant():
+		return hrp, data[:len(data)-6], BECH32M, nil
+	}
+	return "", nil, 0, &Bech32Error{"invalid checksum", bech32LocateError(hrp, data, sep)}
+}
+
+//bech32LocateError looks for a single character substitution in the data
+//part that fixes the checksum and returns its position in the string. The
+//checksum can't pin down more errors than that without guessing, so for
+//anything worse nothing is returned.
+func bech32LocateError(hrp string, data []byte, sep int) []int {
+	values := append(hrpExpand(hrp), data...)
+	offset := len(values) - len(data)
+	var found []int
+	for i := offset; i < len(values); i++ {
+		original := values[i]
+		for v := byte(0); v < 32; v++ {
+			if v == original {
+				continue
+			}
+			values[i] = v
+			polymod := bech32Polymod(values)
+			if polymod == BECH32.constant() || polymod == BECH32M.constant() {
+				found = append(found, sep+1+i-offset)
+				break
+			}
+		}
+		values[i] = original
+	}
+	if len(found) != 1 {
+		return nil
+	}
+	return found
+}
+
+//convertBits regroups a stream of fromBits wide values into toBits wide ones
+func convertBits(data []byte, fromBits, toBits uint, pad bool) ([]byte, error) {
+	acc, bits := uint32(0), uint(0)
+	maxv := uint32(1)<<toBits - 1
+	var result []byte
+	for _, v := range data {
+		if uint32(v)>>fromBits != 0 {
+			return nil, &Bech32Error{msg: "value out of range for the bit width"}
+		}
+		acc = acc<<fromBits | uint32(v)
+		bits += fromBits
+		for bits >= toBits {
+			bits -= toBits
+			result = append(result, byte(acc>>bits&maxv))
+		}
+	}
+	if pad {
+		if bits > 0 {
+			result = append(result, byte(acc<<(toBits-bits)&maxv))
+		}
+	} else if bits >= fromBits || acc<<(toBits-bits)&maxv != 0 {
+		return nil, &Bech32Error{msg: "invalid padding"}
+	}
+	return result, nil
+}
+
+func checkWitnessProgram(version byte, program []byte) error {
+	if version > 16 {
+		return &Bech32Error{msg: "witness version above 16"}
+	}
+	if len(program) < 2 || len(program) > 40 {
+		return &Bech32Error{msg: "witness program must be 2 to 40 bytes"}
+	}
+	if version == 0 && len(program) != 20 && len(program) != 32 {
+		return &Bech32Error{msg: "version 0 witness program must be 20 or 32 bytes"}
+	}
+	return nil
+}
+
+//encodeSegwitAddress makes the address for a witness program, hrp is the
+//bech32Hrp of the network
+func encodeSegwitAddress(hrp string, version byte, program []byte) (string, error) {
+	if err := checkWitnessProgram(version, program); err != nil {
+		return "", err
+	}
+	enc := BECH32
+	if version > 0 {
+		enc = BECH32M
+	}
+	data, _ := convertBits(program, 8, 5, true)
+	return bech32Encode(hrp, append([]byte{version}, data...), enc)
+}
+
+//decodeSegwitAddress returns the witness version and program of address,
+//which has to belong to hrp and use the checksum its version calls for
+func decodeSegwitAddress(hrp string, address string) (byte, []byte, error) {
+	gotHrp, data, enc, err := bech32Decode(address)
+	if err != nil {
+		return 0, nil, err
+	}
+	if gotHrp != hrp {
+		return 0, nil, &Bech32Error{msg: fmt.Sprintf("expected hrp %q, got %q", hrp, gotHrp)}
+	}
+	if len(data) < 1 {
+		return 0, nil, &Bech32Error{msg: "missing witness version"}
+	}
+	version := data[0]
+	program, err := convertBits(data[1:], 5, 8, false)
+	if err != nil {
+		return 0, nil, err
+	}
+	if err := checkWitnessProgram(version, program); err != nil {
+		return 0, nil, err
+	}
+	if (version == 0) != (enc == BECH32) {
+		return 0, nil, &Bech32Error{msg: "wrong checksum for the witness version"}
+	}
+	return version, program, nil
+}
+
+//segwitScript is the ScriptPubKey OP_n <program>
+func segwitScript(version byte, program []byte) []byte {
+	op := version
+	if version > 0 {
+		op = 0x50 + version
+	}
+	return append([]byte{op, byte(len(program))}, program...)
+}
+
+//SegwitAddress is the address on net of a segwit ScriptPubKey, the
+//reverse of segwitScript
+func SegwitAddress(net *NetworkParams, scriptPubkey []byte) (string, error) {
+	if len(scriptPubkey) < 4 || int(scriptPubkey[1]) != len(scriptPubkey)-2 {
+		return "", &Bech32Error{msg: "not a segwit ScriptPubKey"}
+	}
+	version := scriptPubkey[0]
+	switch {
+	case version == 0:
+	case version >= 0x51 && version <= 0x60:
+		version -= 0x50
+	default:
+		return "", &Bech32Error{msg: "not a segwit ScriptPubKey"}
+	}
+	return encodeSegwitAddress(net.bech32Hrp, version, scriptPubkey[2:])
+}
+
+//p2wpkhAddress is the version 0 address paying to the compressed key's hash160
+func (sp *S256Point) p2wpkhAddress(net *NetworkParams) (string, error) {
+	return encodeSegwitAddress(net.bech32Hrp, 0, []byte(sp.hash160(true)))
+}
+
+//p2wshAddress is the version 0 address paying to sha256 of the witness script
+func p2wshAddress(net *NetworkParams, witnessScript []byte) (string, error) {
+	h := sha256.Sum256(witnessScript)
+	return encodeSegwitAddress(net.bech32Hrp, 0, h[:])
+}
+
+//p2trAddress is the version 1 address of the BIP341 output key, merkleRoot
+//is nil for a key path only output
+func (sp *S256Point) p2trAddress(net *NetworkParams, merkleRoot []byte) (string, error) {
+	Q, _, err := sp.taprootOutputKey(merkleRoot)
+	if err != nil {
+		return "", err
+	}
+	return encodeSegwitAddress(net.bech32Hrp, 1, Q.xonly())
+}
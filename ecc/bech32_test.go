@@ -0,0 +1,209 @@
+package ecc
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+)
+
+//the valid and invalid strings of BIP173 and BIP350
+
+func TestBech32Valid(t *testing.T) {
+	tests := []struct {
+		s   string
+		enc bech32Encoding
+	}{
+		{"A12UEL5L", BECH32},
+		{"a12uel5l", BECH32},
+		{"an83characterlonghumanreadablepartthatcontainsthenumber1andtheexcludedcharactersbio1tt5tgs", BECH32},
+		{"abcdef1qpzry9x8gf2tvdw0s3jn54khce6mua7lmqqqxw", BECH32},
+		{"11qqqqqqqqqqqqqqqqqqqqqqqqqqqqqqqqqqqqqqqqqqqqqqqqqqqqqqqqqqqqqqqqqqqqqqqqqqqqqqqqqqc8247j", BECH32},
+		{"split1checkupstagehandshakeupstreamerranterredcaperred2y9e3w", BECH32},
+		{"?1ezyfcl", BECH32},
+		{"A1LQFN3A", BECH32M},
+		{"a1lqfn3a", BECH32M},
+		{"an83characterlonghumanreadablepartthatcontainsthetheexcludedcharactersbioandnumber11sg7hg6", BECH32M},
+		{"abcdef1l7aum6echk45nj3s0wdvt2fg8x9yrzpqzd3ryx", BECH32M},
+		{"11llllllllllllllllllllllllllllllllllllllllllllllllllllllllllllllllllllllllllllllllllludsr8", BECH32M},
+		{"split1checkupstagehandshakeupstreamerranterredcaperredlc445v", BECH32M},
+		{"?1v759aa", BECH32M},
+	}
+	for _, test := range tests {
+		hrp, data, enc, err := bech32Decode(test.s)
+		if err != nil {
+			t.Errorf("%s: %v", test.s, err)
+			continue
+		}
+		if enc != test.enc {
+			t.Errorf("%s: encoding %d, want %d", test.s, enc, test.enc)
+		}
+		encoded, err := bech32Encode(hrp, data, enc)
+		if err != nil || encoded != strings.ToLower(test.s) {
+			t.Errorf("%s: encoded back to %s, %v", test.s, encoded, err)
+		}
+		//flipping the first data character breaks the checksum
+		sep := strings.LastIndexByte(test.s, '1')
+		flipped := []byte(strings.ToLower(test.s))
+		flipped[sep+1] = BECH32CHARSET[(strings.IndexByte(BECH32CHARSET, flipped[sep+1])+1)%32]
+		if _, _, _, err := bech32Decode(string(flipped)); err == nil {
+			t.Errorf("%s: accepted with a changed character", flipped)
+		}
+	}
+}
+
+func TestBech32Invalid(t *testing.T) {
+	for _, s := range []string{
+		//BIP173
+		"\x201nwldj5",
+		"\x7f1axkwrx",
+		"\x801eym55h",
+		"an84characterslonghumanreadablepartthatcontainsthenumber1andtheexcludedcharactersbio1569pvx",
+		"pzry9x0s0muk",
+		"1pzry9x0s0muk",
+		"x1b4n0q5v",
+		"li1dgmt3",
+		"de1lg7wt\xff",
+		"A1G7SGD8",
+		"10a06t8",
+		"1qzzfhee",
+		//BIP350
+		"\x201xj0phk",
+		"\x7f1g6xzxy",
+		"\x801vctc34",
+		"an84characterslonghumanreadablepartthatcontainsthetheexcludedcharactersbioandnumber11d6pts4",
+		"qyrz8wqd2c9m",
+		"1qyrz8wqd2c9m",
+		"y1b0jsk6g",
+		"lt1igcx5c0",
+		"in1muywd",
+		"mm1crxm3i",
+		"au1s5cgom",
+		"M1VUXWEZ",
+		"16plkw9",
+		"1p2gdwpf",
+	} {
+		if _, _, _, err := bech32Decode(s); err == nil {
+			t.Errorf("%q accepted", s)
+		}
+	}
+}
+
+func TestSegwitAddressValid(t *testing.T) {
+	tests := []struct {
+		address      string
+		scriptPubkey string
+	}{
+		{"BC1QW508D6QEJXTDG4Y5R3ZARVARY0C5XW7KV8F3T4", "0014751e76e8199196d454941c45d1b3a323f1433bd6"},
+		{"tb1qrp33g0q5c5txsp9arysrx4k6zdkfs4nce4xj0gdcccefvpysxf3q0sl5k7", "00201863143c14c5166804bd19203356da136c985678cd4d27a1b8c6329604903262"},
+		{"bc1pw508d6qejxtdg4y5r3zarvary0c5xw7kw508d6qejxtdg4y5r3zarvary0c5xw7kt5nd6y", "5128751e76e8199196d454941c45d1b3a323f1433bd6751e76e8199196d454941c45d1b3a323f1433bd6"},
+		{"BC1SW50QGDZ25J", "6002751e"},
+		{"bc1zw508d6qejxtdg4y5r3zarvaryvaxxpcs", "5210751e76e8199196d454941c45d1b3a323"},
+		{"tb1qqqqqp399et2xygdj5xreqhjjvcmzhxw4aywxecjdzew6hylgvsesrxh6hy", "0020000000c4a5cad46221b2a187905e5266362b99d5e91c6ce24d165dab93e86433"},
+		{"tb1pqqqqp399et2xygdj5xreqhjjvcmzhxw4aywxecjdzew6hylgvsesf3hn0c", "5120000000c4a5cad46221b2a187905e5266362b99d5e91c6ce24d165dab93e86433"},
+		{"bc1p0xlxvlhemja6c4dqv22uapctqupfhlxm9h8z3k2e72q4k9hcz7vqzk5jj0", "512079be667ef9dcbbac55a06295ce870b07029bfcdb2dce28d959f2815b16f81798"},
+	}
+	for _, test := range tests {
+		hrp := strings.ToLower(test.address[:2])
+		version, program, err := decodeSegwitAddress(hrp, test.address)
+		if err != nil {
+			t.Errorf("%s: %v", test.address, err)
+			continue
+		}
+		if got := segwitScript(version, program); !bytes.Equal(got, mustHex(test.scriptPubkey)) {
+			t.Errorf("%s: script %x, want %s", test.address, got, test.scriptPubkey)
+		}
+		address, err := encodeSegwitAddress(hrp, version, program)
+		if err != nil || address != strings.ToLower(test.address) {
+			t.Errorf("%s: encoded back to %s, %v", test.address, address, err)
+		}
+		net := MAINNET
+		if hrp == TESTNET3.bech32Hrp {
+			net = TESTNET3
+		}
+		address, err = SegwitAddress(net, mustHex(test.scriptPubkey))
+		if err != nil || address != strings.ToLower(test.address) {
+			t.Errorf("%s: SegwitAddress gave %s, %v", test.address, address, err)
+		}
+	}
+}
+
+//TestSegwitAddressInvalid matches each address against the error it
+//should get, a typo in a vector would fail on the checksum instead
+func TestSegwitAddressInvalid(t *testing.T) {
+	tests := []struct {
+		hrp     string
+		address string
+		wantErr string
+	}{
+		{"bc", "tc1p0xlxvlhemja6c4dqv22uapctqupfhlxm9h8z3k2e72q4k9hcz7vq5zuyut", "expected hrp"},
+		{"tb", "tc1p0xlxvlhemja6c4dqv22uapctqupfhlxm9h8z3k2e72q4k9hcz7vq5zuyut", "expected hrp"},
+		{"bc", "bc1p0xlxvlhemja6c4dqv22uapctqupfhlxm9h8z3k2e72q4k9hcz7vqh2y7hd", "wrong checksum"},
+		{"tb", "tb1z0xlxvlhemja6c4dqv22uapctqupfhlxm9h8z3k2e72q4k9hcz7vqglt7rf", "wrong checksum"},
+		{"bc", "BC1S0XLXVLHEMJA6C4DQV22UAPCTQUPFHLXM9H8Z3K2E72Q4K9HCZ7VQ54WELL", "wrong checksum"},
+		{"bc", "bc1qw508d6qejxtdg4y5r3zarvary0c5xw7kemeawh", "wrong checksum"},
+		{"tb", "tb1q0xlxvlhemja6c4dqv22uapctqupfhlxm9h8z3k2e72q4k9hcz7vq24jc47", "wrong checksum"},
+		{"bc", "bc1p38j9r5y49hruaue7wxjce0updqjuyyx0kh56v8s25huc6995vvpql3jow4", "invalid data character"},
+		{"bc", "BC130XLXVLHEMJA6C4DQV22UAPCTQUPFHLXM9H8Z3K2E72Q4K9HCZ7VQ7ZWS8R", "above 16"},
+		{"bc", "bc1pw5dgrnzv", "2 to 40 bytes"},
+		{"bc", "bc1p0xlxvlhemja6c4dqv22uapctqupfhlxm9h8z3k2e72q4k9hcz7v8n0nx0muaewav253zgeav", "2 to 40 bytes"},
+		{"bc", "BC1QR508D6QEJXTDG4Y5R3ZARVARYV98GJ9P", "20 or 32 bytes"},
+		{"tb", "tb1p0xlxvlhemja6c4dqv22uapctqupfhlxm9h8z3k2e72q4k9hcz7vq47Zagq", "mixed case"},
+		{"bc", "bc1p0xlxvlhemja6c4dqv22uapctqupfhlxm9h8z3k2e72q4k9hcz7v07qwwzcrf", "padding"},
+		{"tb", "tb1p0xlxvlhemja6c4dqv22uapctqupfhlxm9h8z3k2e72q4k9hcz7vpggkg4j", "padding"},
+		{"bc", "bc1gmk9yu", "missing witness version"},
+	}
+	for _, test := range tests {
+		_, _, err := decodeSegwitAddress(test.hrp, test.address)
+		if err == nil || !strings.Contains(err.Error(), test.wantErr) {
+			t.Errorf("%s: error %v, want one about %s", test.address, err, test.wantErr)
+		}
+	}
+	//SegwitAddress only takes OP_n <program> scripts
+	for _, scriptPubkey := range []string{
+		"76a91474d691da1574e6b3c192ecfb52cc8984ee7b6c5688ac",
+		"0015751e76e8199196d454941c45d1b3a323f1433bd6",
+		"0013751e76e8199196d454941c45d1b3a323f1433b",
+		"6102751e",
+		"",
+	} {
+		if address, err := SegwitAddress(MAINNET, mustHex(scriptPubkey)); err == nil {
+			t.Errorf("%s: SegwitAddress gave %s", scriptPubkey, address)
+		}
+	}
+}
+
+//TestSegwitAddressNetworks round trips a program of each kind through
+//every network's hrp and makes sure no other network takes it
+func TestSegwitAddressNetworks(t *testing.T) {
+	wantHrp := map[*NetworkParams]string{MAINNET: "bc", TESTNET3: "tb", TESTNET4: "tb", SIGNET: "tb", REGTEST: "bcrt"}
+	program20, program32 := bytes.Repeat([]byte{0x75}, 20), bytes.Repeat([]byte{0x1e}, 32)
+	for _, net := range NETWORKS {
+		if net.bech32Hrp != wantHrp[net] {
+			t.Errorf("%s: hrp %q, want %q", net.name, net.bech32Hrp, wantHrp[net])
+		}
+		for _, test := range []struct {
+			version byte
+			program []byte
+		}{{0, program20}, {0, program32}, {1, program32}} {
+			address, err := encodeSegwitAddress(net.bech32Hrp, test.version, test.program)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if !strings.HasPrefix(address, net.bech32Hrp+"1") {
+				t.Errorf("%s: address %s", net.name, address)
+			}
+			version, program, err := decodeSegwitAddress(net.bech32Hrp, strings.ToUpper(address))
+			if err != nil || version != test.version || !bytes.Equal(program, test.program) {
+				t.Errorf("%s: %s decoded to %d %x, %v", net.name, address, version, program, err)
+			}
+			for _, other := range NETWORKS {
+				if other.bech32Hrp == net.bech32Hrp {
+					continue
+				}
+				if _, _, err := decodeSegwitAddress(other.bech32Hrp, address); err == nil {
+					t.Errorf("%s address %s accepted for %s", net.name, address, other.name)
+				}
+			}
+		}
+	}
+}
@@ -0,0 +1,229 @@
+package ecc
+
+import (
+	"bytes"
+	"crypto/hmac"
+	"crypto/sha512"
+	"encoding/binary"
+	"fmt"
+	"math/big"
+	"strconv"
+	"strings"
+)
+
+//HARDENEDOFFSET is the first hardened child index, written i' or ih in paths
+const HARDENEDOFFSET = 0x80000000
+
+//version bytes of the Base58Check serialization
+var XPRVVERSION = []byte{0x04, 0x88, 0xad, 0xe4}
+var XPUBVERSION = []byte{0x04, 0x88, 0xb2, 0x1e}
+var TPRVVERSION = []byte{0x04, 0x35, 0x83, 0x94}
+var TPUBVERSION = []byte{0x04, 0x35, 0x87, 0xcf}
+
+//ExtendedKey is a BIP32 node, a key plus the chain code needed to derive
+//its children. privateKey is nil for extended public keys.
+type ExtendedKey struct {
+	privateKey        *PrivateKey
+	point             *S256Point
+	chainCode         []byte
+	depth             byte
+	parentFingerprint []byte
+	childNumber       uint32
+}
+
+//NewMasterKey derives the root extended private key from a 16 to 64 byte seed
+func NewMasterKey(seed []byte) (*ExtendedKey, error) {
+	if len(seed) < 16 || len(seed) > 64 {
+		return nil, fmt.Errorf("ValueError: %v", "seed must be 16 to 64 bytes")
+	}
+	I := hmacSHA512([]byte("Bitcoin seed"), seed)
+	secret := new(big.Int).SetBytes(I[:32])
+	if secret.Sign() == 0 || secret.Cmp(N) >= 0 {
+		return nil, fmt.Errorf("ValueError: %v", "seed gives an invalid master key")
+	}
+	ek := new(ExtendedKey)
+	ek.privateKey = NewPrivateKey(secret)
+	ek.point = ek.privateKey.point
+	ek.chainCode = I[32:]
+	ek.parentFingerprint = make([]byte, 4)
+	return ek, nil
+}
+
+func hmacSHA512(key []byte, data []byte) []byte {
+	mac := hmac.New(sha512.New, key)
+	mac.Write(data)
+	return mac.Sum(nil)
+}
+
+//ser32 is the 4 byte big-endian encoding BIP32 uses for indices
+func ser32(i uint32) []byte {
+	b := make([]byte, 4)
+	binary.BigEndian.PutUint32(b, i)
+	return b
+}
+
+func (ek *ExtendedKey) isPrivate() bool {
+	return ek.privateKey != nil
+}
+
+//fingerprint is the first 4 bytes of the hash160 of the compressed public key
+func (ek *ExtendedKey) fingerprint() []byte {
+	return []byte(ek.point.hash160(true)[:4])
+}
+
+//neuter returns the extended public key, it can derive the same
+//non-hardened children but none of the private keys
+func (ek *ExtendedKey) neuter() *ExtendedKey {
+	return &ExtendedKey{nil, ek.point, ek.chainCode, ek.depth, ek.parentFingerprint, ek.childNumber}
+}
+
+//child derives child number index. Hardened children need the private key.
+//An index whose tweak is out of range returns an error, BIP32 says to
+//move on to the next index in that case.
+func (ek *ExtendedKey) child(index uint32) (*ExtendedKey, error) {
+	if ek.depth == 255 {
+		return nil, fmt.Errorf("ValueError: %v", "maximum depth reached")
+	}
+	var data []byte
+	if index >= HARDENEDOFFSET {
+		if !ek.isPrivate() {
+			return nil, fmt.Errorf("ValueError: %v", "cannot derive a hardened child from a public key")
+		}
+		data = append([]byte{0x00}, intToBytes32(ek.privateKey.secret)...)
+	} else {
+		data = []byte(ek.point.sec(true))
+	}
+	data = append(data, ser32(index)...)
+	I := hmacSHA512(ek.chainCode, data)
+	tweak := new(big.Int).SetBytes(I[:32])
+	if tweak.Cmp(N) >= 0 {
+		return nil, fmt.Errorf("ValueError: child %d is invalid, use the next index", index)
+	}
+	child := new(ExtendedKey)
+	if ek.isPrivate() {
+		privateKey, err := ek.privateKey.tweakAdd(tweak)
+		if err != nil {
+			return nil, fmt.Errorf("ValueError: child %d is invalid, use the next index", index)
+		}
+		child.privateKey = privateKey
+		child.point = privateKey.point
+	} else {
+		point, err := ek.point.tweakAdd(tweak)
+		if err != nil {
+			return nil, fmt.Errorf("ValueError: child %d is invalid, use the next index", index)
+		}
+		child.point = point
+	}
+	child.chainCode = I[32:]
+	child.depth = ek.depth + 1
+	child.parentFingerprint = ek.fingerprint()
+	child.childNumber = index
+	return child, nil
+}
+
+//parsePath turns "m/84'/0'/0'/0/5" into child indices, a trailing ' or h
+//marks a hardened index
+func parsePath(path string) ([]uint32, error) {
+	parts := strings.Split(path, "/")
+	if parts[0] != "m" && parts[0] != "M" {
+		return nil, fmt.Errorf("SyntaxError: %v", "path must start with m")
+	}
+	var indices []uint32
+	for _, part := range parts[1:] {
+		offset := uint32(0)
+		if strings.HasSuffix(part, "'") || strings.HasSuffix(part, "h") || strings.HasSuffix(part, "H") {
+			offset = HARDENEDOFFSET
+			part = part[:len(part)-1]
+		}
+		index, err := strconv.ParseUint(part, 10, 32)
+		if err != nil || index >= HARDENEDOFFSET {
+			return nil, fmt.Errorf("SyntaxError: invalid path element %q", part)
+		}
+		indices = append(indices, uint32(index)+offset)
+	}
+	return indices, nil
+}
+
+//derivePath follows path from this key, which is taken to be m
+func (ek *ExtendedKey) derivePath(path string) (*ExtendedKey, error) {
+	indices, err := parsePath(path)
+	if err != nil {
+		return nil, err
+	}
+	key := ek
+	for _, index := range indices {
+		key, err = key.child(index)
+		if err != nil {
+			return nil, err
+		}
+	}
+	return key, nil
+}
+
+//serialize returns the Base58Check xprv/xpub, or tprv/tpub off mainnet
+func (ek *ExtendedKey) serialize(net *NetworkParams) string {
+	var version, key []byte
+	if ek.isPrivate() {
+		version = net.xprvVersion
+		key = append([]byte{0x00}, intToBytes32(ek.privateKey.secret)...)
+	} else {
+		version = net.xpubVersion
+		key = []byte(ek.point.sec(true))
+	}
+	result := append([]byte{}, version...)
+	result = append(result, ek.depth)
+	result = append(result, ek.parentFingerprint...)
+	result = append(result, ser32(ek.childNumber)...)
+	result = append(result, ek.chainCode...)
+	result = append(result, key...)
+	return encodeBase58Checksum(string(result))
+}
+
+//parseExtendedKey reads an extended key serialized for net. Besides the
+//checksum it checks what BIP32 lists as invalid: an unknown version, key
+//data that does not match the version, a key out of range or off the curve,
+//and a master key (depth 0) with a parent fingerprint or child number.
+func parseExtendedKey(s string, net *NetworkParams) (*ExtendedKey, error) {
+	payload, err := decodeBase58Checksum(s)
+	if err != nil {
+		return nil, err
+	}
+	if len(payload) != 78 {
+		return nil, fmt.Errorf("SyntaxError: %v", "extended keys are 78 bytes")
+	}
+	version, key := payload[:4], payload[45:]
+	ek := new(ExtendedKey)
+	ek.depth = payload[4]
+	ek.parentFingerprint = payload[5:9]
+	ek.childNumber = binary.BigEndian.Uint32(payload[9:13])
+	ek.chainCode = payload[13:45]
+	if ek.depth == 0 && !bytes.Equal(ek.parentFingerprint, []byte{0, 0, 0, 0}) {
+		return nil, fmt.Errorf("ValueError: %v", "zero depth with a non-zero parent fingerprint")
+	}
+	if ek.depth == 0 && ek.childNumber != 0 {
+		return nil, fmt.Errorf("ValueError: %v", "zero depth with a non-zero child number")
+	}
+	switch {
+	case bytes.Equal(version, net.xprvVersion):
+		if key[0] != 0x00 {
+			return nil, fmt.Errorf("SyntaxError: %v", "private key data must start with 0x00")
+		}
+		secret := new(big.Int).SetBytes(key[1:])
+		if secret.Sign() == 0 || secret.Cmp(N) >= 0 {
+			return nil, fmt.Errorf("ValueError: %v", "private key not in range 1 to N-1")
+		}
+		ek.privateKey = NewPrivateKey(secret)
+		ek.point = ek.privateKey.point
+	case bytes.Equal(version, net.xpubVersion):
+		if key[0] != 0x02 && key[0] != 0x03 {
+			return nil, fmt.Errorf("SyntaxError: %v", "public key data must start with 0x02 or 0x03")
+		}
+		ek.point, err = parseSec(key)
+		if err != nil {
+			return nil, err
+		}
+	default:
+		return nil, fmt.Errorf("ValueError: unknown extended key version %x", version)
+	}
+	return ek, nil
+}
@@ -0,0 +1,94 @@
+package ecc
+
+import (
+	"crypto/sha256"
+	"crypto/sha512"
+	_ "embed"
+	"fmt"
+	"math/big"
+	"strings"
+
+	"golang.org/x/crypto/pbkdf2"
+	"golang.org/x/text/unicode/norm"
+)
+
+//BIP39 mnemonics encode 128 to 256 bits of entropy plus a checksum of
+//entropy bits / 32 as words of 11 bits each, 12 to 24 words in total.
+
+//go:embed bip39_english.txt
+var englishWordlist string
+
+var BIP39WORDS = strings.Fields(englishWordlist)
+
+var bip39Index = func() map[string]int {
+	index := make(map[string]int, len(BIP39WORDS))
+	for i, word := range BIP39WORDS {
+		index[word] = i
+	}
+	return index
+}()
+
+//entropyToMnemonic turns 16, 20, 24, 28 or 32 bytes of entropy into words
+func entropyToMnemonic(entropy []byte) (string, error) {
+	if len(entropy) < 16 || len(entropy) > 32 || len(entropy)%4 != 0 {
+		return "", fmt.Errorf("ValueError: %v", "entropy must be 16 to 32 bytes and a multiple of 4")
+	}
+	checksumBits := len(entropy) / 4
+	checksum := sha256.Sum256(entropy)
+	//entropy || first checksumBits of the checksum, as one big number
+	num := new(big.Int).SetBytes(entropy)
+	num.Lsh(num, uint(checksumBits))
+	num.Or(num, big.NewInt(int64(checksum[0]>>(8-checksumBits))))
+	count := (len(entropy)*8 + checksumBits) / 11
+	words := make([]string, count)
+	mask := big.NewInt(2047)
+	for i := count - 1; i >= 0; i-- {
+		words[i] = BIP39WORDS[new(big.Int).And(num, mask).Int64()]
+		num.Rsh(num, 11)
+	}
+	return strings.Join(words, " "), nil
+}
+
+//mnemonicToEntropy is the reverse of entropyToMnemonic, it fails on
+//unknown words, a wrong word count or a bad checksum
+func mnemonicToEntropy(mnemonic string) ([]byte, error) {
+	words := strings.Fields(mnemonic)
+	if len(words) < 12 || len(words) > 24 || len(words)%3 != 0 {
+		return nil, fmt.Errorf("ValueError: %v", "mnemonic must have 12, 15, 18, 21 or 24 words")
+	}
+	num := new(big.Int)
+	for _, word := range words {
+		i, ok := bip39Index[word]
+		if !ok {
+			return nil, fmt.Errorf("ValueError: %q is not in the wordlist", word)
+		}
+		num.Lsh(num, 11)
+		num.Or(num, big.NewInt(int64(i)))
+	}
+	checksumBits := len(words) / 3
+	checksum := new(big.Int).And(num, big.NewInt(int64(1)<<checksumBits-1))
+	num.Rsh(num, uint(checksumBits))
+	entropy := make([]byte, checksumBits*4)
+	num.FillBytes(entropy)
+	hash := sha256.Sum256(entropy)
+	if checksum.Int64() != int64(hash[0]>>(8-checksumBits)) {
+		return nil, fmt.Errorf("ValueError: %v", "mnemonic checksum does not match")
+	}
+	return entropy, nil
+}
+
+//mnemonicToSeed derives the 64 byte seed with PBKDF2-HMAC-SHA512, both
+//strings are NFKD normalized first as BIP39 asks
+func mnemonicToSeed(mnemonic string, passphrase string) []byte {
+	salt := norm.NFKD.String("mnemonic" + passphrase)
+	return pbkdf2.Key([]byte(norm.NFKD.String(mnemonic)), []byte(salt), 2048, 64, sha512.New)
+}
+
+//NewMasterKeyFromMnemonic checks the mnemonic and returns its BIP32 master key
+func NewMasterKeyFromMnemonic(mnemonic string, passphrase string) (*ExtendedKey, error) {
+	if _, err := mnemonicToEntropy(mnemonic); err != nil {
+		return nil, err
+	}
+	mnemonic = strings.Join(strings.Fields(mnemonic), " ")
+	return NewMasterKey(mnemonicToSeed(mnemonic, passphrase))
+}
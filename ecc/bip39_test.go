@@ -0,0 +1,129 @@
+package ecc
+
+import (
+	"bytes"
+	"encoding/hex"
+	"strings"
+	"testing"
+)
+
+//TestBIP39Vectors runs English rows of the Trezor reference vectors,
+//every seed uses the passphrase "TREZOR"
+func TestBIP39Vectors(t *testing.T) {
+	tests := []struct {
+		entropy  string
+		mnemonic string
+		seed     string
+		xprv     string
+	}{
+		{
+			"00000000000000000000000000000000",
+			"abandon abandon abandon abandon abandon abandon abandon abandon abandon abandon abandon about",
+			"c55257c360c07c72029aebc1b53c05ed0362ada38ead3e3e9efa3708e53495531f09a6987599d18264c1e1c92f2cf141630c7a3c4ab7c81b2f001698e7463b04",
+			"xprv9s21ZrQH143K3h3fDYiay8mocZ3afhfULfb5GX8kCBdno77K4HiA15Tg23wpbeF1pLfs1c5SPmYHrEpTuuRhxMwvKDwqdKiGJS9XFKzUsAF",
+		},
+		{
+			"7f7f7f7f7f7f7f7f7f7f7f7f7f7f7f7f",
+			"legal winner thank year wave sausage worth useful legal winner thank yellow",
+			"2e8905819b8723fe2c1d161860e5ee1830318dbf49a83bd451cfb8440c28bd6fa457fe1296106559a3c80937a1c1069be3a3a5bd381ee6260e8d9739fce1f607",
+			"xprv9s21ZrQH143K2gA81bYFHqU68xz1cX2APaSq5tt6MFSLeXnCKV1RVUJt9FWNTbrrryem4ZckN8k4Ls1H6nwdvDTvnV7zEXs2HgPezuVccsq",
+		},
+		{
+			"80808080808080808080808080808080",
+			"letter advice cage absurd amount doctor acoustic avoid letter advice cage above",
+			"d71de856f81a8acc65e6fc851a38d4d7ec216fd0796d0a6827a3ad6ed5511a30fa280f12eb2e47ed2ac03b5c462a0358d18d69fe4f985ec81778c1b370b652a8",
+			"xprv9s21ZrQH143K2shfP28KM3nr5Ap1SXjz8gc2rAqqMEynmjt6o1qboCDpxckqXavCwdnYds6yBHZGKHv7ef2eTXy461PXUjBFQg6PrwY4Gzq",
+		},
+		{
+			"ffffffffffffffffffffffffffffffff",
+			"zoo zoo zoo zoo zoo zoo zoo zoo zoo zoo zoo wrong",
+			"ac27495480225222079d7be181583751e86f571027b0497b5b5d11218e0a8a13332572917f0f8e5a589620c6f15b11c61dee327651a14c34e18231052e48c069",
+			"xprv9s21ZrQH143K2V4oox4M8Zmhi2Fjx5XK4Lf7GKRvPSgydU3mjZuKGCTg7UPiBUD7ydVPvSLtg9hjp7MQTYsW67rZHAXeccqYqrsx8LcXnyd",
+		},
+		{
+			"000000000000000000000000000000000000000000000000",
+			"abandon abandon abandon abandon abandon abandon abandon abandon abandon abandon abandon abandon abandon abandon abandon abandon abandon agent",
+			"035895f2f481b1b0f01fcf8c289c794660b289981a78f8106447707fdd9666ca06da5a9a565181599b79f53b844d8a71dd9f439c52a3d7b3e8a79c906ac845fa",
+			"xprv9s21ZrQH143K3mEDrypcZ2usWqFgzKB6jBBx9B6GfC7fu26X6hPRzVjzkqkPvDqp6g5eypdk6cyhGnBngbjeHTe4LsuLG1cCmKJka5SMkmU",
+		},
+		{
+			"0000000000000000000000000000000000000000000000000000000000000000",
+			"abandon abandon abandon abandon abandon abandon abandon abandon abandon abandon abandon abandon abandon abandon abandon abandon abandon abandon abandon abandon abandon abandon abandon art",
+			"bda85446c68413707090a52022edd26a1c9462295029f2e60cd7c4f2bbd3097170af7a4d73245cafa9c3cca8d561a7c3de6f5d4a10be8ed2a5e608d68f92fcc8",
+			"xprv9s21ZrQH143K32qBagUJAMU2LsHg3ka7jqMcV98Y7gVeVyNStwYS3U7yVVoDZ4btbRNf4h6ibWpY22iRmXq35qgLs79f312g2kj5539ebPM",
+		},
+		{
+			"9e885d952ad362caeb4efe34a8e91bd2",
+			"ozone drill grab fiber curtain grace pudding thank cruise elder eight picnic",
+			"274ddc525802f7c828d8ef7ddbcdc5304e87ac3535913611fbbfa986d0c9e5476c91689f9c8a54fd55bd38606aa6a8595ad213d4c9c9f9aca3fb217069a41028",
+			"xprv9s21ZrQH143K2oZ9stBYpoaZ2ktHj7jLz7iMqpgg1En8kKFTXJHsjxry1JbKH19YrDTicVwKPehFKTbmaxgVEc5TpHdS1aYhB2s9aFJBeJH",
+		},
+		{
+			"f585c11aec520db57dd353c69554b21a89b20fb0650966fa0a9d6f74fd989d8f",
+			"void come effort suffer camp survey warrior heavy shoot primary clutch crush open amazing screen patrol group space point ten exist slush involve unfold",
+			"01f5bced59dec48e362f2c45b5de68b9fd6c92c6634f44d6d40aab69056506f0e35524a518034ddc1192e1dacd32c1ed3eaa3c3b131c88ed8e7e54c49a5d0998",
+			"xprv9s21ZrQH143K39rnQJknpH1WEPFJrzmAqqasiDcVrNuk926oizzJDDQkdiTvNPr2FYDYzWgiMiC63YmfPAa2oPyNB23r2g7d1yiK6WpqaQS",
+		},
+	}
+	for _, test := range tests {
+		mnemonic, err := entropyToMnemonic(mustHex(test.entropy))
+		if err != nil {
+			t.Fatal(err)
+		}
+		if mnemonic != test.mnemonic {
+			t.Errorf("%s: mnemonic %q, want %q", test.entropy, mnemonic, test.mnemonic)
+		}
+		entropy, err := mnemonicToEntropy(test.mnemonic)
+		if err != nil || !bytes.Equal(entropy, mustHex(test.entropy)) {
+			t.Errorf("%s: entropy %x, %v", test.entropy, entropy, err)
+		}
+		if seed := mnemonicToSeed(test.mnemonic, "TREZOR"); hex.EncodeToString(seed) != test.seed {
+			t.Errorf("%s: seed %x, want %s", test.entropy, seed, test.seed)
+		}
+		master, err := NewMasterKeyFromMnemonic(test.mnemonic, "TREZOR")
+		if err != nil {
+			t.Fatal(err)
+		}
+		if got := master.serialize(MAINNET); got != test.xprv {
+			t.Errorf("%s: xprv %s, want %s", test.entropy, got, test.xprv)
+		}
+	}
+}
+
+func TestMnemonicInvalid(t *testing.T) {
+	for _, mnemonic := range []string{
+		//last word carries a wrong checksum
+		"abandon abandon abandon abandon abandon abandon abandon abandon abandon abandon abandon abandon",
+		//not in the wordlist
+		"abandon abandon abandon abandon abandon abandon abandon abandon abandon abandon abandon abut",
+		//11 words
+		"abandon abandon abandon abandon abandon abandon abandon abandon abandon abandon about",
+		"",
+	} {
+		if _, err := mnemonicToEntropy(mnemonic); err == nil {
+			t.Errorf("%q accepted", mnemonic)
+		}
+	}
+	for _, size := range []int{0, 15, 33, 36} {
+		if _, err := entropyToMnemonic(make([]byte, size)); err == nil {
+			t.Errorf("%d bytes of entropy accepted", size)
+		}
+	}
+}
+
+//TestMnemonicToSeedNFKD is the first Japanese vector of bip32JP, the
+//ideographic spaces and the ㍍ and ガ in the passphrase only match once
+//both strings are NFKD normalized
+func TestMnemonicToSeedNFKD(t *testing.T) {
+	mnemonic := "あいこくしん　あいこくしん　あいこくしん　あいこくしん　あいこくしん　あいこくしん　あいこくしん　あいこくしん　あいこくしん　あいこくしん　あいこくしん　あおぞら"
+	passphrase := "㍍ガバヴァぱばぐゞちぢ十人十色"
+	want := "a262d6fb6122ecf45be09c50492b31f92e9beb7d9a845987a02cefda57a15f9c467a17872029a9e92299b5cbdf306e3a0ee620245cbd508959b6cb7ca637bd55"
+	if got := hex.EncodeToString(mnemonicToSeed(mnemonic, passphrase)); got != want {
+		t.Errorf("seed %s, want %s", got, want)
+	}
+	//the same strings already decomposed give the same seed
+	decomposed := "メートルガバヴァぱばぐゞちぢ十人十色"
+	if got := hex.EncodeToString(mnemonicToSeed(strings.ReplaceAll(mnemonic, "　", " "), decomposed)); got != want {
+		t.Errorf("seed of the decomposed strings %s, want %s", got, want)
+	}
+}
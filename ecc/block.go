@@ -25,28 +25,25 @@ func NewBlock(version int, prevBlock []byte, merkleRoot []byte, timestamp int, b
 	return
 }
 
-func (B *Block) parse(s []byte) *Block {
-	var byt bytes.Buffer
-	byt.Write(s)
-	x, _ := byt.ReadBytes(4)
-	version := littleEndianToInt(x)
-	y, _ := byt.ReadBytes(32)
-	prevBlock := reverse(string(y))
-	z, _ := byt.ReadBytes(32)
-	merkleRoot := reverse(string(z))
-	a, _ := byt.ReadBytes(4)
-	timestamp := littleEndianToInt(a)
-	b, _ := byt.ReadBytes(4)
-	bits := b
-	c, _ := byt.ReadBytes(4)
-	nonce := c
-	return NewBlock(int(version), []byte(prevBlock), []byte(merkleRoot), int(timestamp), bits, nonce)
+//parse reads an 80 byte block header, the hashes are kept byte reversed
+func (B *Block) parse(s *bytes.Reader) (*Block, error) {
+	header, err := readBytes(s, 80)
+	if err != nil {
+		return nil, err
+	}
+	version := littleEndianToInt(header[:4])
+	prevBlock := reverse(string(header[4:36]))
+	merkleRoot := reverse(string(header[36:68]))
+	timestamp := littleEndianToInt(header[68:72])
+	bits := header[72:76]
+	nonce := header[76:80]
+	return NewBlock(int(version), []byte(prevBlock), []byte(merkleRoot), int(timestamp), bits, nonce), nil
 }
 
 func (B *Block) serialize() []byte {
 	result := intToLittleEndian(B.version, 4)
-	result = append(result, B.prevBlock[:]...)
-	result = append(result, B.merkleRoot[:]...)
+	result = append(result, reverse(string(B.prevBlock))...)
+	result = append(result, reverse(string(B.merkleRoot))...)
 	result = append(result, intToLittleEndian(B.timestamp, 4)...)
 	result = append(result, B.bits...)
 	result = append(result, B.nonce...)
@@ -1,46 +1,185 @@
 package ecc
 
+import (
+	"crypto/rand"
+	"encoding/binary"
+	"fmt"
+	"math"
+)
+
+//BloomFilter is a BIP37 filter. The bits are packed into bytes, bit i is
+//bit i%8 (least significant first) of byte i/8, the same layout filterload
+//sends over the wire.
 type BloomFilter struct {
 	size          int
-	bitField      []int
-	functionCount int
-	tweak         int
+	bitField      []byte
+	functionCount uint32
+	tweak         uint32
+	flags         int
 }
 
-var BIP37CONSTANT = 4221880213
+//BIP37CONSTANT spaces out the murmur3 seeds of the hash functions
+const BIP37CONSTANT = 0xfba4c795
+
+//the largest filter BIP37 lets a node load
+const MAXBLOOMFILTERSIZE = 36000
+const MAXHASHFUNCS = 50
+
+//MAXFILTERADDSIZE is the biggest item filteradd takes, the script element limit
+const MAXFILTERADDSIZE = 520
 
-func NewBloomFilter(size int, functionCount int, tweak int) (Bf *BloomFilter) {
+//filterload flags, they say which outpoints Matches adds to the filter
+//when one of the outputs of a transaction matches
+const (
+	BLOOMUPDATENONE         = 0
+	BLOOMUPDATEALL          = 1
+	BLOOMUPDATEP2PUBKEYONLY = 2
+	BLOOMUPDATEMASK         = 3
+)
+
+//NewBloomFilter makes an empty filter of size bytes using functionCount
+//hash functions, tweak varies the hash seeds between filters
+func NewBloomFilter(size int, functionCount int, tweak uint32) (Bf *BloomFilter) {
+	if size < 1 || size > MAXBLOOMFILTERSIZE {
+		panic(fmt.Errorf("ValueError: filter size must be 1 to %d bytes", MAXBLOOMFILTERSIZE))
+	}
+	if functionCount < 1 || functionCount > MAXHASHFUNCS {
+		panic(fmt.Errorf("ValueError: need 1 to %d hash functions", MAXHASHFUNCS))
+	}
 	Bf = new(BloomFilter)
 	Bf.size = size
-	Bf.bitField = func(repeated []int, n int) (result []int) {
-		for i := 0; i < n; i++ {
-			result = append(result, repeated...)
-		}
-		return result
-	}([]int{0}, int(size)*8)
-	Bf.functionCount = functionCount
+	Bf.bitField = make([]byte, size)
+	Bf.functionCount = uint32(functionCount)
 	Bf.tweak = tweak
 	return
 }
 
-func (Bf *BloomFilter) add(item []int) {
-	for i := 0; i < Bf.functionCount; i++ {
-		seed := i*BIP37CONSTANT + Bf.tweak
-		h := murmur3(item, seed)
-		bit := h % (Bf.size * 8)
-		Bf.bitField[bit] = 1
+//NewBloomFilterForRate sizes a filter for elements items so that about
+//fpRate of the items that were not added still match. It uses the same
+//formulas as Bitcoin Core, clamped to the BIP37 limits, and a random tweak.
+//A lower rate costs bandwidth, a higher one hides more of the wallet.
+//Near the limits the clamping gives a worse rate than asked for, check it
+//with FalsePositiveRate.
+func NewBloomFilterForRate(elements int, fpRate float64) (*BloomFilter, error) {
+	if elements < 1 {
+		return nil, fmt.Errorf("ValueError: %v", "need at least one element")
+	}
+	if fpRate <= 0 || fpRate >= 1 {
+		return nil, fmt.Errorf("ValueError: %v", "false positive rate must be between 0 and 1")
+	}
+	//optimal bits = -n*ln(p)/ln(2)**2, clamped while still a float64 so a
+	//huge filter can not overflow the conversion
+	bits := -1 / (math.Ln2 * math.Ln2) * float64(elements) * math.Log(fpRate)
+	size := int(math.Max(1, math.Min(bits/8, MAXBLOOMFILTERSIZE)))
+	//optimal hash functions = bits/n*ln(2)
+	functionCount := int(math.Max(1, math.Min(float64(size*8/elements)*math.Ln2, MAXHASHFUNCS)))
+	var tweak [4]byte
+	if _, err := rand.Read(tweak[:]); err != nil {
+		return nil, err
 	}
+	return NewBloomFilter(size, functionCount, binary.LittleEndian.Uint32(tweak[:])), nil
 }
 
-func (Bf *BloomFilter) filterload(flag int) *GenericMessage {
-	payload := encodeVarint(Bf.size)
-	payload = append(payload, Bf.filterBytes()...)
-	payload = append(payload, intToLittleEndian(Bf.functionCount, 4)...)
-	payload = append(payload, intToLittleEndian(Bf.tweak, 4)...)
-	payload = append(payload, intToLittleEndian(flag, 1)...)
-	return NewGenericMessage([]byte("filterload"), payload)
+//FalsePositiveRate is the chance an item that was never added matches
+//once elements items are in the filter, (1 - e**(-k*n/m))**k
+func (Bf *BloomFilter) FalsePositiveRate(elements int) float64 {
+	k, m := float64(Bf.functionCount), float64(Bf.size*8)
+	return math.Pow(1-math.Exp(-k*float64(elements)/m), k)
+}
+
+//bitIndex is the bit hash function i sets for item
+func (Bf *BloomFilter) bitIndex(i uint32, item []byte) uint32 {
+	seed := i*BIP37CONSTANT + Bf.tweak
+	return murmur3(item, seed) % uint32(Bf.size*8)
+}
+
+//Add puts item into the filter
+func (Bf *BloomFilter) Add(item []byte) {
+	for i := uint32(0); i < Bf.functionCount; i++ {
+		bit := Bf.bitIndex(i, item)
+		Bf.bitField[bit>>3] |= 1 << (bit & 7)
+	}
+}
+
+//Contains reports whether item may be in the filter, false positives
+//are possible but an added item is always found
+func (Bf *BloomFilter) Contains(item []byte) bool {
+	for i := uint32(0); i < Bf.functionCount; i++ {
+		bit := Bf.bitIndex(i, item)
+		if Bf.bitField[bit>>3]&(1<<(bit&7)) == 0 {
+			return false
+		}
+	}
+	return true
+}
+
+//Matches tests a transaction the way a BIP37 node does: the txid, every
+//data push in the output scripts, then every input outpoint and every data
+//push in the scriptSigs. Matched outputs are added to the filter as
+//outpoints according to the flags, so later spends of them match too.
+func (Bf *BloomFilter) Matches(tx *Tx) bool {
+	txHash := []byte(tx.hash())
+	found := Bf.Contains(txHash)
+	for i, txOut := range tx.txOuts {
+		for _, cmd := range txOut.scriptPubkey.cmds {
+			data, ok := cmd.([]byte)
+			if !ok || len(data) == 0 || !Bf.Contains(data) {
+				continue
+			}
+			found = true
+			switch Bf.flags & BLOOMUPDATEMASK {
+			case BLOOMUPDATEALL:
+				Bf.Add(outpoint(txHash, i))
+			case BLOOMUPDATEP2PUBKEYONLY:
+				if txOut.scriptPubkey.isP2pkScriptPubkey() || txOut.scriptPubkey.isMultisigScriptPubkey() {
+					Bf.Add(outpoint(txHash, i))
+				}
+			}
+			//one hit per output is enough
+			break
+		}
+	}
+	if found {
+		return true
+	}
+	for _, txIn := range tx.txIns {
+		if Bf.Contains(outpoint(txIn.prevTx, int(txIn.prevIndex))) {
+			return true
+		}
+		for _, cmd := range txIn.scriptSig.cmds {
+			if data, ok := cmd.([]byte); ok && len(data) > 0 && Bf.Contains(data) {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+//outpoint is the serialized (hash, index) pair that names a transaction output
+func outpoint(txHash []byte, index int) []byte {
+	result := append([]byte{}, txHash...)
+	return append(result, intToLittleEndian(index, 4)...)
+}
+
+//filterload builds the message for this filter. The flag is kept so that
+//Matches updates our copy the same way the peer updates theirs.
+func (Bf *BloomFilter) filterload(flag int) *FilterLoadMessage {
+	Bf.flags = flag
+	return NewFilterLoadMessage(Bf)
+}
+
+//filteradd adds item to our copy and returns the message that adds it
+//to the copy the peer has. An item the peer would refuse is not added,
+//so the two copies stay the same.
+func (Bf *BloomFilter) filteradd(item []byte) (*FilterAddMessage, error) {
+	message, err := NewFilterAddMessage(item)
+	if err != nil {
+		return nil, err
+	}
+	Bf.Add(item)
+	return message, nil
 }
 
 func (Bf *BloomFilter) filterBytes() []byte {
-	return bitFieldToBytes(Bf.bitField)
+	return Bf.bitField
 }
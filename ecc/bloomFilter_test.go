@@ -0,0 +1,356 @@
+package ecc
+
+import (
+	"bytes"
+	"encoding/hex"
+	"math"
+	"math/big"
+	"testing"
+)
+
+//TestMurmur3 runs the MurmurHash3 vectors of Bitcoin Core's hash_tests
+func TestMurmur3(t *testing.T) {
+	tests := []struct {
+		want uint32
+		seed uint32
+		data string
+	}{
+		{0x00000000, 0x00000000, ""},
+		{0x6a396f08, 0xfba4c795, ""},
+		{0x81f16f39, 0xffffffff, ""},
+		{0x514e28b7, 0x00000000, "00"},
+		{0xea3f0b17, 0xfba4c795, "00"},
+		{0xfd6cf10d, 0x00000000, "ff"},
+		{0x16c6b7ab, 0x00000000, "0011"},
+		{0x8eb51c3d, 0x00000000, "001122"},
+		{0xb4471bf8, 0x00000000, "00112233"},
+		{0xe2301fa8, 0x00000000, "0011223344"},
+		{0xfc2e4a15, 0x00000000, "001122334455"},
+		{0xb074502c, 0x00000000, "00112233445566"},
+		{0x8034d2a0, 0x00000000, "0011223344556677"},
+		{0xb4698def, 0x00000000, "001122334455667788"},
+	}
+	for _, test := range tests {
+		if got := murmur3(mustHex(test.data), test.seed); got != test.want {
+			t.Errorf("murmur3(%q, %#x) = %#08x, want %#08x", test.data, test.seed, got, test.want)
+		}
+	}
+}
+
+//TestBloomFilterSerialize is bloom_create_insert_serialize and its
+//tweaked twin from Bitcoin Core's bloom_tests
+func TestBloomFilterSerialize(t *testing.T) {
+	tests := []struct {
+		tweak uint32
+		want  string
+	}{
+		{0, "03614e9b050000000000000001"},
+		{2147483649, "03ce4299050000000100008001"},
+	}
+	for _, test := range tests {
+		sized, err := NewBloomFilterForRate(3, 0.01)
+		if err != nil {
+			t.Fatal(err)
+		}
+		//the tweak of NewBloomFilterForRate is random, keep its sizing only
+		filter := NewBloomFilter(sized.size, int(sized.functionCount), test.tweak)
+		filter.Add(mustHex("99108ad8ed9bb6274d3980bab5a85c048f0950c8"))
+		if !filter.Contains(mustHex("99108ad8ed9bb6274d3980bab5a85c048f0950c8")) {
+			t.Error("added item does not match")
+		}
+		if filter.Contains(mustHex("19108ad8ed9bb6274d3980bab5a85c048f0950c8")) {
+			t.Error("item one bit off matches")
+		}
+		filter.Add(mustHex("b5a2c786d9ef4658287ced5914b37a1b4aa32eee"))
+		filter.Add(mustHex("b9300670b4c5366e95b2699e8b18bc75e5f729c5"))
+		if got := hex.EncodeToString(filter.filterload(BLOOMUPDATEALL).serialize()); got != test.want {
+			t.Errorf("tweak %d: filterload %s, want %s", test.tweak, got, test.want)
+		}
+	}
+}
+
+//TestBloomFilterForRateClamps checks the sizing stays within the BIP37
+//limits and that FalsePositiveRate owns up to what clamping costs
+func TestBloomFilterForRateClamps(t *testing.T) {
+	tests := []struct {
+		elements      int
+		fpRate        float64
+		size          int
+		functionCount uint32
+	}{
+		//Core's sizing, 3 bytes and 5 functions
+		{3, 0.01, 3, 5},
+		{1000, 0.01, 1198, 6},
+		//a million items at 0.0001 wants 2.4MB
+		{1000000, 0.0001, MAXBLOOMFILTERSIZE, 1},
+		{20000, 0.0001, MAXBLOOMFILTERSIZE, 9},
+		//one item at 1e-30 wants 94 functions
+		{1, 1e-30, 17, MAXHASHFUNCS},
+		//under a bit rounds up to a byte
+		{1, 0.9, 1, 5},
+		{10000000, 0.5, MAXBLOOMFILTERSIZE, 1},
+		//far past what fits in an int before clamping
+		{math.MaxInt32, 1e-300, MAXBLOOMFILTERSIZE, 1},
+	}
+	for _, test := range tests {
+		filter, err := NewBloomFilterForRate(test.elements, test.fpRate)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if filter.size != test.size || len(filter.bitField) != test.size || filter.functionCount != test.functionCount {
+			t.Errorf("%d at %g: %d bytes %d functions, want %d and %d", test.elements, test.fpRate,
+				filter.size, filter.functionCount, test.size, test.functionCount)
+		}
+		rate := filter.FalsePositiveRate(test.elements)
+		if test.size == MAXBLOOMFILTERSIZE && rate <= test.fpRate {
+			t.Errorf("%d at %g: clamped filter claims a rate of %g", test.elements, test.fpRate, rate)
+		}
+		//rounding down costs small filters a lot, so only a sizeable one
+		//has to come close to the rate asked for
+		if test.size > 1000 && test.size < MAXBLOOMFILTERSIZE && rate > test.fpRate*1.5 {
+			t.Errorf("%d at %g: rate %g", test.elements, test.fpRate, rate)
+		}
+	}
+	for _, test := range []struct {
+		elements int
+		fpRate   float64
+	}{{0, 0.01}, {10, 0}, {10, 1}, {10, -0.5}} {
+		if _, err := NewBloomFilterForRate(test.elements, test.fpRate); err == nil {
+			t.Errorf("%d at %g accepted", test.elements, test.fpRate)
+		}
+	}
+}
+
+//TestBloomFilterUpdate follows bloom_match_update_none and
+//bloom_match_update_p2pubkey_only of Bitcoin Core's bloom_tests: a
+//transaction pays a p2pk, a p2pkh and a 1 of 1 multisig output whose keys
+//are in the filter, the flags decide which of the three outpoints get
+//added and so which later spends match
+func TestBloomFilterUpdate(t *testing.T) {
+	p2pkKey := []byte(NewPrivateKey(big.NewInt(1001)).point.sec(true))
+	p2pkhHash := []byte(NewPrivateKey(big.NewInt(1002)).point.hash160(true))
+	multisigKey := []byte(NewPrivateKey(big.NewInt(1003)).point.sec(true))
+	funding := NewTx(1, []*TxIn{NewTxIn(make([]byte, 32), 0, nil, 0xffffffff)}, []*TxOut{
+		NewTxOut(1000, NewScript([]interface{}{p2pkKey, 172})),
+		NewTxOut(1000, p2pkhScript(p2pkhHash)),
+		NewTxOut(1000, NewScript([]interface{}{81, multisigKey, 81, 174})),
+	}, 0, MAINNET)
+	fundingHash := []byte(funding.hash())
+	spend := func(index int) *Tx {
+		return NewTx(1, []*TxIn{NewTxIn(fundingHash, int64(index), nil, 0xffffffff)},
+			[]*TxOut{NewTxOut(900, p2pkhScript(make([]byte, 20)))}, 0, MAINNET)
+	}
+	tests := []struct {
+		flag  int
+		added [3]bool
+	}{
+		{BLOOMUPDATENONE, [3]bool{false, false, false}},
+		{BLOOMUPDATEALL, [3]bool{true, true, true}},
+		{BLOOMUPDATEP2PUBKEYONLY, [3]bool{true, false, true}},
+	}
+	for _, test := range tests {
+		filter := NewBloomFilter(1000, 10, 0)
+		filter.filterload(test.flag)
+		for _, item := range [][]byte{p2pkKey, p2pkhHash, multisigKey} {
+			filter.Add(item)
+		}
+		if !filter.Matches(funding) {
+			t.Fatalf("flag %d: funding tx does not match", test.flag)
+		}
+		for i, added := range test.added {
+			if got := filter.Contains(outpoint(fundingHash, i)); got != added {
+				t.Errorf("flag %d: outpoint %d added %v, want %v", test.flag, i, got, added)
+			}
+			if got := filter.Matches(spend(i)); got != added {
+				t.Errorf("flag %d: spend of output %d matches %v, want %v", test.flag, i, got, added)
+			}
+		}
+		//an unrelated transaction with no hit stays out
+		if filter.Matches(spend(3)) {
+			t.Errorf("flag %d: spend of an output that does not exist matches", test.flag)
+		}
+	}
+}
+
+//TestBloomFilterMatchCore is bloom_match from Bitcoin Core's bloom_tests:
+//tx b4749f01... and e2769b09... which spends its first output, each row
+//loads a fresh filter with one item. The txid, a scriptSig push, an
+//output push and the spent outpoint all match, near misses do not.
+func TestBloomFilterMatchCore(t *testing.T) {
+	tx, err := new(Tx).parse(bytes.NewReader(mustHex("01000000010b26e9b7735eb6aabdf358bab62f9816a21ba9ebdb719d5299e88607d722c190000000008b4830450220070aca44506c5cef3a16ed519d7c3c39f8aab192c4e1c90d065f37b8a4af6141022100a8e160b856c2d43d27d8fba71e5aef6405b8643ac4cb7cb3c462aced7f14711a0141046d11fee51b0e60666d5049a9101a72741df480b96ee26488a4d3466b95c9a40ac5eeef87e10a5cd336c19a84565f80fa6c547957b7700ff4dfbdefe76036c339ffffffff021bff3d11000000001976a91404943fdd508053c75000106d3bc6e2754dbcff1988ac2f15de00000000001976a914a266436d2965547608b9e15d9032a7b9d64fa43188ac00000000")), MAINNET)
+	if err != nil {
+		t.Fatal(err)
+	}
+	spendingTx, err := new(Tx).parse(bytes.NewReader(mustHex("01000000016bff7fcd4f8565ef406dd5d63d4ff94f318fe82027fd4dc451b04474019f74b4000000008c493046022100da0dc6aecefe1e06efdf05773757deb168820930e3b0d03f46f5fcf150bf990c022100d25b5c87040076e4f253f8262e763e2dd51e7ff0be157727c4bc42807f17bd39014104e6c26ef67dc610d2cd192484789a6cf9aea9930b944b7e2db5342b9d9e5b9ff79aff9a2ee1978dd7fd01dfc522ee02283d3b06a9d03acf8096968d7dbb0f9178ffffffff028ba7940e000000001976a914badeecfdef0507247fc8f74241d73bc039972d7b88ac4094a802000000001976a914c10932483fec93ed51f5fe95e72559f2cc7043f988ac00000000")), MAINNET)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if tx.id() != "b4749f017444b051c44dfd2720e88f314ff94f3dd6d56d40ef65854fcd7fff6b" || spendingTx.id() != "e2769b09e784f32f62ef849763d4f45b98e07ba658647343b915ff832b110436" {
+		t.Fatalf("parsed %s and %s", tx.id(), spendingTx.id())
+	}
+	//the outpoint tx spends, its hash byte reversed
+	prevHash := []byte(reverse(string(mustHex("90c122d70786e899529d71dbeba91ba216982fb6ba58f3bdaab65e73b7e9260b"))))
+	tests := []struct {
+		name  string
+		item  []byte
+		match bool
+		//with BLOOM_UPDATE_ALL a matched output is added, so its spend matches
+		spends bool
+	}{
+		{"txid", mustHex("6bff7fcd4f8565ef406dd5d63d4ff94f318fe82027fd4dc451b04474019f74b4"), true, false},
+		{"scriptSig signature", mustHex("30450220070aca44506c5cef3a16ed519d7c3c39f8aab192c4e1c90d065f37b8a4af6141022100a8e160b856c2d43d27d8fba71e5aef6405b8643ac4cb7cb3c462aced7f14711a01"), true, false},
+		{"scriptSig pubkey", mustHex("046d11fee51b0e60666d5049a9101a72741df480b96ee26488a4d3466b95c9a40ac5eeef87e10a5cd336c19a84565f80fa6c547957b7700ff4dfbdefe76036c339"), true, false},
+		{"first output address", mustHex("04943fdd508053c75000106d3bc6e2754dbcff19"), true, true},
+		{"second output address", mustHex("a266436d2965547608b9e15d9032a7b9d64fa431"), true, false},
+		{"spent outpoint", outpoint(prevHash, 0), true, false},
+		{"random txid", []byte(reverse(string(mustHex("00000009e784f32f62ef849763d4f45b98e07ba658647343b915ff832b110436")))), false, false},
+		{"random address", mustHex("0000006d2965547608b9e15d9032a7b9d64fa431"), false, false},
+		{"outpoint not spent", outpoint(prevHash, 1), false, false},
+		{"outpoint of another tx", outpoint([]byte(reverse(string(mustHex("000000d70786e899529d71dbeba91ba216982fb6ba58f3bdaab65e73b7e9260b")))), 0), false, false},
+	}
+	for _, test := range tests {
+		sized, err := NewBloomFilterForRate(10, 0.000001)
+		if err != nil {
+			t.Fatal(err)
+		}
+		filter := NewBloomFilter(sized.size, int(sized.functionCount), 0)
+		filter.filterload(BLOOMUPDATEALL)
+		filter.Add(test.item)
+		if got := filter.Matches(tx); got != test.match {
+			t.Errorf("%s: Matches(tx) = %v, want %v", test.name, got, test.match)
+		}
+		if got := filter.Matches(spendingTx); got != test.spends {
+			t.Errorf("%s: Matches(spendingTx) = %v, want %v", test.name, got, test.spends)
+		}
+	}
+}
+
+//TestFilterMessageRoundTrip serializes and parses filterload and filteradd
+//at sizes around the 0xfd varint prefix
+func TestFilterMessageRoundTrip(t *testing.T) {
+	for _, size := range []int{1, 252, 253, 300, MAXBLOOMFILTERSIZE} {
+		filter := NewBloomFilter(size, 11, 0x01020304)
+		for i := 0; i < 20; i++ {
+			filter.Add([]byte{byte(i)})
+		}
+		payload := filter.filterload(BLOOMUPDATEP2PUBKEYONLY).serialize()
+		if want := len(encodeVarint(size)) + size + 9; len(payload) != want {
+			t.Errorf("%d bytes: payload of %d bytes, want %d", size, len(payload), want)
+		}
+		message, err := new(FilterLoadMessage).parse(payload)
+		if err != nil {
+			t.Fatalf("%d bytes: %v", size, err)
+		}
+		got := message.filter
+		if got.size != size || !bytes.Equal(got.bitField, filter.bitField) || got.functionCount != 11 ||
+			got.tweak != 0x01020304 || got.flags != BLOOMUPDATEP2PUBKEYONLY {
+			t.Errorf("%d bytes: parsed back to %d bytes %d functions tweak %#x flags %d",
+				size, got.size, got.functionCount, got.tweak, got.flags)
+		}
+		if !bytes.Equal(message.serialize(), payload) {
+			t.Errorf("%d bytes: serialized back differently", size)
+		}
+	}
+	for _, size := range []int{0, 252, 253, 300, MAXFILTERADDSIZE} {
+		item := bytes.Repeat([]byte{0x5a}, size)
+		filter := NewBloomFilter(100, 5, 0)
+		message, err := filter.filteradd(item)
+		if err != nil {
+			t.Fatalf("%d bytes: %v", size, err)
+		}
+		if !filter.Contains(item) {
+			t.Errorf("%d bytes: filteradd left the item out of the filter", size)
+		}
+		payload := message.serialize()
+		parsed, err := new(FilterAddMessage).parse(payload)
+		if err != nil {
+			t.Fatalf("%d bytes: %v", size, err)
+		}
+		if !bytes.Equal(parsed.data, item) || !bytes.Equal(parsed.serialize(), payload) {
+			t.Errorf("%d bytes: parsed back to %d bytes", size, len(parsed.data))
+		}
+	}
+	//a 521 byte item is refused on both sides and stays out of our copy
+	item := bytes.Repeat([]byte{0x5a}, MAXFILTERADDSIZE+1)
+	filter := NewBloomFilter(100, 5, 0)
+	if _, err := filter.filteradd(item); err == nil {
+		t.Error("521 byte filteradd accepted")
+	}
+	for _, b := range filter.bitField {
+		if b != 0 {
+			t.Fatal("refused item was added to the filter")
+		}
+	}
+	if _, err := new(FilterAddMessage).parse(varstr(item)); err == nil {
+		t.Error("521 byte filteradd payload parsed")
+	}
+	if _, err := new(FilterLoadMessage).parse(append(varstr(make([]byte, MAXBLOOMFILTERSIZE+1)), make([]byte, 9)...)); err == nil {
+		t.Error("36001 byte filterload parsed")
+	}
+}
+
+//TestFilterMessageEnvelope sends filterload, filteradd and filterclear
+//through a NetworkEnvelope and applies them the way a peer does
+func TestFilterMessageEnvelope(t *testing.T) {
+	//wrap serializes message into an envelope and parses it back
+	wrap := func(message Message) *NetworkEnvelope {
+		raw := NewNetworkEnvelope(message.Command(), message.serialize(), MAINNET).serialize()
+		envelope, err := new(NetworkEnvelope).parse(bytes.NewReader(raw), MAINNET)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return envelope
+	}
+	item := []byte("watched address")
+	added := []byte("new address")
+	filter := NewBloomFilter(100, 5, 99)
+	filter.Add(item)
+
+	//filteradd before any filterload is an error and leaves no filter
+	addMessage, err := NewFilterAddMessage(added)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got, err := applyFilterMessage(nil, wrap(addMessage)); err == nil || got != nil {
+		t.Errorf("filteradd without a filter: %v, %v", got, err)
+	}
+
+	peer, err := applyFilterMessage(nil, wrap(filter.filterload(BLOOMUPDATEALL)))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if peer == nil || !bytes.Equal(peer.bitField, filter.bitField) || peer.functionCount != 5 || peer.tweak != 99 || peer.flags != BLOOMUPDATEALL {
+		t.Fatalf("filterload gave %+v", peer)
+	}
+	if !peer.Contains(item) || peer.Contains(added) {
+		t.Fatal("loaded filter does not match ours")
+	}
+
+	addMessage, err = filter.filteradd(added)
+	if err != nil {
+		t.Fatal(err)
+	}
+	peer, err = applyFilterMessage(peer, wrap(addMessage))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !peer.Contains(added) || !bytes.Equal(peer.bitField, filter.bitField) {
+		t.Error("filteradd did not reach the peer's filter")
+	}
+
+	peer, err = applyFilterMessage(peer, wrap(NewFilterClearMessage()))
+	if err != nil || peer != nil {
+		t.Errorf("filterclear left %v, %v", peer, err)
+	}
+
+	//anything else is not a filter message and changes nothing
+	peer = NewBloomFilter(10, 1, 0)
+	if got, err := applyFilterMessage(peer, wrap(NewPingMessage(make([]byte, 8)))); err == nil || got != peer {
+		t.Errorf("ping: %v, %v", got, err)
+	}
+	//a filterclear with a payload is malformed
+	envelope := NewNetworkEnvelope([]byte("filterclear"), []byte{0}, MAINNET)
+	if got, err := applyFilterMessage(peer, envelope); err == nil || got != peer {
+		t.Errorf("filterclear with a payload: %v, %v", got, err)
+	}
+}
@@ -0,0 +1,109 @@
+package ecc
+
+import (
+	"math/big"
+)
+
+//DERError is returned for signatures that break the BIP66 strict DER rules,
+//compare against the constants below to find out what was wrong
+type DERError string
+
+func (e DERError) Error() string {
+	return "SyntaxError: " + string(e)
+}
+
+const (
+	ErrDERLength         DERError = "signature length out of range"
+	ErrDERCompound       DERError = "signature is not a DER compound"
+	ErrDERTotalLength    DERError = "DER length does not match the signature"
+	ErrDERIntegerMarker  DERError = "expected a DER integer"
+	ErrDERIntegerLength  DERError = "DER integer length does not fit"
+	ErrDERZeroLength     DERError = "DER integer has zero length"
+	ErrDERNegative       DERError = "DER integer is negative"
+	ErrDERPadding        DERError = "DER integer has excessive padding"
+	ErrDERHashTypeLength DERError = "signature is missing the sighash byte"
+)
+
+//checkDEREncoding enforces the BIP66 rules on a bare DER signature:
+//0x30 [total-length] 0x02 [R-length] [R] 0x02 [S-length] [S]
+func checkDEREncoding(sig []byte) error {
+	//the shortest form is 8 bytes and the longest 72 bytes
+	if len(sig) < 8 || len(sig) > 72 {
+		return ErrDERLength
+	}
+	if sig[0] != 0x30 {
+		return ErrDERCompound
+	}
+	if int(sig[1]) != len(sig)-2 {
+		return ErrDERTotalLength
+	}
+	lenR := int(sig[3])
+	//make sure the length of S is still inside the signature
+	if 5+lenR >= len(sig) {
+		return ErrDERIntegerLength
+	}
+	lenS := int(sig[5+lenR])
+	if lenR+lenS+6 != len(sig) {
+		return ErrDERIntegerLength
+	}
+	if err := checkDERInteger(sig[2], sig[4:4+lenR]); err != nil {
+		return err
+	}
+	return checkDERInteger(sig[4+lenR], sig[6+lenR:])
+}
+
+//checkDERInteger checks the marker and that the value is positive and minimally encoded
+func checkDERInteger(marker byte, value []byte) error {
+	if marker != 0x02 {
+		return ErrDERIntegerMarker
+	}
+	if len(value) == 0 {
+		return ErrDERZeroLength
+	}
+	if value[0]&0x80 != 0 {
+		return ErrDERNegative
+	}
+	//a leading zero is only allowed when the next byte has its high bit set
+	if len(value) > 1 && value[0] == 0 && value[1]&0x80 == 0 {
+		return ErrDERPadding
+	}
+	return nil
+}
+
+//checkSignatureEncoding is checkDEREncoding for signatures as they appear
+//in a script, with the sighash type byte on the end
+func checkSignatureEncoding(sig []byte) error {
+	if len(sig) < 1 {
+		return ErrDERHashTypeLength
+	}
+	return checkDEREncoding(sig[:len(sig)-1])
+}
+
+//derInteger is the minimal big-endian encoding of a positive integer
+func derInteger(n *big.Int) []byte {
+	b := n.Bytes()
+	//if b has a high bit, add a \x00 so it does not read as negative
+	if len(b) == 0 || b[0]&0x80 != 0 {
+		b = append([]byte{0}, b...)
+	}
+	return append([]byte{0x02, byte(len(b))}, b...)
+}
+
+//isLowS reports whether s is in the lower half of the order (BIP62/BIP146)
+func (S *Signature) isLowS() bool {
+	return S.s.Cmp(new(big.Int).Rsh(N, 1)) <= 0
+}
+
+//hasLowR reports whether r fits in 32 DER bytes, i.e. needs no padding byte
+func (S *Signature) hasLowR() bool {
+	return S.r.BitLen() < 256
+}
+
+//normalizeS returns the equivalent signature with s = N - s when s is high.
+//(r, s) and (r, N-s) are both valid so only the low one should be relayed.
+func (S *Signature) normalizeS() *Signature {
+	if S.isLowS() {
+		return NewSignature(S.r, S.s)
+	}
+	return NewSignature(S.r, new(big.Int).Sub(N, S.s))
+}
@@ -0,0 +1,114 @@
+package ecc
+
+import (
+	"bytes"
+	"math/big"
+	"testing"
+)
+
+//TestCheckDEREncoding runs BIP66 style valid and invalid signatures, every
+//DERError constant has at least one row
+func TestCheckDEREncoding(t *testing.T) {
+	high := append([]byte{0x00, 0x80}, bytes.Repeat([]byte{0x01}, 31)...)
+	longest := append([]byte{0x30, 0x46, 0x02, 0x21}, high...)
+	longest = append(append(longest, 0x02, 0x21), high...)
+	tests := []struct {
+		name string
+		sig  []byte
+		want error
+	}{
+		{"shortest", mustHex("3006020101020101"), nil},
+		{"high bit r", mustHex("300702020080020101"), nil},
+		{"longest", longest, nil},
+		{"too short", mustHex("30050201010201"), ErrDERLength},
+		{"too long", append(longest, 0x01), ErrDERLength},
+		{"not a compound", mustHex("3106020101020101"), ErrDERCompound},
+		{"total length", mustHex("3007020101020101"), ErrDERTotalLength},
+		{"r past the end", mustHex("3006020501020101"), ErrDERIntegerLength},
+		{"s length", mustHex("3006020201020101"), ErrDERIntegerLength},
+		{"r marker", mustHex("3006030101020101"), ErrDERIntegerMarker},
+		{"s marker", mustHex("3006020101030101"), ErrDERIntegerMarker},
+		{"zero length r", mustHex("3006020002020101"), ErrDERZeroLength},
+		{"zero length s", mustHex("3006020201010200"), ErrDERZeroLength},
+		{"negative r", mustHex("3006020181020101"), ErrDERNegative},
+		{"negative s", mustHex("3006020101020181"), ErrDERNegative},
+		{"padded r", mustHex("300702020001020101"), ErrDERPadding},
+		{"padded s", mustHex("300702010102020001"), ErrDERPadding},
+	}
+	for _, tc := range tests {
+		sig := tc.sig
+		withType := append(append([]byte{}, sig...), byte(SIGHASHALL))
+		if err := checkDEREncoding(sig); err != tc.want {
+			t.Errorf("%s: checkDEREncoding(%x) = %v, want %v", tc.name, sig, err, tc.want)
+		}
+		//the same rules apply with a sighash byte on the end
+		if err := checkSignatureEncoding(withType); err != tc.want {
+			t.Errorf("%s: checkSignatureEncoding(%x) = %v, want %v", tc.name, withType, err, tc.want)
+		}
+		if _, err := new(Signature).parse(sig); err != tc.want {
+			t.Errorf("%s: parse(%x) = %v, want %v", tc.name, sig, err, tc.want)
+		}
+	}
+	if err := checkSignatureEncoding(nil); err != ErrDERHashTypeLength {
+		t.Errorf("checkSignatureEncoding(nil) = %v, want %v", err, ErrDERHashTypeLength)
+	}
+}
+
+func TestDERRoundTrip(t *testing.T) {
+	for i := 0; i < 50; i++ {
+		privateKey := NewPrivateKey(randomScalar(t))
+		z := randomScalar(t)
+		sig := privateKey.sign(z, false)
+		der := []byte(sig.der())
+		if err := checkDEREncoding(der); err != nil {
+			t.Fatalf("der() of %s is not strict DER: %v", sig.Repr(), err)
+		}
+		got, err := new(Signature).parse(der)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if got.r.Cmp(sig.r) != 0 || got.s.Cmp(sig.s) != 0 {
+			t.Fatalf("parse(der()) = %s, want %s", got.Repr(), sig.Repr())
+		}
+	}
+}
+
+func TestLowS(t *testing.T) {
+	half := new(big.Int).Rsh(N, 1)
+	if !NewSignature(big.NewInt(1), half).isLowS() {
+		t.Errorf("s = N/2 is not low")
+	}
+	if NewSignature(big.NewInt(1), new(big.Int).Add(half, big.NewInt(1))).isLowS() {
+		t.Errorf("s = N/2 + 1 is low")
+	}
+	for i := 0; i < 20; i++ {
+		privateKey := NewPrivateKey(randomScalar(t))
+		z := randomScalar(t)
+		sig := privateKey.sign(z, false)
+		if !sig.isLowS() {
+			t.Fatalf("sign gave a high s: %s", sig.Repr())
+		}
+		high := NewSignature(sig.r, new(big.Int).Sub(N, sig.s))
+		if high.isLowS() {
+			t.Fatalf("N - s is low: %s", high.Repr())
+		}
+		//both are valid but only the low one survives normalizeS
+		if !privateKey.point.verify(z, high) {
+			t.Fatalf("high s signature does not verify")
+		}
+		for _, in := range []*Signature{sig, high} {
+			got := in.normalizeS()
+			if got.r.Cmp(sig.r) != 0 || got.s.Cmp(sig.s) != 0 {
+				t.Fatalf("normalizeS(%s) = %s, want %s", in.Repr(), got.Repr(), sig.Repr())
+			}
+		}
+		//checkSig only accepts the low one
+		sec := []byte(privateKey.point.sec(true))
+		if valid, ok := checkSig(z, sec, append([]byte(sig.der()), byte(SIGHASHALL))); !valid || !ok {
+			t.Fatalf("checkSig(low s) = %v, %v", valid, ok)
+		}
+		if _, ok := checkSig(z, sec, append([]byte(high.der()), byte(SIGHASHALL))); ok {
+			t.Fatalf("checkSig accepted a high s")
+		}
+	}
+}
@@ -5,38 +5,37 @@ import (
 	"crypto/hmac"
 	"crypto/sha256"
 	"encoding/binary"
-	"encoding/hex"
-	"errors"
 	"fmt"
-	"math"
 	"math/big"
-	"reflect"
-	"strings"
 )
 
-var A int = 0
-var B int = 7
-var P int = int(math.Pow(float64(2), float64(256)) - math.Pow(float64(2), float64(32)) - 977)
-var N int64 = 0xfffffffffffffffffffffffffffffffebaaedce6af48a03bbfd25e8cd0364141
+var A = big.NewInt(0)
+var B = big.NewInt(7)
+
+//P is the secp256k1 field prime 2**256 - 2**32 - 977
+var P, _ = new(big.Int).SetString("fffffffffffffffffffffffffffffffffffffffffffffffffffffffefffffc2f", 16)
+
+//N is the order of the secp256k1 group generated by G
+var N, _ = new(big.Int).SetString("fffffffffffffffffffffffffffffffebaaedce6af48a03bbfd25e8cd0364141", 16)
 
 type FieldElement struct {
-	num   int
-	prime int
+	num   *big.Int
+	prime *big.Int
 }
 
-func mod(x, y int64) int64 {
-	bx, by := big.NewInt(x), big.NewInt(y)
-	return new(big.Int).Mod(bx, by).Int64()
+//mod always returns a value in the range 0 to y-1, even for negative x
+func mod(x, y *big.Int) *big.Int {
+	return new(big.Int).Mod(x, y)
 }
 
 //named return value *fieldelement
-func NewFieldElement(num int, prime int) (f *FieldElement) {
+func NewFieldElement(num *big.Int, prime *big.Int) (f *FieldElement) {
 	f = new(FieldElement)
-	if num >= prime || num < 0 {
-		error := fmt.Sprintf("Num %d not in field range 0 to %d", num, (prime - 1))
+	if num.Sign() < 0 || num.Cmp(prime) >= 0 {
+		error := fmt.Sprintf("Num %d not in field range 0 to %d", num, new(big.Int).Sub(prime, big.NewInt(1)))
 		panic(error)
 	}
-	f.num = num
+	f.num = new(big.Int).Set(num)
 	f.prime = prime
 	return
 }
@@ -50,78 +49,102 @@ func (f *FieldElement) Eq(other *FieldElement) bool {
 	if other == nil {
 		return false
 	}
-	return reflect.DeepEqual(f.num, other.num) && reflect.DeepEqual(f.prime, other.prime)
+	return f.num.Cmp(other.num) == 0 && f.prime.Cmp(other.prime) == 0
 }
 
 func (f *FieldElement) Ne(other *FieldElement) bool {
-	return !reflect.DeepEqual(f.num, other.num) && reflect.DeepEqual(f.prime, other.prime)
+	return !f.Eq(other)
 }
 
-func (f *FieldElement) Add(other *FieldElement) *FieldElement {
-	if !reflect.DeepEqual(f.prime, other.prime) {
-		panic(fmt.Errorf("TypeError: %v", "Cannot Add two numbers in different Fields"))
+func (f *FieldElement) checkField(other *FieldElement, op string) {
+	if f.prime.Cmp(other.prime) != 0 {
+		panic(fmt.Errorf("TypeError: Cannot %s two numbers in different Fields", op))
 	}
-	num := (f.num + other.num) % f.prime
+}
+
+func (f *FieldElement) Add(other *FieldElement) *FieldElement {
+	f.checkField(other, "Add")
+	num := mod(new(big.Int).Add(f.num, other.num), f.prime)
 	return NewFieldElement(num, f.prime)
 }
+
 func (f *FieldElement) Sub(other *FieldElement) *FieldElement {
-	if !reflect.DeepEqual(f.prime, other.prime) {
-		panic(fmt.Errorf("TypeError: %v", "Cannot Subtract two numbers in different Fields"))
-	}
-	num := (f.num - other.num) % f.prime
+	f.checkField(other, "Subtract")
+	num := mod(new(big.Int).Sub(f.num, other.num), f.prime)
 	return NewFieldElement(num, f.prime)
 }
 
 func (f *FieldElement) Mul(other *FieldElement) *FieldElement {
-	if !reflect.DeepEqual(f.prime, other.prime) {
-		panic(fmt.Errorf("TypeError: %v", "Cannot multiply two numbers in different Fields"))
-	}
-	num := (f.num * other.num) % f.prime
+	f.checkField(other, "multiply")
+	num := mod(new(big.Int).Mul(f.num, other.num), f.prime)
+	return NewFieldElement(num, f.prime)
+}
+
+//Rmul multiplies the element by an integer coefficient, i.e. adds it to itself
+func (f *FieldElement) Rmul(coefficient *big.Int) *FieldElement {
+	num := mod(new(big.Int).Mul(f.num, coefficient), f.prime)
 	return NewFieldElement(num, f.prime)
 }
 
-func (f *FieldElement) Pow(exponent int) *FieldElement {
-	var n int64 = mod(int64(exponent), int64(f.prime-1))
-	//fmt.Println("n is ", n)
-	num := int(math.Pow(float64(f.num), float64(n))) % (f.prime)
-	//fmt.Println("num is ", num)
+func (f *FieldElement) Pow(exponent *big.Int) *FieldElement {
+	//Fermat's little theorem lets us bring negative exponents into 0..p-2
+	n := mod(exponent, new(big.Int).Sub(f.prime, big.NewInt(1)))
+	num := new(big.Int).Exp(f.num, n, f.prime)
 	return NewFieldElement(num, f.prime)
 }
 
-func (f *FieldElement) Truediv(other *FieldElement) {
-	if !reflect.DeepEqual(f.prime, other.prime) {
-		panic(fmt.Errorf("TypeError: %v", "Cannot divide two numbers in different Fields"))
+func (f *FieldElement) Truediv(other *FieldElement) *FieldElement {
+	f.checkField(other, "divide")
+	if other.num.Sign() == 0 {
+		panic(fmt.Errorf("ZeroDivisionError: %v", "division by zero in finite field"))
 	}
-	num := f.num * (int(math.Pow(float64(other.num), float64(f.prime-2))) % f.prime) % f.prime
-	NewFieldElement(num, f.prime)
+	//a/b == a*b**(p-2) since b**(p-1) == 1
+	inv := new(big.Int).Exp(other.num, new(big.Int).Sub(f.prime, big.NewInt(2)), f.prime)
+	num := mod(new(big.Int).Mul(f.num, inv), f.prime)
+	return NewFieldElement(num, f.prime)
 }
 
 type Point struct {
-	a int
-	b int
-	x int
-	y int
+	a *FieldElement
+	b *FieldElement
+	x *FieldElement //x and y are both nil for the point at infinity
+	y *FieldElement
 }
 
-func NewPoint(x, y, a, b int) (p *Point) {
+func NewPoint(x, y, a, b *FieldElement) (p *Point) {
 	p = new(Point)
 	p.a = a
 	p.b = b
 	p.x = x
 	p.y = y
 
-	if p.x == 0 && p.y == 0 {
+	if p.x == nil && p.y == nil {
 		return
 	}
-	if !reflect.DeepEqual(math.Pow(float64(p.y), float64(2)), math.Pow(float64(p.x), float64(3))+float64(a*x)+float64(b)) {
-		error := fmt.Sprintf("(%d, %d) is not on the curve", x, y)
+	if p.x == nil || p.y == nil {
+		panic(fmt.Errorf("ValueError: %v", "a point needs both coordinates or neither"))
+	}
+	//y**2 == x**3 + a*x + b
+	three := big.NewInt(3)
+	if !p.y.Pow(big.NewInt(2)).Eq(p.x.Pow(three).Add(a.Mul(x)).Add(b)) {
+		error := fmt.Sprintf("(%d, %d) is not on the curve", x.num, y.num)
 		panic(error)
 	}
 	return
 }
 
+func (p *Point) isInfinity() bool {
+	return p.x == nil
+}
+
 func (p *Point) Eq(other *Point) bool {
-	return reflect.DeepEqual(p.x, other.x) && reflect.DeepEqual(p.y, other.y) && reflect.DeepEqual(p.a, other.a) && reflect.DeepEqual(p.b, other.b)
+	if other == nil || !p.a.Eq(other.a) || !p.b.Eq(other.b) {
+		return false
+	}
+	if p.isInfinity() || other.isInfinity() {
+		return p.isInfinity() && other.isInfinity()
+	}
+	return p.x.Eq(other.x) && p.y.Eq(other.y)
 }
 
 func (p *Point) Ne(other *Point) bool {
@@ -129,74 +152,72 @@ func (p *Point) Ne(other *Point) bool {
 }
 
 func (p *Point) Repr() string {
-	if p.x == 0 {
+	if p.isInfinity() {
 		return "Point(infinity)"
 	} else {
-		err := fmt.Sprintf("Point(%d,%d)_%d_%d", p.x, p.y, p.a, p.b)
+		err := fmt.Sprintf("Point(%d,%d)_%d_%d FieldElement(%d)", p.x.num, p.y.num, p.a.num, p.b.num, p.x.prime)
 		return err
 	}
 }
 
-func (p *Point) Add(other *Point) interface{} {
-	if !reflect.DeepEqual(p.a, other.a) || !reflect.DeepEqual(p.b, other.b) {
-		panic(errors.New("exception, points are not on the curve"))
+func (p *Point) Add(other *Point) *Point {
+	if !p.a.Eq(other.a) || !p.b.Eq(other.b) {
+		panic(fmt.Errorf("TypeError: Points %s, %s are not on the same curve", p.Repr(), other.Repr()))
 	}
 	//Case 0.0: self is the point at infinity, return other
-	if p.x == 0 {
+	if p.isInfinity() {
 		return other
 	}
 	//Case 0.1: other is the point at infinity, return self
-	if other.x == 0 {
+	if other.isInfinity() {
 		return p
 	}
 	//Case 1: self.x == other.x, self.y != other.y
 	//Result is point at infinity
-	if reflect.DeepEqual(p.x, other.x) && !reflect.DeepEqual(p.y, other.y) {
-		return NewPoint(0, 0, p.a, p.b)
+	if p.x.Eq(other.x) && p.y.Ne(other.y) {
+		return NewPoint(nil, nil, p.a, p.b)
 	}
 	//Case 2: self.x ≠ other.x
 	//Formula (x3,y3)==(x1,y1)+(x2,y2)
 	//s=(y2-y1)/(x2-x1)
 	//x3=s**2-x1-x2
 	//y3=s*(x1-x3)-y1
-	if !reflect.DeepEqual(p.x, other.x) {
-		s := (other.y - p.y) / (other.x - p.x)
-		x := math.Pow(float64(s), 2) - float64(p.x) - float64(other.x)
-		y := float64(s)*(float64(p.x)-x) - float64(p.y)
-		return NewPoint(int(x), int(y), p.a, p.b)
+	if p.x.Ne(other.x) {
+		s := other.y.Sub(p.y).Truediv(other.x.Sub(p.x))
+		x := s.Mul(s).Sub(p.x).Sub(other.x)
+		y := s.Mul(p.x.Sub(x)).Sub(p.y)
+		return NewPoint(x, y, p.a, p.b)
 	}
 	//Case 4: if we are tangent to the vertical line,
 	//we return the point at infinity
 	//note instead of figuring out what 0 is for each type
 	//we just use 0 * self.x
-	if p.Eq(other) && reflect.DeepEqual(p.y, 0*int(p.x)) {
-		return NewPoint(0, 0, p.a, p.b)
+	if p.Eq(other) && p.y.Eq(p.x.Rmul(big.NewInt(0))) {
+		return NewPoint(nil, nil, p.a, p.b)
 	}
 	//Case 3: self == other
 	//Formula (x3,y3)=(x1,y1)+(x1,y1)
 	//s=(3*x1**2+a)/(2*y1)
 	//x3=s**2-2*x1
 	//y3=s*(x1-x3)-y1
-	if p.Eq(other) {
-		s := float64(3*math.Pow(float64(p.x), float64(2))+float64(p.a)) / float64(2*int(p.y))
-		x := math.Pow(s, float64(2)) - float64(2*int(p.x))
-		y := s*(float64(p.x)-x) - float64(p.y)
-		return NewPoint(int(x), int(y), p.a, p.b)
-	}
-	return NewPoint(0, 0, 0, 0)
+	s := p.x.Mul(p.x).Rmul(big.NewInt(3)).Add(p.a).Truediv(p.y.Rmul(big.NewInt(2)))
+	x := s.Mul(s).Sub(p.x.Rmul(big.NewInt(2)))
+	y := s.Mul(p.x.Sub(x)).Sub(p.y)
+	return NewPoint(x, y, p.a, p.b)
 }
 
-func (p *Point) Rmul(coefficient int) *Point {
-	coef := mod(int64(coefficient), N)
-	//coef := coefficient
+//Rmul uses binary expansion: double current every round and
+//add it to the result whenever the lowest bit of coef is set
+func (p *Point) Rmul(coefficient *big.Int) *Point {
+	coef := new(big.Int).Set(coefficient)
 	current := p
-	result := NewPoint(0, 0, p.a, p.b)
-	for coef != 0 {
-		if int(coef)&1 != 0 {
-			result.Add(current)
+	result := NewPoint(nil, nil, p.a, p.b)
+	for coef.Sign() > 0 {
+		if coef.Bit(0) == 1 {
+			result = result.Add(current)
 		}
-		current.Add(current)
-		coef >>= 1
+		current = current.Add(current)
+		coef.Rsh(coef, 1)
 	}
 	return result
 }
@@ -206,376 +227,342 @@ type S256Field struct {
 }
 
 //removed prime as an argument
-func NewS256Field(num int) (s *FieldElement) {
-	s = new(FieldElement)
-	s.num = num
-	prime := P
-	s.prime = prime
-	NewFieldElement(num, prime)
-	return
+func NewS256Field(num *big.Int) *FieldElement {
+	return NewFieldElement(num, P)
 }
 
 func (f *S256Field) Repr() string {
-	str := fmt.Sprintf("%064d", (f.num))
+	str := fmt.Sprintf("%064x", f.num)
 	return str
 }
 
+//sqrt only works for primes where p % 4 == 3, which is the case for secp256k1
 func (f *FieldElement) sqrt() *FieldElement {
-	return f.Pow((P + 1) / 4)
+	exponent := new(big.Int).Add(f.prime, big.NewInt(1))
+	return f.Pow(exponent.Rsh(exponent, 2))
 }
 
 type S256Point struct {
 	Point
-	a  int
-	b  int
-	xx *S256Field //we need to access num variable so we use new xx variable instead of the x variable from Point
-	yy *S256Field
-	//S256Field
 }
 
-func NewS256Point(xx, y, a, b interface{}) (sp *S256Point) {
-	//var a interface{}
-	//var b interface{}
+//NewS256Point takes the raw coordinates, pass nil for both to get the point at infinity
+func NewS256Point(x, y *big.Int) (sp *S256Point) {
 	sp = new(S256Point)
-	a, b = NewS256Field(A), NewS256Field(B)
-	if reflect.TypeOf(xx).Kind() == reflect.Int && xx != nil {
-		NewS256Point(xx, NewS256Field(y.(int)), a.(int), b.(int))
+	a, b := NewS256Field(A), NewS256Field(B)
+	if x == nil && y == nil {
+		sp.Point = *NewPoint(nil, nil, a, b)
 	} else {
-		NewS256Point(xx, y, a, b)
+		sp.Point = *NewPoint(NewS256Field(x), NewS256Field(y), a, b)
 	}
 	return
 }
 
-func (s256 *S256Point) Rmul2(coefficient int) *S256Point {
-	coef := mod(int64(coefficient), N)
-	//coef := coefficient
-	current := s256
-	result := NewS256Point(0, 0, s256.a, s256.b)
-	for coef != 0 {
-		if int(coef)&1 != 0 {
-			result.SAdd(current)
-		}
-		current.SAdd(current)
-		coef >>= 1
+func (sp *S256Point) Repr() string {
+	if sp.isInfinity() {
+		return "S256Point(infinity)"
 	}
-	return result
+	return fmt.Sprintf("S256Point(%064x, %064x)", sp.x.num, sp.y.num)
 }
 
-func (p *Point) SEq(other *S256Point) bool {
-	return reflect.DeepEqual(p.x, other.x) && reflect.DeepEqual(p.y, other.y) && reflect.DeepEqual(p.a, other.a) && reflect.DeepEqual(p.b, other.b)
+func (sp *S256Point) SEq(other *S256Point) bool {
+	return other != nil && sp.Point.Eq(&other.Point)
 }
 
-func (p *S256Point) SAdd(other *S256Point) *S256Point {
-	if !reflect.DeepEqual(p.a, other.a) || !reflect.DeepEqual(p.b, other.b) {
-		panic(errors.New("exception, points are not on the curve"))
-	}
-	//Case 0.0: p is the point at infinity, return other
-	if p.xx == nil {
-		return other
-	}
-	//Case 0.1: other is the point at infinity, return p
-	if other.xx == nil {
-		return p
-	}
-	//Case 1: p.x == other.x, p.y != other.y
-	//Result is point at infinity
-	if reflect.DeepEqual(p.x, other.x) && !reflect.DeepEqual(p.y, other.y) {
-		return NewS256Point(0, 0, p.a, p.b)
-	}
-	//Case 2: p.x ≠ other.x
-	//Formula (x3,y3)==(x1,y1)+(x2,y2)
-	//s=(y2-y1)/(x2-x1)
-	//x3=s**2-x1-x2
-	//y3=s*(x1-x3)-y1
-	if !reflect.DeepEqual(p.x, other.x) {
-		s := (other.y - p.y) / (other.xx.num - p.x)
-		x := math.Pow(float64(s), 2) - float64(p.x) - float64(other.x)
-		y := float64(s)*(float64(p.x)-x) - float64(p.y)
-		return NewS256Point(NewS256Field(int(x)), NewS256Field(int(y)), p.a, p.b)
-	}
-	//Case 4: if we are tangent to the vertical line,
-	//we return the point at infinity
-	//note instead of figuring out what 0 is for each type
-	//we just use 0 * p.x
-	if p.SEq(other) && reflect.DeepEqual(p.y, 0*int(p.x)) {
-		return NewS256Point(0, 0, p.a, p.b)
-	}
-	//Case 3: p == other
-	//Formula (x3,y3)=(x1,y1)+(x1,y1)
-	//s=(3*x1**2+a)/(2*y1)
-	//x3=s**2-2*x1
-	//y3=s*(x1-x3)-y1
-	if p.SEq(other) {
-		s := float64(3*math.Pow(float64(p.x), float64(2))+float64(p.a)) / float64(2*int(p.y))
-		x := math.Pow(s, float64(2)) - float64(2*int(p.x))
-		y := s*(float64(p.x)-x) - float64(p.y)
-		return NewS256Point(NewS256Field(int(x)), NewS256Field(int(y)), p.a, p.b)
+func (sp *S256Point) SAdd(other *S256Point) *S256Point {
+	return &S256Point{*sp.Point.Add(&other.Point)}
+}
+
+//Rmul2 reduces the coefficient mod N first since N*G is the point at infinity.
+//It runs in variable time, see rmulSecret for private keys and nonces.
+func (sp *S256Point) Rmul2(coefficient *big.Int) *S256Point {
+	coef := mod(coefficient, N)
+	p := newProjPoint(sp)
+	var result projPoint
+	if GLVENABLED {
+		result = p.mulGLV(coef)
 	} else {
-		return NewS256Point(0, 0, 0, 0)
+		result = p.mulWNAF(coef)
 	}
-
+	return result.toAffine()
 }
 
-func (s256 *S256Point) verify(z int64, sig *Signature) bool {
-	s_inv := mod(int64(math.Pow(sig.s.(float64), float64(N-2))), N)
-	u := mod((z * s_inv), N)
-	v := mod((sig.r.(int64) * s_inv), N)
-	total := new(S256Point)
-	total = (G.Rmul2(int(u))).SAdd(s256.Rmul2(int(v)))
-	return total.xx.num == sig.r
+func (sp *S256Point) verify(z *big.Int, sig *Signature) bool {
+	if sp.isInfinity() || sig.r.Sign() <= 0 || sig.r.Cmp(N) >= 0 || sig.s.Sign() <= 0 || sig.s.Cmp(N) >= 0 {
+		return false
+	}
+	//s_inv calculated using Fermat's little theorem, N is prime
+	sInv := new(big.Int).Exp(sig.s, new(big.Int).Sub(N, big.NewInt(2)), N)
+	u := mod(new(big.Int).Mul(z, sInv), N)
+	v := mod(new(big.Int).Mul(sig.r, sInv), N)
+	//u*G + v*P in a single pass over the precomputed G table
+	p := newProjPoint(sp)
+	var total projPoint
+	if GLVENABLED {
+		total = shamirMulGLV(u, v, &p)
+	} else {
+		total = shamirMul(u, v, &p)
+	}
+	return total.hasXCoordinate(sig.r)
 }
 
+//sec returns the binary version of the SEC format
 func (sp *S256Point) sec(compressed bool) string {
-	//returns the binary version of the SEC format
-	compressed = true
-	if bool(compressed) {
-		if sp.yy.num%2 == 0 {
-			z_bytes := make([]byte, 32)
-			binary.BigEndian.PutUint64(z_bytes, uint64(sp.xx.num))
-			return string(append([]byte("\u0002"), z_bytes...))
+	xBytes := make([]byte, 32)
+	sp.x.num.FillBytes(xBytes)
+	if compressed {
+		if sp.y.num.Bit(0) == 0 {
+			return string(append([]byte("\u0002"), xBytes...))
 		} else {
-			x_bytes := make([]byte, 32)
-			binary.BigEndian.PutUint64(x_bytes, uint64(sp.xx.num))
-			return string(append([]byte("\u0002"), x_bytes...))
+			return string(append([]byte("\u0003"), xBytes...))
 		}
 	} else {
-		x_bytes := make([]byte, 32)
-		binary.BigEndian.PutUint64(x_bytes, uint64(sp.xx.num))
-		y_bytes := make([]byte, 32)
-		binary.BigEndian.PutUint64(y_bytes, uint64(sp.yy.num))
-		return string(append(append([]byte("\u0004"), x_bytes...), y_bytes...))
+		yBytes := make([]byte, 32)
+		sp.y.num.FillBytes(yBytes)
+		return string(append(append([]byte("\u0004"), xBytes...), yBytes...))
 	}
 }
 
-func (self *S256Point) hash160(compressed bool) string {
-	return hash160(string(self.sec(true)))
+func (sp *S256Point) hash160(compressed bool) string {
+	return hash160(sp.sec(compressed))
 }
 
-func (sp *S256Point) address(compressed, testnet bool) string {
-	//Returns the address string
-	compressed = true
-	testnet = false
+func (sp *S256Point) address(compressed bool, net *NetworkParams) string {
+	//Returns the p2pkh address string on net
 	h160 := sp.hash160(compressed)
-	var prefix []byte
+	return encodeBase58Checksum(string([]byte{net.p2pkhPrefix}) + h160)
+}
 
-	if testnet == true {
-		prefix = []byte("o")
-	} else {
-		prefix = []byte("\u0000")
+//parse returns a Point object from a SEC binary (not hex)
+func (sp *S256Point) parse(secBin []byte) *S256Point {
+	point, err := parseSec(secBin)
+	if err != nil {
+		panic(err)
 	}
-
-	return encodeBase58Checksum(string(prefix) + h160)
+	return point
 }
 
-func (sp *S256Point) parse(secBin []byte) *S256Point {
-	var evenBeta interface{}
-	var oddBeta interface{}
-	//returns a Point object from a SEC binary (not hex)
-	if secBin[0] == 4 {
-		x := binary.BigEndian.Uint32([]byte(secBin[1:33]))
-		y := binary.BigEndian.Uint32([]byte(secBin[33:65]))
-		return NewS256Point(x, y, nil, nil)
-	}
-	isEven := secBin[0] == 2
-	x := NewS256Field(int(binary.BigEndian.Uint32([]byte(secBin[1:]))))
-	//right side of the equation y^2 = x^3 + 7
-	alpha := x.Pow(3).Add(NewS256Field(B))
-	//solve for left side
-	beta := alpha.sqrt()
-	if beta.num%2 == 0 {
-		evenBeta = beta
-		oddBeta = NewS256Field(P - beta.num)
-	} else {
-		evenBeta = NewS256Field(P - beta.num)
-		oddBeta = beta
+//parseSec is parse for untrusted input, a bad encoding or a point that is
+//not on the curve comes back as an error instead of a panic
+func parseSec(secBin []byte) (*S256Point, error) {
+	if len(secBin) == 65 && secBin[0] == 4 {
+		x := new(big.Int).SetBytes(secBin[1:33])
+		y := new(big.Int).SetBytes(secBin[33:65])
+		if x.Cmp(P) >= 0 || y.Cmp(P) >= 0 {
+			return nil, fmt.Errorf("ValueError: %v", "sec coordinate not in field range")
+		}
+		//y^2 == x^3 + 7, checked here since NewS256Point panics otherwise
+		left := mod(new(big.Int).Mul(y, y), P)
+		right := mod(new(big.Int).Add(new(big.Int).Exp(x, big.NewInt(3), P), B), P)
+		if left.Cmp(right) != 0 {
+			return nil, fmt.Errorf("ValueError: %v", "point is not on the curve")
+		}
+		return NewS256Point(x, y), nil
 	}
-	if isEven {
-		return NewS256Point(x, evenBeta, nil, nil)
-	} else {
-		return NewS256Point(x, oddBeta, nil, nil)
+	if len(secBin) != 33 || (secBin[0] != 2 && secBin[0] != 3) {
+		return nil, fmt.Errorf("SyntaxError: %v", "bad sec encoding")
+	}
+	point, err := liftX(new(big.Int).SetBytes(secBin[1:]))
+	if err != nil {
+		return nil, err
+	}
+	//liftX gives the even y, the prefix says which one we want
+	if secBin[0] == 3 {
+		point = NewS256Point(point.x.num, new(big.Int).Sub(P, point.y.num))
 	}
+	return point, nil
 }
 
 type Signature struct {
-	r interface{}
-	s interface{}
+	r *big.Int
+	s *big.Int
+	//recid tells which of the up to four points with x == r was kG,
+	//-1 when unknown (e.g. parsed from DER)
+	recid int
 }
 
-func NewSignature(r interface{}, s interface{}) (ss *Signature) {
+func NewSignature(r *big.Int, s *big.Int) (ss *Signature) {
 	ss = new(Signature)
 	ss.r = r
 	ss.s = s
+	ss.recid = -1
 	return
 }
 
 func (S *Signature) Repr() string {
-	str := fmt.Sprintf("Signature(%s,%s)", S.r, S.s)
+	str := fmt.Sprintf("Signature(%x,%x)", S.r, S.s)
 	return str
 }
 
+//der returns the strict (BIP66) DER encoding of the signature
 func (S *Signature) der() string {
-	rbin := make([]byte, 32)
-	//remove all null bytes at the beginning
-	binary.BigEndian.PutUint64(rbin, uint64(S.r.(uint32))) //to bytes
-	rbin = []byte(strings.TrimLeft(string(rbin), string([]byte("\u0000"))))
-	//if rbin has a high bit, add a \x00
-	if rbin[0]&128 != 0 {
-		rbin = append([]byte("\u0000"), rbin...)
-	}
-	result := make([]byte, 2, len(rbin))
-	result = append(result, rbin...)
-	sbin := make([]byte, 32)
-	binary.BigEndian.PutUint64(rbin, uint64(S.s.(uint32)))
-	//remove all null bytes at the beginning
-	sbin = []byte(strings.TrimLeft(string(sbin), string([]byte("\u0000"))))
-	//if sbin has a high bit, add a \x00
-	if sbin[0]&128 != 0 {
-		sbin = append([]byte("\u0000"), sbin...)
-	}
-	result = append(result, append(make([]byte, 2, len(sbin)), sbin...)...)
-	return string(append(make([]byte, 48, len(result)), result...))
-}
-
-func (S *Signature) parse(signatureBin []byte) *Signature {
-	var s bytes.Buffer
-	s.Write(signatureBin)
-	compound, _ := s.ReadByte()
-	if compound != 48 {
-		panic(fmt.Errorf("SyntaxError: %v", "Bad Signature"))
-	}
-	length, _ := s.ReadByte()
-	if int(length+2) != len(signatureBin) {
-		panic(fmt.Errorf("SyntaxError: %v", "Bad Signature Length"))
-	}
-	marker, _ := s.ReadByte()
-	if marker != 2 {
-		panic(fmt.Errorf("SyntaxError: %v", "Bad Signature"))
-	}
-	rlength, _ := s.ReadByte()
-	b, _ := s.ReadBytes(rlength) //get the byte slice
-	r := binary.BigEndian.Uint32(b)
-	marker, _ = s.ReadByte()
-	if marker != 2 {
-		panic(fmt.Errorf("SyntaxError: %v", "Bad Signature"))
-	}
-	slength, _ := s.ReadByte()
-	c, _ := s.ReadBytes(slength) //get the byte slice
-	var x []byte
-	s.Write(append(x, byte((binary.BigEndian.Uint32(c)))))
-	if len(signatureBin) != 6+int(rlength)+int(slength) {
-		panic(fmt.Errorf("SyntaxError: %v", "Signature too long"))
-	}
-	return NewSignature(r, s)
-}
-
-var G = NewS256Point(0x79be667ef9dcbbac55a06295ce870b07029bfcdb2dce28d959f2815b16f81798, 0x483ada7726a3c4655da4fbfc0e1108a8fd17b448a68554199c47d08ffb10d4b8, 0, 0)
+	rbin := derInteger(S.r)
+	sbin := derInteger(S.s)
+	result := append([]byte{0x30, byte(len(rbin) + len(sbin))}, rbin...)
+	return string(append(result, sbin...))
+}
+
+//parse reads a DER signature, anything that breaks BIP66 gives a DERError
+func (S *Signature) parse(signatureBin []byte) (*Signature, error) {
+	if err := checkDEREncoding(signatureBin); err != nil {
+		return nil, err
+	}
+	rlength := int(signatureBin[3])
+	r := new(big.Int).SetBytes(signatureBin[4 : 4+rlength])
+	s := new(big.Int).SetBytes(signatureBin[6+rlength:])
+	return NewSignature(r, s), nil
+}
+
+var gx, _ = new(big.Int).SetString("79be667ef9dcbbac55a06295ce870b07029bfcdb2dce28d959f2815b16f81798", 16)
+var gy, _ = new(big.Int).SetString("483ada7726a3c4655da4fbfc0e1108a8fd17b448a68554199c47d08ffb10d4b8", 16)
+
+var G = NewS256Point(gx, gy)
 
 type PrivateKey struct {
-	secret int
+	secret *big.Int
 	//point has to point to s256point to access sec() function in tx file
 	point *S256Point
 }
 
-func NewPrivateKey(secret int) (pk *PrivateKey) {
+func NewPrivateKey(secret *big.Int) (pk *PrivateKey) {
+	if secret.Sign() <= 0 || secret.Cmp(N) >= 0 {
+		panic(fmt.Errorf("ValueError: %v", "secret not in range 1 to N-1"))
+	}
 	pk = new(PrivateKey)
-	pk.secret = secret
-	pk.point = new(S256Point)
-	pk.point = (G.Rmul2(secret))
+	pk.secret = new(big.Int).Set(secret)
+	//the secret must never go through the variable time Rmul2
+	pk.point = G.rmulSecret(pk.secret)
 	return
 }
 
-func (p *PrivateKey) hex() {
-	fmt.Printf("%64d", (p.secret))
+func (p *PrivateKey) hex() string {
+	return fmt.Sprintf("%064x", p.secret)
 }
 
-func (pk *PrivateKey) sign(z int64) *Signature {
-	k := pk.deterministic_k(z)
-	r := (G.Rmul2(int(k))).xx.num
-	k_inv := mod(int64(math.Pow(float64(k), float64(N-2))), N)
-	s := (z + int64(r*pk.secret)) * k_inv % N
-	if float64(s) > float64(N)/float64(2) {
-		s = N - s
-	}
-	return NewSignature(r, s)
-}
+//the largest DER signature plus sighash byte that sign can produce. s is
+//always low so it never needs a padding byte, r only when it is not ground.
+const (
+	MAXSIGSIZE     = 72
+	MAXSIGSIZELOWR = 71
+)
 
-func (pk *PrivateKey) deterministic_k(z int64) int64 {
-	k := func(repeated []byte, n int) (result []byte) {
-		for i := 0; i < n; i++ {
-			result = append(result, repeated...)
-		}
-		return result
-	}([]byte("\u0000"), 32)
-	v := func(repeated []byte, n int) (result []byte) {
-		for i := 0; i < n; i++ {
-			result = append(result, repeated...)
+//sign signs z with the RFC6979 nonce. With grindR set it keeps retrying
+//with an extra entropy counter, like Bitcoin Core, until r has its high bit
+//clear so the signature is at most MAXSIGSIZELOWR bytes in a script.
+func (pk *PrivateKey) sign(z *big.Int, grindR bool) *Signature {
+	sig := pk.signWithNonce(z, pk.deterministic_k(z, nil))
+	extraEntropy := make([]byte, 32)
+	for counter := uint32(1); grindR && !sig.hasLowR(); counter++ {
+		binary.LittleEndian.PutUint32(extraEntropy, counter)
+		sig = pk.signWithNonce(z, pk.deterministic_k(z, extraEntropy))
+	}
+	return sig
+}
+
+func (pk *PrivateKey) signWithNonce(z *big.Int, k *big.Int) *Signature {
+	//r is the x coordinate of the kG point
+	R := G.rmulSecret(k)
+	r := R.x.num
+	//s = (z + r*secret) / k, all of it on constant time scalars.
+	//k_inv is found with Fermat's little theorem, k**(N-2)
+	var kInv, sum, rSecret scalarVal
+	kVal := newScalarVal(k)
+	kInv.inverse(&kVal)
+	rVal, secretVal, zVal := newScalarVal(r), newScalarVal(pk.secret), newScalarVal(z)
+	rSecret.mul(&rVal, &secretVal)
+	sum.add(&zVal, &rSecret)
+	sum.mul(&sum, &kInv)
+	//bit 0 of the recovery id is the parity of y, bit 1 is set when
+	//the x coordinate of kG was at least N and r wrapped around
+	recid := int(R.y.num.Bit(0))
+	if R.x.num.Cmp(N) >= 0 {
+		recid |= 2
+	}
+	//using the low-s value will get nodes to relay our transactions.
+	//negating s is the same as using -k, whose point has the other y
+	sig := NewSignature(mod(r, N), sum.big())
+	if !sig.isLowS() {
+		sig = sig.normalizeS()
+		recid ^= 1
+	}
+	sig.recid = recid
+	return sig
+}
+
+//deterministic_k generates the nonce from the secret and z as in RFC6979
+//(HMAC-SHA256, qlen 256). extraEntropy is optional additional data (section
+//3.6), pass nil for the plain RFC6979 nonce. Bitcoin Core passes a 32 byte
+//little-endian counter here when grinding for low-R signatures.
+func (pk *PrivateKey) deterministic_k(z *big.Int, extraEntropy []byte) *big.Int {
+	k := bytes.Repeat([]byte("\u0000"), 32)
+	v := bytes.Repeat([]byte("\u0001"), 32)
+	hmacSum := func(key []byte, data ...[]byte) []byte {
+		h := hmac.New(sha256.New, key)
+		for _, d := range data {
+			h.Write(d)
 		}
-		return result
-	}([]byte("\u0001"), 32)
-	if z > N {
-		z -= N
+		return h.Sum(nil)
 	}
+	//int2octets(secret) and bits2octets(z), z is reduced mod N first
+	secretBytes := intToBytes32(pk.secret)
+	zBytes := intToBytes32(mod(z, N))
 
-	z_bytes := make([]byte, 32)
-	binary.BigEndian.PutUint64(z_bytes, uint64(z))
-	secret_bytes := make([]byte, 32)
-	binary.BigEndian.PutUint64(secret_bytes, uint64(pk.secret))
-
-	kk := hmac.New(sha256.New, k)                                                         // Create a new HMAC by defining the hash type and the key (as byte array)
-	kk.Write(append(append(append(v, []byte("\u0000")...), secret_bytes...), z_bytes...)) // Write Data to it
-	//sha := hex.EncodeToString(kk.Sum(nil))
-	k, _ = hex.DecodeString(hex.EncodeToString(kk.Sum(nil))) //DecodeString returns the bytes represented by the hexadecimal string s.
-
-	vv := hmac.New(sha256.New, k)
-	vv.Write(v)
-	v, _ = hex.DecodeString(hex.EncodeToString(vv.Sum(nil)))
-
-	kk = hmac.New(sha256.New, v)
-	kk.Write(append(append(append(v, []byte("\u0000")...), secret_bytes...), z_bytes...))
-	k, _ = hex.DecodeString(hex.EncodeToString(kk.Sum(nil)))
-
-	vv = hmac.New(sha256.New, k)
-	vv.Write(v)
-	v, _ = hex.DecodeString(hex.EncodeToString(vv.Sum(nil)))
-
+	k = hmacSum(k, v, []byte("\u0000"), secretBytes, zBytes, extraEntropy)
+	v = hmacSum(k, v)
+	k = hmacSum(k, v, []byte("\u0001"), secretBytes, zBytes, extraEntropy)
+	v = hmacSum(k, v)
 	for {
-		vv = hmac.New(sha256.New, k)
-		vv.Write(v)
-		v, _ = hex.DecodeString(hex.EncodeToString(vv.Sum(nil)))
-		candidate := int64(binary.LittleEndian.Uint64(v))
-		if candidate >= 1 && candidate < N {
+		v = hmacSum(k, v)
+		candidate := new(big.Int).SetBytes(v)
+		if candidate.Sign() > 0 && candidate.Cmp(N) < 0 {
 			return candidate
 		}
-		kk = hmac.New(sha256.New, k)
-		kk.Write(append(k, []byte("\u0000")...))
-		k, _ = hex.DecodeString(hex.EncodeToString(kk.Sum(nil)))
-
-		vv = hmac.New(sha256.New, k)
-		vv.Write(v)
-		v, _ = hex.DecodeString(hex.EncodeToString(vv.Sum(nil)))
-
+		k = hmacSum(k, v, []byte("\u0000"))
+		v = hmacSum(k, v)
 	}
 }
 
-func (pk *PrivateKey) wif(compressed, testnet bool) string {
-	compressed = true
-	testnet = true
-	secretBytes := make([]byte, 32)
-	binary.BigEndian.PutUint64(secretBytes, uint64(pk.secret)) //to bytes
-	var prefix []byte
+func (pk *PrivateKey) wif(compressed bool, net *NetworkParams) string {
+	prefix := []byte{net.wifPrefix}
 	var suffix []byte
-	if testnet {
-		prefix = []byte("\u00ef")
-	} else {
-		prefix = []byte("\u0080")
-	}
 
 	if compressed {
-		suffix = []byte("\u0001")
+		suffix = []byte{0x01}
 	} else {
-		suffix = []byte("")
+		suffix = []byte{}
 	}
 
-	return encodeBase58Checksum(string(append(append(prefix, secretBytes...), suffix...)))
+	return encodeBase58Checksum(string(append(append(prefix, intToBytes32(pk.secret)...), suffix...)))
+}
 
+//parseWIF reads a key exported by wif, along with the compressed flag and
+//the network its prefix belongs to. The test networks share 0xef, a key
+//with that prefix comes back as TESTNET3 for the whole testnet family.
+func parseWIF(wif string) (pk *PrivateKey, compressed bool, net *NetworkParams, err error) {
+	payload, err := decodeBase58Checksum(wif)
+	if err != nil {
+		return nil, false, nil, err
+	}
+	switch {
+	case len(payload) == 34 && payload[33] == 0x01:
+		compressed = true
+	case len(payload) == 33:
+		compressed = false
+	default:
+		return nil, false, nil, fmt.Errorf("SyntaxError: %v", "WIF payload has the wrong length")
+	}
+	for _, candidate := range NETWORKS {
+		if candidate.wifPrefix == payload[0] {
+			net = candidate
+			break
+		}
+	}
+	if net == nil {
+		return nil, false, nil, fmt.Errorf("SyntaxError: unknown WIF prefix %#02x", payload[0])
+	}
+	secret := new(big.Int).SetBytes(payload[1:33])
+	if secret.Sign() == 0 || secret.Cmp(N) >= 0 {
+		return nil, false, nil, fmt.Errorf("ValueError: %v", "secret not in range 1 to N-1")
+	}
+	return NewPrivateKey(secret), compressed, net, nil
 }
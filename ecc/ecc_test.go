@@ -0,0 +1,145 @@
+package ecc
+
+import (
+	"crypto/sha256"
+	"encoding/binary"
+	"encoding/hex"
+	"math/big"
+	"testing"
+)
+
+func hexInt(s string) *big.Int {
+	return new(big.Int).SetBytes(mustHex(s))
+}
+
+//TestRFC6979 checks the nonces of the secp256k1/SHA-256 vectors that
+//circulate with python-ecdsa and Trezor, z is sha256 of the message
+func TestRFC6979(t *testing.T) {
+	nMinus1 := new(big.Int).Sub(N, big.NewInt(1))
+	tests := []struct {
+		secret *big.Int
+		msg    string
+		k      string
+		der    string
+	}{
+		{
+			big.NewInt(1),
+			"Satoshi Nakamoto",
+			"8f8a276c19f4149656b280621e358cce24f5f52542772691ee69063b74f15d15",
+			"3045022100934b1ea10a4b3c1757e2b0c017d0b6143ce3c9a7e6a4a49860d7a6ab210ee3d802202442ce9d2b916064108014783e923ec36b49743e2ffa1c4496f01a512aafd9e5",
+		},
+		{
+			big.NewInt(1),
+			"All those moments will be lost in time, like tears in rain. Time to die...",
+			"38aa22d72376b4dbc472e06c3ba403ee0a394da63fc58d88686c611aba98d6b3",
+			"30450221008600dbd41e348fe5c9465ab92d23e3db8b98b873beecd930736488696438cb6b0220547fe64427496db33bf66019dacbf0039c04199abb0122918601db38a72cfc21",
+		},
+		{
+			nMinus1,
+			"Satoshi Nakamoto",
+			"33a19b60e25fb6f4435af53a3d42d493644827367e6453928554f43e49aa6f90",
+			"",
+		},
+		{
+			hexInt("f8b8af8ce3c7cca5e300d33939540c10d45ce001b8f252bfbc57ba0342904181"),
+			"Alan Turing",
+			"525a82b70e67874398067543fd84c83d30c175fdc45fdeee082fe13b1d7cfdf1",
+			"304402207063ae83e7f62bbb171798131b4a0564b956930092b33b07b395615d9ec7e15c022058dfcc1e00a35e1572f366ffe34ba0fc47db1e7189759b9fb233c5b05ab388ea",
+		},
+		{
+			hexInt("e91671c46231f833a6406ccbea0e3e392c76c167bac1cb013f6f1013980455c2"),
+			"There is a computer disease that anybody who works with computers knows about. It's a very serious disease and it interferes completely with the work. The trouble with computers is that you 'play' with them!",
+			"1f4b84c23a86a221d233f2521be018d9318639d5b8bbd6374a8a59232d16ad3d",
+			"3045022100b552edd27580141f3b2a5463048cb7cd3e047b97c9f98076c32dbdf85a68718b0220279fa72dd19bfae05577e06c7c0c1900c371fcd5893f7e1d56a37d30174671f6",
+		},
+	}
+	for _, test := range tests {
+		privateKey := NewPrivateKey(test.secret)
+		h := sha256.Sum256([]byte(test.msg))
+		z := new(big.Int).SetBytes(h[:])
+		if k := privateKey.deterministic_k(z, nil); k.Cmp(hexInt(test.k)) != 0 {
+			t.Errorf("%q: k = %x, want %s", test.msg, k, test.k)
+		}
+		sig := privateKey.sign(z, false)
+		if test.der != "" && hex.EncodeToString([]byte(sig.der())) != test.der {
+			t.Errorf("%q: signature %x, want %s", test.msg, sig.der(), test.der)
+		}
+		if !privateKey.point.verify(z, sig) {
+			t.Errorf("%q: signature does not verify", test.msg)
+		}
+	}
+}
+
+//TestSignCore checks Bitcoin Core's deterministic signatures of "Very
+//deterministic message" from key_tests.cpp, both have a low R already
+func TestSignCore(t *testing.T) {
+	z := new(big.Int).SetBytes([]byte(hash256("Very deterministic message")))
+	tests := []struct {
+		wif string
+		der string
+	}{
+		{"5HxWvvfubhXpYYpS3tJkw6fq9jE9j18THftkZjHHfmFiWtmAbrj", "304402205dbbddda71772d95ce91cd2d14b592cfbc1dd0aabd6a394b6c2d377bbe59d31d022014ddda21494a4e221f0824f0b8b924c43fa43c0ad57dccdaa11f81a6bd4582f6"},
+		{"5KC4ejrDjv152FGwP386VD1i2NYc5KkfSMyv1nGy1VGDxGHqVY3", "3044022052d8a32079c11e79db95af63bb9600c5b04f21a9ca33dc129c2bfa8ac9dc1cd5022061d8ae5e0f6c1a16bde3719c64c2fd70e404b6428ab9a69566962e8771b5944d"},
+	}
+	for _, test := range tests {
+		privateKey, _, _, err := parseWIF(test.wif)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if got := hex.EncodeToString([]byte(privateKey.sign(z, true).der())); got != test.der {
+			t.Errorf("%s: signature %s, want %s", test.wif, got, test.der)
+		}
+	}
+}
+
+//TestRFC6979ExtraEntropy covers section 3.6: the additional data goes
+//into both HMAC key updates after the message, so it changes the nonce,
+//and Core's low-R grinding feeds a little-endian counter through it
+func TestRFC6979ExtraEntropy(t *testing.T) {
+	privateKey := NewPrivateKey(big.NewInt(1))
+	h := sha256.Sum256([]byte("Satoshi Nakamoto"))
+	z := new(big.Int).SetBytes(h[:])
+	plain := privateKey.deterministic_k(z, nil)
+	seen := map[string]bool{plain.String(): true}
+	counter := make([]byte, 32)
+	for i := uint32(0); i < 4; i++ {
+		binary.LittleEndian.PutUint32(counter, i)
+		k := privateKey.deterministic_k(z, counter)
+		if k.Cmp(privateKey.deterministic_k(z, append([]byte{}, counter...))) != 0 {
+			t.Fatalf("counter %d: nonce is not deterministic", i)
+		}
+		if seen[k.String()] {
+			t.Fatalf("counter %d: nonce %x repeats", i, k)
+		}
+		seen[k.String()] = true
+	}
+	//the first signature with a high R has to be ground until R is low
+	ground := 0
+	for i := int64(1); ground < 3; i++ {
+		privateKey := NewPrivateKey(big.NewInt(i))
+		if privateKey.sign(z, false).hasLowR() {
+			continue
+		}
+		ground++
+		sig := privateKey.sign(z, true)
+		if !sig.hasLowR() || len(sig.der()) > MAXSIGSIZELOWR-1 || !privateKey.point.verify(z, sig) {
+			t.Errorf("secret %d: ground signature %x", i, sig.der())
+		}
+		//it has to be one of the counter nonces, in order
+		for counter := uint32(1); ; counter++ {
+			candidate := privateKey.signWithNonce(z, privateKey.deterministic_k(z, counter32(counter)))
+			if candidate.hasLowR() {
+				if candidate.der() != sig.der() {
+					t.Errorf("secret %d: ground signature is not from counter %d", i, counter)
+				}
+				break
+			}
+		}
+	}
+}
+
+func counter32(counter uint32) []byte {
+	extra := make([]byte, 32)
+	binary.LittleEndian.PutUint32(extra, counter)
+	return extra
+}
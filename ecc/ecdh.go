@@ -0,0 +1,61 @@
+package ecc
+
+import (
+	"crypto/sha256"
+	"fmt"
+	"math/big"
+)
+
+//ECDHHashFunc turns the 32 byte big-endian coordinates of the shared
+//point into the shared secret
+type ECDHHashFunc func(x, y []byte) []byte
+
+//ecdhHashSHA256 is libsecp256k1's default, sha256 of the compressed
+//encoding of the shared point
+func ecdhHashSHA256(x, y []byte) []byte {
+	version := byte(0x02 | y[31]&1)
+	h := sha256.Sum256(append([]byte{version}, x...))
+	return h[:]
+}
+
+//isOnCurve checks y**2 == x**3 + 7 with both coordinates in field range
+func (sp *S256Point) isOnCurve() bool {
+	if sp.x == nil || sp.y == nil {
+		return false
+	}
+	x, y := sp.x.num, sp.y.num
+	if x.Sign() < 0 || x.Cmp(P) >= 0 || y.Sign() < 0 || y.Cmp(P) >= 0 {
+		return false
+	}
+	left := mod(new(big.Int).Mul(y, y), P)
+	right := new(big.Int).Exp(x, big.NewInt(3), P)
+	return left.Cmp(mod(right.Add(right, B), P)) == 0
+}
+
+//ecdh derives a shared secret with the owner of peer, both sides
+//get the same bytes since a*(b*G) == b*(a*G)
+func (pk *PrivateKey) ecdh(peer *S256Point) ([]byte, error) {
+	return pk.ecdhWithHash(peer, ecdhHashSHA256)
+}
+
+//ecdhWithHash is ecdh with a custom hash of the shared point, peer is
+//untrusted input so anything but a finite point on the curve is refused
+func (pk *PrivateKey) ecdhWithHash(peer *S256Point, hashFn ECDHHashFunc) ([]byte, error) {
+	if hashFn == nil {
+		return nil, fmt.Errorf("ValueError: %v", "no hash function given")
+	}
+	if peer == nil || peer.isInfinity() {
+		return nil, fmt.Errorf("ValueError: %v", "peer public key is the point at infinity")
+	}
+	if !peer.isOnCurve() {
+		return nil, fmt.Errorf("ValueError: %v", "peer public key is not on the curve")
+	}
+	//the secret is the multiplier so this has to be the constant time path,
+	//and the shared point stays in field elements until it is hashed
+	shared := peer.mulSecret(pk.secret)
+	x, y, ok := shared.affineBytes()
+	if !ok {
+		return nil, fmt.Errorf("RuntimeError: %v", "shared point is the point at infinity")
+	}
+	return hashFn(x[:], y[:]), nil
+}
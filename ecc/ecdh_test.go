@@ -0,0 +1,49 @@
+package ecc
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"math/big"
+	"testing"
+)
+
+func TestECDH(t *testing.T) {
+	alice := NewPrivateKey(big.NewInt(0xa11ce))
+	bob := NewPrivateKey(new(big.Int).Sub(N, big.NewInt(0xb0b)))
+	aliceShared, err := alice.ecdh(bob.point)
+	if err != nil {
+		t.Fatal(err)
+	}
+	bobShared, err := bob.ecdh(alice.point)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !bytes.Equal(aliceShared, bobShared) {
+		t.Fatalf("shared secrets differ: %x %x", aliceShared, bobShared)
+	}
+	//same bytes as the variable time multiplication
+	shared := bob.point.Rmul2(alice.secret)
+	want := sha256.Sum256([]byte(shared.sec(true)))
+	if !bytes.Equal(aliceShared, want[:]) {
+		t.Fatalf("shared secret %x, want %x", aliceShared, want)
+	}
+	if _, err := alice.ecdh(NewS256Point(nil, nil)); err == nil {
+		t.Fatal("infinity accepted as peer key")
+	}
+	offCurve := &S256Point{bob.point.Point}
+	offCurve.y = NewS256Field(new(big.Int).Add(bob.point.y.num, big.NewInt(1)))
+	if _, err := alice.ecdh(offCurve); err == nil {
+		t.Fatal("point off the curve accepted as peer key")
+	}
+}
+
+func BenchmarkECDH(b *testing.B) {
+	alice := NewPrivateKey(randomScalar(b))
+	bob := NewPrivateKey(randomScalar(b))
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		if _, err := alice.ecdh(bob.point); err != nil {
+			b.Fatal(err)
+		}
+	}
+}
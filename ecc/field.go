@@ -0,0 +1,187 @@
+package ecc
+
+import (
+	"math/big"
+	"math/bits"
+)
+
+//fieldVal is an element of the secp256k1 base field stored as four
+//little-endian 64-bit limbs. Unlike FieldElement every operation runs
+//in constant time, so it is the type used whenever a secret is involved.
+//Values are always kept fully reduced, i.e. in the range 0 to P-1.
+type fieldVal [4]uint64
+
+//P = 2**256 - fieldC, which lets us fold the high half of a product back in
+const fieldC = 0x1000003d1
+
+var fieldP = fieldVal{0xfffffffefffffc2f, 0xffffffffffffffff, 0xffffffffffffffff, 0xffffffffffffffff}
+
+var fieldOne = fieldVal{1, 0, 0, 0}
+
+//fieldB3 is 3*B, used by the complete addition formulas
+var fieldB3 = fieldVal{21, 0, 0, 0}
+
+func newFieldVal(num *big.Int) (f fieldVal) {
+	var b [32]byte
+	mod(num, P).FillBytes(b[:])
+	f.setBytes(&b)
+	return
+}
+
+//setBytes reads a 32 byte big-endian number, reducing it mod P
+func (f *fieldVal) setBytes(b *[32]byte) {
+	for i := 0; i < 4; i++ {
+		f[i] = uint64(b[31-8*i]) | uint64(b[30-8*i])<<8 | uint64(b[29-8*i])<<16 | uint64(b[28-8*i])<<24 |
+			uint64(b[27-8*i])<<32 | uint64(b[26-8*i])<<40 | uint64(b[25-8*i])<<48 | uint64(b[24-8*i])<<56
+	}
+	//anything below 2**256 is less than 2P so one subtraction is enough
+	f.reduceOnce(0)
+}
+
+//bytes returns the 32 byte big-endian encoding
+func (f *fieldVal) bytes() (b [32]byte) {
+	for i := 0; i < 4; i++ {
+		for j := 0; j < 8; j++ {
+			b[31-8*i-j] = byte(f[i] >> (8 * j))
+		}
+	}
+	return
+}
+
+func (f *fieldVal) big() *big.Int {
+	b := f.bytes()
+	return new(big.Int).SetBytes(b[:])
+}
+
+//reduceOnce subtracts P when f (plus an overflow bit of 2**256) is at least P
+func (f *fieldVal) reduceOnce(carry uint64) {
+	var t fieldVal
+	var borrow uint64
+	t[0], borrow = bits.Sub64(f[0], fieldP[0], 0)
+	t[1], borrow = bits.Sub64(f[1], fieldP[1], borrow)
+	t[2], borrow = bits.Sub64(f[2], fieldP[2], borrow)
+	t[3], borrow = bits.Sub64(f[3], fieldP[3], borrow)
+	//keep t if there was an overflow or the subtraction did not borrow
+	f.cmov(&t, carry|(borrow^1))
+}
+
+//cmov sets f to g when flag is 1 and leaves it alone when flag is 0
+func (f *fieldVal) cmov(g *fieldVal, flag uint64) {
+	mask := -flag
+	for i := 0; i < 4; i++ {
+		f[i] = f[i]&^mask | g[i]&mask
+	}
+}
+
+func (f *fieldVal) isZero() uint64 {
+	z := f[0] | f[1] | f[2] | f[3]
+	//the top bit of z | -z is set unless z is zero
+	return ((z | -z) >> 63) ^ 1
+}
+
+func (f *fieldVal) equal(g *fieldVal) uint64 {
+	var d fieldVal
+	for i := 0; i < 4; i++ {
+		d[i] = f[i] ^ g[i]
+	}
+	return d.isZero()
+}
+
+func (f *fieldVal) add(a, b *fieldVal) *fieldVal {
+	var carry uint64
+	f[0], carry = bits.Add64(a[0], b[0], 0)
+	f[1], carry = bits.Add64(a[1], b[1], carry)
+	f[2], carry = bits.Add64(a[2], b[2], carry)
+	f[3], carry = bits.Add64(a[3], b[3], carry)
+	f.reduceOnce(carry)
+	return f
+}
+
+func (f *fieldVal) sub(a, b *fieldVal) *fieldVal {
+	var borrow, carry uint64
+	f[0], borrow = bits.Sub64(a[0], b[0], 0)
+	f[1], borrow = bits.Sub64(a[1], b[1], borrow)
+	f[2], borrow = bits.Sub64(a[2], b[2], borrow)
+	f[3], borrow = bits.Sub64(a[3], b[3], borrow)
+	//add P back when we went below zero
+	mask := -borrow
+	f[0], carry = bits.Add64(f[0], fieldP[0]&mask, 0)
+	f[1], carry = bits.Add64(f[1], fieldP[1]&mask, carry)
+	f[2], carry = bits.Add64(f[2], fieldP[2]&mask, carry)
+	f[3], _ = bits.Add64(f[3], fieldP[3]&mask, carry)
+	return f
+}
+
+func (f *fieldVal) neg(a *fieldVal) *fieldVal {
+	var zero fieldVal
+	return f.sub(&zero, a)
+}
+
+func (f *fieldVal) mul(a, b *fieldVal) *fieldVal {
+	var t [8]uint64
+	for i := 0; i < 4; i++ {
+		var carry uint64
+		for j := 0; j < 4; j++ {
+			hi, lo := bits.Mul64(a[i], b[j])
+			var c uint64
+			lo, c = bits.Add64(lo, t[i+j], 0)
+			hi += c
+			lo, c = bits.Add64(lo, carry, 0)
+			hi += c
+			t[i+j] = lo
+			carry = hi
+		}
+		t[i+4] = carry
+	}
+	f.reduce512(&t)
+	return f
+}
+
+func (f *fieldVal) sqr(a *fieldVal) *fieldVal {
+	return f.mul(a, a)
+}
+
+//reduce512 folds a 512 bit product into the field using 2**256 == fieldC mod P
+func (f *fieldVal) reduce512(t *[8]uint64) {
+	var r [4]uint64
+	var carry, c uint64
+	for i := 0; i < 4; i++ {
+		hi, lo := bits.Mul64(t[4+i], fieldC)
+		lo, c = bits.Add64(lo, t[i], 0)
+		hi += c
+		lo, c = bits.Add64(lo, carry, 0)
+		hi += c
+		r[i] = lo
+		carry = hi
+	}
+	//carry is below 2**34, fold it in a second time
+	hi, lo := bits.Mul64(carry, fieldC)
+	r[0], c = bits.Add64(r[0], lo, 0)
+	r[1], c = bits.Add64(r[1], hi, c)
+	r[2], c = bits.Add64(r[2], 0, c)
+	r[3], c = bits.Add64(r[3], 0, c)
+	//a last overflow can only happen when r is tiny, so this never carries out
+	r[0], c = bits.Add64(r[0], c*fieldC, 0)
+	r[1], c = bits.Add64(r[1], 0, c)
+	r[2], c = bits.Add64(r[2], 0, c)
+	r[3], _ = bits.Add64(r[3], 0, c)
+	*f = fieldVal(r)
+	f.reduceOnce(0)
+}
+
+//inverse uses Fermat's little theorem, a**(P-2). The exponent is public
+//so the sequence of squarings and multiplications never depends on a.
+func (f *fieldVal) inverse(a *fieldVal) *fieldVal {
+	exponent := fieldP
+	exponent[0] -= 2
+	result := fieldOne
+	base := *a
+	for i := 255; i >= 0; i-- {
+		result.sqr(&result)
+		if (exponent[i/64]>>(uint(i)%64))&1 == 1 {
+			result.mul(&result, &base)
+		}
+	}
+	*f = result
+	return f
+}
@@ -0,0 +1,271 @@
+package ecc
+
+import (
+	"bytes"
+	"encoding/binary"
+	"fmt"
+	"math/bits"
+	"sort"
+)
+
+//BIP158 compact block filters. A filter is a Golomb-Rice coded set (GCS)
+//of the scripts a block touches. Unlike a BloomFilter the client downloads
+//it and matches locally, so the peer never learns which scripts we watch.
+
+//basic filter parameters, a false positive rate of about 1/M
+const (
+	BASICFILTERTYPE = 0
+	BASICFILTERP    = 19
+	BASICFILTERM    = 784931
+)
+
+const OPRETURN = 0x6a
+
+type GCSFilter struct {
+	n    uint32   //number of items
+	p    uint8    //Golomb-Rice parameter, the number of remainder bits
+	m    uint64   //the items hash into the range [0, n*m)
+	key  [16]byte //SipHash key
+	data []byte   //the coded set, without the leading item count
+}
+
+//bitWriter appends bits most significant first
+type bitWriter struct {
+	bytes []byte
+	count uint
+}
+
+func (w *bitWriter) writeBit(bit uint64) {
+	if w.count%8 == 0 {
+		w.bytes = append(w.bytes, 0)
+	}
+	w.bytes[len(w.bytes)-1] |= byte(bit&1) << (7 - w.count%8)
+	w.count++
+}
+
+func (w *bitWriter) writeBits(value uint64, n uint8) {
+	for i := int(n) - 1; i >= 0; i-- {
+		w.writeBit(value >> uint(i))
+	}
+}
+
+type bitReader struct {
+	bytes []byte
+	count uint
+}
+
+func (r *bitReader) readBit() (uint64, error) {
+	if r.count/8 >= uint(len(r.bytes)) {
+		return 0, fmt.Errorf("SyntaxError: %v", "filter data ended early")
+	}
+	bit := r.bytes[r.count/8] >> (7 - r.count%8) & 1
+	r.count++
+	return uint64(bit), nil
+}
+
+func (r *bitReader) readBits(n uint8) (uint64, error) {
+	var value uint64
+	for i := uint8(0); i < n; i++ {
+		bit, err := r.readBit()
+		if err != nil {
+			return 0, err
+		}
+		value = value<<1 | bit
+	}
+	return value, nil
+}
+
+//golombEncode writes x as a unary quotient and p remainder bits
+func golombEncode(w *bitWriter, x uint64, p uint8) {
+	for q := x >> p; q > 0; q-- {
+		w.writeBit(1)
+	}
+	w.writeBit(0)
+	w.writeBits(x, p)
+}
+
+func golombDecode(r *bitReader, p uint8) (uint64, error) {
+	var q uint64
+	for {
+		bit, err := r.readBit()
+		if err != nil {
+			return 0, err
+		}
+		if bit == 0 {
+			break
+		}
+		q++
+	}
+	remainder, err := r.readBits(p)
+	if err != nil {
+		return 0, err
+	}
+	return q<<p | remainder, nil
+}
+
+//hashToRange maps item uniformly into [0, f) with (siphash * f) >> 64
+func (f *GCSFilter) hashToRange(item []byte, rangeSize uint64) uint64 {
+	k0 := binary.LittleEndian.Uint64(f.key[:8])
+	k1 := binary.LittleEndian.Uint64(f.key[8:])
+	hi, _ := bits.Mul64(sipHash24(k0, k1, item), rangeSize)
+	return hi
+}
+
+//hashedSet is the sorted list of hashed items the filter codes
+func (f *GCSFilter) hashedSet(items [][]byte) []uint64 {
+	rangeSize := uint64(f.n) * f.m
+	values := make([]uint64, len(items))
+	for i, item := range items {
+		values[i] = f.hashToRange(item, rangeSize)
+	}
+	sort.Slice(values, func(i, j int) bool { return values[i] < values[j] })
+	return values
+}
+
+//NewGCSFilter codes items into a filter, duplicates are counted once
+func NewGCSFilter(key [16]byte, p uint8, m uint64, items [][]byte) (f *GCSFilter) {
+	seen := make(map[string]bool)
+	var unique [][]byte
+	for _, item := range items {
+		if !seen[string(item)] {
+			seen[string(item)] = true
+			unique = append(unique, item)
+		}
+	}
+	f = new(GCSFilter)
+	f.n = uint32(len(unique))
+	f.p = p
+	f.m = m
+	f.key = key
+	w := new(bitWriter)
+	var last uint64
+	for _, value := range f.hashedSet(unique) {
+		golombEncode(w, value-last, p)
+		last = value
+	}
+	f.data = w.bytes
+	return
+}
+
+//basicFilterKey is the SipHash key of a block's filter, the first 16 bytes
+//of the block hash in its internal byte order
+func basicFilterKey(blockHash []byte) (key [16]byte) {
+	copy(key[:], blockHash)
+	return
+}
+
+//basicFilterElements collects the scripts a basic filter covers: every
+//output script except empty and OP_RETURN ones, and the scripts of the
+//outputs the block spends. Those come from the caller since a block does
+//not carry them, the coinbase spends nothing.
+func basicFilterElements(txs []*Tx, prevScripts [][]byte) [][]byte {
+	var elements [][]byte
+	for _, tx := range txs {
+		for _, txOut := range tx.txOuts {
+			script := txOut.scriptPubkey.rawSerialize()
+			if len(script) == 0 || script[0] == OPRETURN {
+				continue
+			}
+			elements = append(elements, script)
+		}
+	}
+	for _, script := range prevScripts {
+		if len(script) > 0 {
+			elements = append(elements, script)
+		}
+	}
+	return elements
+}
+
+//NewBasicFilter builds the BIP158 basic filter of the block with the given hash
+func NewBasicFilter(blockHash []byte, txs []*Tx, prevScripts [][]byte) *GCSFilter {
+	return NewGCSFilter(basicFilterKey(blockHash), BASICFILTERP, BASICFILTERM, basicFilterElements(txs, prevScripts))
+}
+
+//parseBasicFilter reads a serialized basic filter as served by cfilter
+func parseBasicFilter(blockHash []byte, b []byte) (*GCSFilter, error) {
+	return parseGCSFilter(basicFilterKey(blockHash), BASICFILTERP, BASICFILTERM, b)
+}
+
+//parseGCSFilter reads the item count and the coded set. The data is
+//checked by decoding it, so a bad filter fails here and not in Match.
+func parseGCSFilter(key [16]byte, p uint8, m uint64, b []byte) (*GCSFilter, error) {
+	s := bytes.NewReader(b)
+	count, err := readVarint(s)
+	if err != nil {
+		return nil, err
+	}
+	data := b[len(b)-s.Len():]
+	//every item takes at least a 0 quotient bit and p remainder bits
+	if count > 1<<32-1 || count > uint64(len(data))*8/(uint64(p)+1) {
+		return nil, fmt.Errorf("ValueError: %v", "too many items for the filter data")
+	}
+	f := &GCSFilter{n: uint32(count), p: p, m: m, key: key, data: data}
+	if _, err := f.values(); err != nil {
+		return nil, err
+	}
+	return f, nil
+}
+
+func (f *GCSFilter) serialize() []byte {
+	return append(encodeVarint(int(f.n)), f.data...)
+}
+
+//values decodes the sorted hashed items
+func (f *GCSFilter) values() ([]uint64, error) {
+	r := &bitReader{bytes: f.data}
+	var values []uint64
+	var last uint64
+	for i := uint32(0); i < f.n; i++ {
+		delta, err := golombDecode(r, f.p)
+		if err != nil {
+			return nil, err
+		}
+		last += delta
+		values = append(values, last)
+	}
+	return values, nil
+}
+
+//Match reports whether item may be in the filter, false positives happen
+//about once in m queries
+func (f *GCSFilter) Match(item []byte) bool {
+	return f.MatchAny([][]byte{item})
+}
+
+//MatchAny reports whether any of items may be in the filter. It walks the
+//sorted query and the sorted set side by side, so checking a whole wallet
+//costs one pass over the filter.
+func (f *GCSFilter) MatchAny(items [][]byte) bool {
+	if f.n == 0 || len(items) == 0 {
+		return false
+	}
+	values, err := f.values()
+	if err != nil {
+		return false
+	}
+	queries := f.hashedSet(items)
+	i, j := 0, 0
+	for i < len(values) && j < len(queries) {
+		switch {
+		case values[i] == queries[j]:
+			return true
+		case values[i] < queries[j]:
+			i++
+		default:
+			j++
+		}
+	}
+	return false
+}
+
+//hash is hash256 of the serialized filter
+func (f *GCSFilter) hash() []byte {
+	return []byte(hash256(string(f.serialize())))
+}
+
+//header chains the filter to the previous block's filter header,
+//hash256(filter hash || previous header). The genesis block uses 32 zero bytes.
+func (f *GCSFilter) header(prevHeader []byte) []byte {
+	return []byte(hash256(string(append(f.hash(), prevHeader...))))
+}
@@ -0,0 +1,205 @@
+package ecc
+
+import (
+	"bytes"
+	"encoding/hex"
+	"encoding/json"
+	"os"
+	"testing"
+)
+
+//TestBIP158Vectors runs rows in the layout of BIP158's testnet-19
+//blockfilters.json. The genesis row is BIP158's own, the rest were built
+//with btcutil's gcs/builder as a second implementation, chained on from
+//genesis, to cover OP_RETURN and empty scripts, prev output scripts,
+//duplicates and empty filters. Rows of the full file drop in unchanged.
+//Block hashes and filter headers are in the json byte reversed.
+func TestBIP158Vectors(t *testing.T) {
+	raw, err := os.ReadFile("testdata/bip158.json")
+	if err != nil {
+		t.Fatal(err)
+	}
+	var rows [][]interface{}
+	if err := json.Unmarshal(raw, &rows); err != nil {
+		t.Fatal(err)
+	}
+	var lastHeader string
+	for _, row := range rows {
+		//the first row names the columns
+		if len(row) == 1 {
+			continue
+		}
+		//every row chains on the header of the one before it
+		if lastHeader != "" && row[4].(string) != lastHeader {
+			t.Fatalf("%v: previous header %s, want %s", row[0], row[4], lastHeader)
+		}
+		lastHeader = row[6].(string)
+		height := row[0].(float64)
+		s := bytes.NewReader(mustHex(row[2].(string)))
+		block, err := new(Block).parse(s)
+		if err != nil {
+			t.Fatal(err)
+		}
+		blockHash := []byte(block.hash())
+		if got := hex.EncodeToString([]byte(reverse(string(blockHash)))); got != row[1].(string) {
+			t.Fatalf("%v: block hash %s, want %s", height, got, row[1])
+		}
+		count, err := readVarint(s)
+		if err != nil {
+			t.Fatal(err)
+		}
+		var txs []*Tx
+		for i := uint64(0); i < count; i++ {
+			tx, err := new(Tx).parse(s, TESTNET3)
+			if err != nil {
+				t.Fatalf("%v: tx %d: %v", height, i, err)
+			}
+			txs = append(txs, tx)
+		}
+		var prevScripts [][]byte
+		for _, script := range row[3].([]interface{}) {
+			prevScripts = append(prevScripts, mustHex(script.(string)))
+		}
+		filter := NewBasicFilter(blockHash, txs, prevScripts)
+		if got := hex.EncodeToString(filter.serialize()); got != row[5].(string) {
+			t.Errorf("%v: filter %s, want %s", height, got, row[5])
+		}
+		if got, want := filter.hash(), []byte(hash256(string(mustHex(row[5].(string))))); !bytes.Equal(got, want) {
+			t.Errorf("%v: filter hash %x, want %x", height, got, want)
+		}
+		prevHeader := reverse(string(mustHex(row[4].(string))))
+		header := reverse(string(filter.header([]byte(prevHeader))))
+		if got := hex.EncodeToString([]byte(header)); got != row[6].(string) {
+			t.Errorf("%v: filter header %s, want %s", height, got, row[6])
+		}
+		parsed, err := parseBasicFilter(blockHash, mustHex(row[5].(string)))
+		if err != nil {
+			t.Fatalf("%v: %v", height, err)
+		}
+		if !bytes.Equal(parsed.hash(), filter.hash()) {
+			t.Errorf("%v: parsed filter hash %x, want %x", height, parsed.hash(), filter.hash())
+		}
+		elements := basicFilterElements(txs, prevScripts)
+		for _, element := range elements {
+			if !parsed.Match(element) {
+				t.Errorf("%v: parsed filter misses %x", height, element)
+			}
+		}
+		if len(elements) > 0 && !parsed.MatchAny(append([][]byte{{0x6a}}, elements[len(elements)-1])) {
+			t.Errorf("%v: MatchAny misses the last element", height)
+		}
+		//a filter with nothing in it matches nothing
+		if parsed.n == 0 && parsed.MatchAny(elements) {
+			t.Errorf("%v: empty filter matched", height)
+		}
+	}
+}
+
+//TestParseGCSFilterCount checks the item count is held to what the data
+//can code before anything is decoded or allocated
+func TestParseGCSFilterCount(t *testing.T) {
+	var key [16]byte
+	items := [][]byte{{1}, {2}, {3}, {4}, {5}}
+	filter := NewGCSFilter(key, BASICFILTERP, BASICFILTERM, items)
+	parsed, err := parseGCSFilter(key, BASICFILTERP, BASICFILTERM, filter.serialize())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if parsed.n != 5 || !parsed.MatchAny(items[2:3]) {
+		t.Errorf("parsed filter has %d items", parsed.n)
+	}
+	for _, b := range [][]byte{
+		{},
+		{0xfd, 0x01},
+		//a 4 billion item claim on 3 bytes of data
+		{0xfe, 0xff, 0xff, 0xff, 0xff, 0x9d, 0xfc, 0xa8},
+		{0xff, 0xff, 0xff, 0xff, 0xff, 0xff, 0xff, 0xff, 0xff, 0x9d, 0xfc, 0xa8},
+		//2 items need at least 40 bits
+		{0x02, 0x9d, 0xfc, 0xa8},
+		//one more item than the 5 that were coded
+		append([]byte{0x06}, filter.data...),
+	} {
+		if _, err := parseGCSFilter(key, BASICFILTERP, BASICFILTERM, b); err == nil {
+			t.Errorf("%x parsed", b)
+		}
+	}
+}
+
+func TestBasicFilterElements(t *testing.T) {
+	a := p2pkhScript(bytes.Repeat([]byte{0x11}, 20))
+	b := NewScript([]interface{}{0, bytes.Repeat([]byte{0x22}, 20)})
+	opReturn := NewScript([]interface{}{OPRETURN, []byte("memo")})
+	empty := NewScript(nil)
+	txs := []*Tx{
+		NewTx(1, nil, []*TxOut{NewTxOut(1, a), NewTxOut(0, opReturn)}, 0, MAINNET),
+		NewTx(1, nil, []*TxOut{NewTxOut(1, empty), NewTxOut(1, b), NewTxOut(1, a)}, 0, MAINNET),
+	}
+	c := []byte(p2pkhScript(bytes.Repeat([]byte{0x33}, 20)).rawSerialize())
+	prevScripts := [][]byte{c, {}, []byte(a.rawSerialize()), nil}
+	got := basicFilterElements(txs, prevScripts)
+	//outputs in block order, then the prev output scripts, empty ones and
+	//OP_RETURN dropped, duplicates left for NewGCSFilter
+	want := [][]byte{[]byte(a.rawSerialize()), []byte(b.rawSerialize()), []byte(a.rawSerialize()), c, []byte(a.rawSerialize())}
+	if len(got) != len(want) {
+		t.Fatalf("basicFilterElements gave %d elements, want %d: %x", len(got), len(want), got)
+	}
+	for i := range want {
+		if !bytes.Equal(got[i], want[i]) {
+			t.Errorf("element %d = %x, want %x", i, got[i], want[i])
+		}
+	}
+	filter := NewBasicFilter(make([]byte, 32), txs, prevScripts)
+	if filter.n != 3 {
+		t.Errorf("filter has %d items, want 3", filter.n)
+	}
+	if filter.Match([]byte(opReturn.rawSerialize())) || filter.Match([]byte{}) {
+		t.Errorf("filter matched an OP_RETURN or empty script")
+	}
+}
+
+func TestGCSFilterMatchAny(t *testing.T) {
+	var key [16]byte
+	var items, others [][]byte
+	for i := 0; i < 100; i++ {
+		items = append(items, []byte{1, byte(i)})
+		others = append(others, []byte{2, byte(i)})
+	}
+	filter := NewGCSFilter(key, BASICFILTERP, BASICFILTERM, items)
+	for i, item := range items {
+		if !filter.Match(item) {
+			t.Fatalf("Match misses item %d", i)
+		}
+	}
+	if filter.MatchAny(others) {
+		t.Errorf("MatchAny matched none of the items")
+	}
+	if filter.MatchAny(nil) {
+		t.Errorf("MatchAny matched an empty query")
+	}
+	//one hit anywhere in the query is enough, in any order
+	for _, i := range []int{0, 50, 99} {
+		query := append(append([][]byte{}, others...), items[i])
+		if !filter.MatchAny(query) {
+			t.Errorf("MatchAny misses item %d among others", i)
+		}
+		if !filter.MatchAny(append([][]byte{items[i]}, others...)) {
+			t.Errorf("MatchAny misses item %d ahead of others", i)
+		}
+	}
+	if !filter.MatchAny([][]byte{items[7], items[7]}) {
+		t.Errorf("MatchAny misses a duplicated item")
+	}
+	empty := NewGCSFilter(key, BASICFILTERP, BASICFILTERM, nil)
+	if got := hex.EncodeToString(empty.serialize()); got != "00" {
+		t.Errorf("empty filter serializes to %s", got)
+	}
+	if empty.MatchAny(items) {
+		t.Errorf("empty filter matched")
+	}
+	//a filter cut short fails to decode and matches nothing
+	short := *filter
+	short.data = short.data[:len(short.data)/2]
+	if short.MatchAny(items) {
+		t.Errorf("truncated filter matched")
+	}
+}
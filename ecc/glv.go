@@ -0,0 +1,95 @@
+package ecc
+
+import (
+	"math/big"
+)
+
+//secp256k1 has an efficiently computable endomorphism: for the cube root of
+//unity beta mod P, (x, y) -> (beta*x, y) is the same as multiplying the point
+//by lambda, a cube root of unity mod N. Splitting k into k1 + k2*lambda with
+//both halves around 128 bits long halves the number of doublings (GLV method).
+
+var glvBeta = newFieldVal(func() *big.Int {
+	beta, _ := new(big.Int).SetString("7ae96a2b657c07106e64479eac3434e99cf0497512f58995c1396c28719501ee", 16)
+	return beta
+}())
+
+var glvLambda, _ = new(big.Int).SetString("5363ad4cc05c30e0a5261c028812645a122e22ea20816678df02967c1b23bd72", 16)
+
+//the short basis of the lattice {(a, b) : a + b*lambda == 0 mod N}, b1 is negative
+var (
+	glvA1, _ = new(big.Int).SetString("3086d221a7d46bcde86c90e49284eb15", 16)
+	glvB1, _ = new(big.Int).SetString("-e4437ed6010e88286f547fa90abfe4c3", 16)
+	glvA2, _ = new(big.Int).SetString("114ca50f7a8e2f3f657c1108d9d44cfd8", 16)
+	glvB2, _ = new(big.Int).SetString("3086d221a7d46bcde86c90e49284eb15", 16)
+)
+
+//gLambdaOddMultiples is gOddMultiples with the endomorphism applied
+var gLambdaOddMultiples = endomorphismTable(gOddMultiples)
+
+//glvSplit returns k1, k2 with k == k1 + k2*lambda mod N, either may be negative
+func glvSplit(k *big.Int) (k1, k2 *big.Int) {
+	halfN := new(big.Int).Rsh(N, 1)
+	//c1 = round(b2*k/N), c2 = round(-b1*k/N)
+	c1 := new(big.Int).Mul(glvB2, k)
+	c1.Add(c1, halfN).Quo(c1, N)
+	c2 := new(big.Int).Mul(new(big.Int).Neg(glvB1), k)
+	c2.Add(c2, halfN).Quo(c2, N)
+	//k1 = k - c1*a1 - c2*a2
+	k1 = new(big.Int).Sub(k, new(big.Int).Mul(c1, glvA1))
+	k1.Sub(k1, new(big.Int).Mul(c2, glvA2))
+	//k2 = -c1*b1 - c2*b2
+	k2 = new(big.Int).Mul(c1, glvB1)
+	k2.Neg(k2).Sub(k2, new(big.Int).Mul(c2, glvB2))
+	return
+}
+
+//endomorphism sets p to lambda*q
+func (p *projPoint) endomorphism(q *projPoint) *projPoint {
+	p.x.mul(&q.x, &glvBeta)
+	p.y = q.y
+	p.z = q.z
+	return p
+}
+
+func endomorphismTable(table []projPoint) []projPoint {
+	result := make([]projPoint, len(table))
+	for i := range table {
+		result[i].endomorphism(&table[i])
+	}
+	return result
+}
+
+//signedWnaf is wnaf for negative k as well, the digits of |k| are negated
+func signedWnaf(k *big.Int, w uint) []int8 {
+	naf := wnaf(new(big.Int).Abs(k), w)
+	if k.Sign() < 0 {
+		for i := range naf {
+			naf[i] = -naf[i]
+		}
+	}
+	return naf
+}
+
+//glvTerms splits k*p into k1*p + k2*(lambda*p)
+func glvTerms(k *big.Int, table, lambdaTable []projPoint, w uint) []wnafTerm {
+	k1, k2 := glvSplit(k)
+	return []wnafTerm{
+		{signedWnaf(k1, w), table},
+		{signedWnaf(k2, w), lambdaTable},
+	}
+}
+
+//mulGLV computes k*p for any point p using the endomorphism
+func (p *projPoint) mulGLV(k *big.Int) projPoint {
+	table := oddMultiples(*p, pointWindow)
+	return interleave(glvTerms(k, table, endomorphismTable(table), pointWindow))
+}
+
+//shamirMulGLV computes u*G + v*p as the sum of four half length multiplications
+func shamirMulGLV(u, v *big.Int, p *projPoint) projPoint {
+	table := oddMultiples(*p, pointWindow)
+	terms := glvTerms(u, gOddMultiples, gLambdaOddMultiples, gWindow)
+	terms = append(terms, glvTerms(v, table, endomorphismTable(table), pointWindow)...)
+	return interleave(terms)
+}
@@ -0,0 +1,7 @@
+//go:build noglv
+// +build noglv
+
+package ecc
+
+//GLVENABLED is off in builds tagged noglv, see glv_on.go
+const GLVENABLED = false
@@ -0,0 +1,9 @@
+//go:build !noglv
+// +build !noglv
+
+package ecc
+
+//GLVENABLED switches the public (verification) multiplications over to the
+//endomorphism, build with -tags noglv to fall back to plain wNAF. Secret
+//scalars always go through the constant time ladder.
+const GLVENABLED = true
@@ -0,0 +1,49 @@
+package ecc
+
+import (
+	"math/big"
+	"testing"
+)
+
+func sameProjPoint(a, b projPoint) bool {
+	return a.toAffine().sec(false) == b.toAffine().sec(false)
+}
+
+func TestGLVSplit(t *testing.T) {
+	for i := 0; i < 100; i++ {
+		k := randomScalar(t)
+		k1, k2 := glvSplit(k)
+		sum := new(big.Int).Add(k1, new(big.Int).Mul(k2, glvLambda))
+		if mod(sum, N).Cmp(k) != 0 {
+			t.Fatalf("glvSplit(%x) = %x, %x", k, k1, k2)
+		}
+		if k1.BitLen() > 129 || k2.BitLen() > 129 {
+			t.Fatalf("glvSplit(%x) halves are too long: %d, %d bits", k, k1.BitLen(), k2.BitLen())
+		}
+	}
+}
+
+//TestGLVMatchesLadder runs the endomorphism against the plain wNAF and the
+//constant time ladder whichever way GLVENABLED is built
+func TestGLVMatchesLadder(t *testing.T) {
+	p := newProjPoint(G.Rmul2(randomScalar(t)))
+	for i := 0; i < 50; i++ {
+		k := randomScalar(t)
+		if i == 0 {
+			k = new(big.Int).Sub(N, big.NewInt(1))
+		}
+		var kBytes [32]byte
+		k.FillBytes(kBytes[:])
+		ladder := p.mulConstTime(&kBytes)
+		if glv := p.mulGLV(k); !sameProjPoint(glv, ladder) {
+			t.Fatalf("mulGLV(%x) differs from the ladder", k)
+		}
+		if plain := p.mulWNAF(k); !sameProjPoint(plain, ladder) {
+			t.Fatalf("mulWNAF(%x) differs from the ladder", k)
+		}
+		u := randomScalar(t)
+		if !sameProjPoint(shamirMulGLV(u, k, &p), shamirMul(u, k, &p)) {
+			t.Fatalf("shamirMulGLV(%x, %x) differs from shamirMul", u, k)
+		}
+	}
+}
@@ -5,11 +5,12 @@ import (
 	"crypto/sha256"
 	"encoding/binary"
 	"encoding/hex"
-	"errors"
 	"fmt"
+	"io"
 	"math"
+	"math/big"
+	"math/bits"
 	"strings"
-	"unsafe"
 
 	"github.com/btcsuite/btcutil"
 )
@@ -21,15 +22,16 @@ var BASE58ALPHABET = "123456789ABCDEFGHJKLMNPQRSTUVWXYZabcdefghijkmnopqrstuvwxyz
 var TWOWEEKS = 60 * 60 * 24 * 14
 var MAXTARGET = 65535 * math.Pow(256, 29-3)
 
+//hash160 and hash256 return the raw digest bytes as a string, not hex
 func hash160(s string) string {
-	return hex.EncodeToString(btcutil.Hash160([]byte(s)))
+	return string(btcutil.Hash160([]byte(s)))
 }
 
 func hash256(s string) string {
 	//two rounds of sha256
 	hash := sha256.Sum256([]byte(s))
 	hash = sha256.Sum256(hash[:])
-	return hex.EncodeToString(hash[:]) //convet to [] by slicicng it
+	return string(hash[:]) //convet to [] by slicicng it
 }
 
 func divmod(numerator, denominator int64) (quotient, remainder int64) {
@@ -39,29 +41,30 @@ func divmod(numerator, denominator int64) (quotient, remainder int64) {
 }
 
 func ByteArrayToInt(arr []byte) int64 {
+	//little-endian, the first byte is the lowest
 	val := int64(0)
-	size := len(arr)
-	for i := 0; i < size; i++ {
-		*(*uint8)(unsafe.Pointer(uintptr(unsafe.Pointer(&val)) + uintptr(i))) = arr[i]
+	for i := len(arr) - 1; i >= 0; i-- {
+		val = val<<8 | int64(arr[i])
 	}
 	return val
 }
 
 func encodeBase58(s string) string {
 	count := 0
-	for _, c := range s {
+	for _, c := range []byte(s) {
 		if c == 0 {
 			count += 1
 		} else {
 			break
 		}
 	}
-	num := binary.BigEndian.Uint32([]byte(s)) //bytes to int
+	num := new(big.Int).SetBytes([]byte(s)) //bytes to int
 	prefix := strings.Repeat("1", count)
 	result := ""
-	for num > 0 {
-		_, mod := divmod(int64(num), 58)
-		result = string(BASE58ALPHABET[mod]) + result
+	base, mod := big.NewInt(58), new(big.Int)
+	for num.Sign() > 0 {
+		num.DivMod(num, base, mod)
+		result = string(BASE58ALPHABET[mod.Int64()]) + result
 	}
 	return prefix + result
 }
@@ -70,87 +73,108 @@ func encodeBase58Checksum(b string) string {
 	return encodeBase58(b + hash256(b)[:4])
 }
 
-func decodeBase58(s string) string {
-	num := 0
+//decodeBase58 is the reverse of encodeBase58, leading 1s become zero bytes
+func decodeBase58(s string) ([]byte, error) {
+	count := 0
+	for count < len(s) && s[count] == '1' {
+		count += 1
+	}
+	num, base := new(big.Int), big.NewInt(58)
 	for _, c := range s {
-		num *= 58
-		num += func() int {
-			for i, val := range BASE58ALPHABET {
-				if val == c {
-					return i
-				}
-			}
-			panic(errors.New("ValueError: element not found"))
-		}()
+		i := strings.IndexRune(BASE58ALPHABET, c)
+		if i < 0 {
+			return nil, fmt.Errorf("ValueError: %q is not a base58 character", c)
+		}
+		num.Mul(num, base)
+		num.Add(num, big.NewInt(int64(i)))
 	}
-	combined := make([]byte, 25)
-	binary.BigEndian.PutUint64(combined, uint64(num)) //int to bytes
-	checksum := combined[len(combined)-4:]
-	if hash256(string(combined[:len(combined)-4]))[:4] != string(checksum) {
-		panic(
-			fmt.Errorf("bad address: %s %s", checksum, hash256(string(combined[:len(combined)-4]))[:4]),
-		)
+	return append(make([]byte, count), num.Bytes()...), nil
+}
+
+//decodeBase58Checksum decodes s and strips the 4 byte checksum after checking it
+func decodeBase58Checksum(s string) ([]byte, error) {
+	combined, err := decodeBase58(s)
+	if err != nil {
+		return nil, err
 	}
-	return string(combined[1 : len(combined)-4])
+	if len(combined) < 5 {
+		return nil, fmt.Errorf("SyntaxError: %v", "base58check string too short")
+	}
+	payload, checksum := combined[:len(combined)-4], combined[len(combined)-4:]
+	if hash256(string(payload))[:4] != string(checksum) {
+		return nil, fmt.Errorf("ValueError: bad checksum %x", checksum)
+	}
+	return payload, nil
 }
 
 func littleEndianToInt(b []byte) int64 {
 	//little_endian_to_int takes byte sequence as a little-endian number.
 	//Returns an integer
-
-	return int64(binary.BigEndian.Uint32(b)) //bytes to int
+	return ByteArrayToInt(b)
 }
 
 func intToLittleEndian(n, length int) []byte {
 	//endian_to_little_endian takes an integer and returns the little-endian
 	//byte sequence of length"
 
-	x := make([]byte, length)
+	x := make([]byte, 8)
 	binary.LittleEndian.PutUint64(x, uint64(n)) //int to bytes
-	return x
+	return x[:length]
 }
 
-func readVarint(s []byte) int64 {
+func readVarint(s *bytes.Reader) (uint64, error) {
 	//read_varint reads a variable integer from a stream
-	var byt bytes.Buffer
-	byt.Write(s)
-
-	i, _ := byt.ReadByte()
-
+	i, err := s.ReadByte()
+	if err != nil {
+		return 0, fmt.Errorf("SyntaxError: %v", "missing varint")
+	}
+	size := 0
 	if i == 253 {
 		// 0xfd means the next two bytes are the number
-		x, _ := byt.ReadBytes(2)
-		return littleEndianToInt(x)
+		size = 2
 	} else if i == 254 {
-		// 0xfe means the next two bytes are the number
-		x, _ := byt.ReadBytes(4)
-		return littleEndianToInt(x)
+		// 0xfe means the next four bytes are the number
+		size = 4
 	} else if i == 255 {
-		// 0xfe means the next two bytes are the number
-		x, _ := byt.ReadBytes(8)
-		return littleEndianToInt(x)
+		// 0xff means the next eight bytes are the number
+		size = 8
 	} else {
 		//anything else is just the integer
-		return int64(i)
+		return uint64(i), nil
 	}
+	x, err := readBytes(s, uint64(size))
+	if err != nil {
+		return 0, fmt.Errorf("SyntaxError: %v", "truncated varint")
+	}
+	return binary.LittleEndian.Uint64(append(x, make([]byte, 8-size)...)), nil
+}
 
+//readBytes reads exactly n bytes, a length past the end of the stream is an
+//error before anything is allocated
+func readBytes(s *bytes.Reader, n uint64) ([]byte, error) {
+	if n > uint64(s.Len()) {
+		return nil, fmt.Errorf("SyntaxError: %v", "unexpected end of stream")
+	}
+	b := make([]byte, n)
+	if _, err := io.ReadFull(s, b); err != nil {
+		return nil, err
+	}
+	return b, nil
 }
 
 func encodeVarint(i int) []byte {
-	//encodes an integer as a varint
-	if i < 253 {
-		bs := make([]byte, 4)
-		binary.BigEndian.PutUint32(bs, uint32(i)) //not so sure big or little
-		return bs
+	//encodes an integer as a varint, the prefixes are raw bytes
+	//0xfd, 0xfe and 0xff followed by 2, 4 or 8 little-endian bytes
+	if i < 0 {
+		panic(fmt.Errorf("ValueError: negative varint %d", i))
+	} else if i < 253 {
+		return []byte{byte(i)}
 	} else if i < 65536 {
-		return append([]byte("\u00fd"), intToLittleEndian(i, 2)...)
-	} else if i < 4294967296 {
-		return append([]byte("\u00fe"), intToLittleEndian(i, 4)...)
-	} else if i < 18446744073709551616 {
-		return append([]byte("\u00ff"), intToLittleEndian(i, 8)...)
-	} else {
-		panic(fmt.Errorf("ValueError: %v", "integer too large: %d", i))
+		return append([]byte{0xfd}, intToLittleEndian(i, 2)...)
+	} else if uint64(i) < 4294967296 {
+		return append([]byte{0xfe}, intToLittleEndian(i, 4)...)
 	}
+	return append([]byte{0xff}, intToLittleEndian(i, 8)...)
 }
 
 func targetToBits(target int) []byte {
@@ -230,15 +254,14 @@ func bitFieldToBytes(bitField []int) []byte {
 			),
 		)
 	}
-	result := make([]float64, 2)
-	result = append(result, math.Floor(len(float64(bitField))/float64(8))...)
+	result := make([]byte, len(bitField)/8)
 	for i, bit := range bitField {
-		byte_index, bit_index := divmod(int64(i), 8)
+		byteIndex, bitIndex := divmod(int64(i), 8)
 		if bit == 1 {
-			result[byte_index] |= 1 << bit_index
+			result[byteIndex] |= 1 << uint(bitIndex)
 		}
 	}
-	return []byte(result)
+	return result
 }
 
 func bytesToBitField(someBytes []byte) []int {
@@ -252,56 +275,99 @@ func bytesToBitField(someBytes []byte) []int {
 	return flagBits
 }
 
-func murmur3(data []int, seed int) int {
-	//"from http://stackoverflow.com/questions/13305290/is-there-a-pure-python-implementation-of-murmurhash"
-	c1 := 3432918353
-	c2 := 461845907
-	length := len(data)
+//murmur3 is MurmurHash3 x86_32, the hash BIP37 filters use. All the
+//arithmetic is on uint32 so it wraps exactly like the reference code.
+func murmur3(data []byte, seed uint32) uint32 {
+	const c1, c2 = 0xcc9e2d51, 0x1b873593
 	h1 := seed
-	roundedEnd := length & 4294967292
+	roundedEnd := len(data) &^ 3
 	for i := 0; i < roundedEnd; i += 4 {
-		k1 := data[i]&255 | data[i+1]&255<<8 | data[i+2]&255<<16 | data[i+3]<<24
+		k1 := binary.LittleEndian.Uint32(data[i : i+4])
 		k1 *= c1
-		k1 = k1<<15 | k1&4294967295>>17
+		k1 = bits.RotateLeft32(k1, 15)
 		k1 *= c2
 		h1 ^= k1
-		h1 = int(h1)<<13 | int(h1)&4294967295>>19
-		h1 = int(h1)*5 + 3864292196
-	}
-	k1 := 0
-	val := length & 3
-	if val == 3 {
-		k1 = data[roundedEnd+2] & 255 << 16
+		h1 = bits.RotateLeft32(h1, 13)
+		h1 = h1*5 + 0xe6546b64
 	}
-	if func() int {
-		for i, v := range []int{2, 3} {
-			if v == val {
-				return i
-			}
-		}
-		return -1
-	}() != -1 {
-		k1 |= data[roundedEnd+1] & 255 << 8
-	}
-	if func() int {
-		for i, v := range []int{1, 2, 3} {
-			if v == val {
-				return i
-			}
-		}
-		return -1
-	}() != -1 {
-		k1 |= data[roundedEnd] & 255
+	//the last 1 to 3 bytes
+	var k1 uint32
+	switch len(data) & 3 {
+	case 3:
+		k1 ^= uint32(data[roundedEnd+2]) << 16
+		fallthrough
+	case 2:
+		k1 ^= uint32(data[roundedEnd+1]) << 8
+		fallthrough
+	case 1:
+		k1 ^= uint32(data[roundedEnd])
 		k1 *= c1
-		k1 = k1<<15 | k1&4294967295>>17
+		k1 = bits.RotateLeft32(k1, 15)
 		k1 *= c2
 		h1 ^= k1
 	}
-	h1 ^= length
-	h1 ^= int(h1) & 4294967295 >> 16
-	h1 *= 2246822507
-	h1 ^= int(h1) & 4294967295 >> 13
-	h1 *= 3266489909
-	h1 ^= int(h1) & 4294967295 >> 16
-	return int(h1) & 4294967295
+	//finalization mix
+	h1 ^= uint32(len(data))
+	h1 ^= h1 >> 16
+	h1 *= 0x85ebca6b
+	h1 ^= h1 >> 13
+	h1 *= 0xc2b2ae35
+	h1 ^= h1 >> 16
+	return h1
+}
+
+//sipHash24 is SipHash-2-4 with the 128 bit key k0, k1, the hash BIP158
+//filters use to map items into their range
+func sipHash24(k0, k1 uint64, data []byte) uint64 {
+	v0 := k0 ^ 0x736f6d6570736575
+	v1 := k1 ^ 0x646f72616e646f6d
+	v2 := k0 ^ 0x6c7967656e657261
+	v3 := k1 ^ 0x7465646279746573
+	round := func() {
+		v0 += v1
+		v1 = bits.RotateLeft64(v1, 13)
+		v1 ^= v0
+		v0 = bits.RotateLeft64(v0, 32)
+		v2 += v3
+		v3 = bits.RotateLeft64(v3, 16)
+		v3 ^= v2
+		v0 += v3
+		v3 = bits.RotateLeft64(v3, 21)
+		v3 ^= v0
+		v2 += v1
+		v1 = bits.RotateLeft64(v1, 17)
+		v1 ^= v2
+		v2 = bits.RotateLeft64(v2, 32)
+	}
+	roundedEnd := len(data) &^ 7
+	for i := 0; i < roundedEnd; i += 8 {
+		m := binary.LittleEndian.Uint64(data[i : i+8])
+		v3 ^= m
+		round()
+		round()
+		v0 ^= m
+	}
+	//the last block holds the leftover bytes and the length in its top byte
+	m := uint64(len(data)) << 56
+	for i, b := range data[roundedEnd:] {
+		m |= uint64(b) << (8 * uint(i))
+	}
+	v3 ^= m
+	round()
+	round()
+	v0 ^= m
+	v2 ^= 0xff
+	for i := 0; i < 4; i++ {
+		round()
+	}
+	return v0 ^ v1 ^ v2 ^ v3
+}
+
+//mustHex decodes a hex constant, it is for fixed values and test vectors
+func mustHex(s string) []byte {
+	b, err := hex.DecodeString(s)
+	if err != nil {
+		panic(err)
+	}
+	return b
 }
@@ -149,7 +149,7 @@ func (Mt *MerkleTree) populateTree(flagBits []int, hashes []string) {
 		}
 	}
 	if len(hashes) != 0 {
-		panic(fmt.Errorf("RuntimeError: %v", "hashes not all consumed {}", len(hashes)))
+		panic(fmt.Errorf("RuntimeError: %d hashes not all consumed", len(hashes)))
 	}
 	for _, flag_bit := range flagBits {
 		if flag_bit != 0 {
@@ -199,30 +199,42 @@ func (Mb *MerkleBlock) Repr() string {
 	return result + fmt.Sprintf("{%x}", Mb.flags)
 }
 
-func (Mb *MerkleBlock) parse(s []byte) *MerkleBlock {
-	var byt bytes.Buffer
-	byt.Write(s)
-	a, _ := byt.ReadBytes(4)
-	version := littleEndianToInt(a)
-	b, _ := byt.ReadBytes(32)
-	prevBlock := reverse(string(b))
-	c, _ := byt.ReadBytes(32)
-	merkleRoot := reverse(string(c))
-	d, _ := byt.ReadBytes(4)
-	timestamp := littleEndianToInt(d)
-	bits, _ := byt.ReadBytes(4)
-	nonce, _ := byt.ReadBytes(4)
-	e, _ := byt.ReadBytes(4)
-	total := littleEndianToInt(e)
-	numHashes := readVarint(s)
+func (Mb *MerkleBlock) parse(s *bytes.Reader) (*MerkleBlock, error) {
+	header, err := readBytes(s, 84)
+	if err != nil {
+		return nil, err
+	}
+	version := littleEndianToInt(header[:4])
+	prevBlock := reverse(string(header[4:36]))
+	merkleRoot := reverse(string(header[36:68]))
+	timestamp := littleEndianToInt(header[68:72])
+	bits := header[72:76]
+	nonce := header[76:80]
+	total := littleEndianToInt(header[80:84])
+	numHashes, err := readVarint(s)
+	if err != nil {
+		return nil, err
+	}
+	if numHashes > uint64(s.Len()/32) {
+		return nil, fmt.Errorf("SyntaxError: %v", "too many hashes")
+	}
 	var hashes []string
-	for i := 0; i < int(numHashes); i++ {
-		x, _ := byt.ReadBytes(32)
+	for i := uint64(0); i < numHashes; i++ {
+		x, err := readBytes(s, 32)
+		if err != nil {
+			return nil, err
+		}
 		hashes = append(hashes, reverse(string(x)))
 	}
-	flagsLength := readVarint(s)
-	flags, _ := byt.ReadBytes(byte(flagsLength))
-	return NewMerkleBlock(version, prevBlock, merkleRoot, timestamp, bits, nonce, int(total), hashes, flags)
+	flagsLength, err := readVarint(s)
+	if err != nil {
+		return nil, err
+	}
+	flags, err := readBytes(s, flagsLength)
+	if err != nil {
+		return nil, err
+	}
+	return NewMerkleBlock(version, prevBlock, merkleRoot, timestamp, bits, nonce, int(total), hashes, flags), nil
 }
 
 func (Mb *MerkleBlock) isValid() bool {
@@ -0,0 +1,289 @@
+package ecc
+
+import (
+	"bytes"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"fmt"
+	"math/big"
+	"strings"
+)
+
+//MESSAGEMAGIC is prepended to every message signed the legacy (BIP137) way
+var MESSAGEMAGIC = "Bitcoin Signed Message:\n"
+
+//varstr is a varint length followed by the bytes
+func varstr(b []byte) []byte {
+	return append(encodeVarint(len(b)), b...)
+}
+
+//legacyMessageHash is hash256(varstr(magic) + varstr(message)) as an integer
+func legacyMessageHash(message string) *big.Int {
+	payload := append(varstr([]byte(MESSAGEMAGIC)), varstr([]byte(message))...)
+	return new(big.Int).SetBytes([]byte(hash256(string(payload))))
+}
+
+//SignMessage signs message for the p2pkh address of the key (BIP137) and
+//returns that address with the base64 compact signature
+func SignMessage(privateKey *PrivateKey, message string, compressed bool, net *NetworkParams) (string, string) {
+	sig := privateKey.sign(legacyMessageHash(message), false)
+	address := privateKey.point.address(compressed, net)
+	return address, base64.StdEncoding.EncodeToString(sig.compact(compressed))
+}
+
+//VerifyMessage checks a BIP137 signature made by a p2pkh address. The public
+//key is recovered from the signature and must hash to the given address.
+//Segwit addresses are handed on to VerifyMessageBIP322.
+func VerifyMessage(address string, message string, signature string) (bool, error) {
+	for _, net := range NETWORKS {
+		if !strings.HasPrefix(strings.ToLower(address), net.bech32Hrp+"1") {
+			continue
+		}
+		version, program, err := decodeSegwitAddress(net.bech32Hrp, address)
+		if err != nil {
+			return false, err
+		}
+		return VerifyMessageBIP322(segwitScript(version, program), message, signature)
+	}
+	net, addressType, _, err := decodeAddress(address)
+	if err != nil {
+		return false, err
+	}
+	if addressType != P2PKH {
+		return false, fmt.Errorf("ValueError: %v", "BIP137 signatures need a p2pkh address")
+	}
+	raw, err := base64.StdEncoding.DecodeString(signature)
+	if err != nil {
+		return false, fmt.Errorf("SyntaxError: signature is not base64: %v", err)
+	}
+	sig, compressed, err := new(Signature).parseCompact(raw)
+	if err != nil {
+		return false, err
+	}
+	point, err := recoverPubkey(legacyMessageHash(message), sig)
+	if err != nil {
+		return false, nil
+	}
+	return address == point.address(compressed, net), nil
+}
+
+//BIP322 "simple" signatures sign a virtual transaction spending the
+//address' own ScriptPubKey and only ship the witness stack. p2wpkh, p2wsh
+//and the key path of p2tr are supported.
+
+func bip322MessageHash(message string) []byte {
+	h := taggedHash("BIP0322-signed-message", []byte(message))
+	return h[:]
+}
+
+//p2wpkhScript is OP_0 <20 byte hash>
+func p2wpkhScript(h160 []byte) []byte {
+	return append([]byte{0x00, 0x14}, h160...)
+}
+
+//bip322ToSpend is the serialized to_spend transaction: version 0, one input
+//spending 00..00:0xffffffff with ScriptSig OP_0 PUSH32[message_hash] and
+//sequence 0, one output of 0 sats to the ScriptPubKey and locktime 0
+func bip322ToSpend(message string, scriptPubkey []byte) []byte {
+	result := intToLittleEndian(0, 4)
+	result = append(result, encodeVarint(1)...)
+	result = append(result, make([]byte, 32)...)
+	result = append(result, intToLittleEndian(0xffffffff, 4)...)
+	result = append(result, varstr(append([]byte{0x00, 0x20}, bip322MessageHash(message)...))...)
+	result = append(result, intToLittleEndian(0, 4)...)
+	result = append(result, encodeVarint(1)...)
+	result = append(result, intToLittleEndian(0, 8)...)
+	result = append(result, varstr(scriptPubkey)...)
+	return append(result, intToLittleEndian(0, 4)...)
+}
+
+//bip322ToSign is the unsigned to_sign transaction: version 0, spending
+//output 0 of to_spend with sequence 0 into a single 0 sat OP_RETURN output
+func bip322ToSign(message string, scriptPubkey []byte) *Tx {
+	toSpendId := hash256(string(bip322ToSpend(message, scriptPubkey)))
+	txIn := NewTxIn([]byte(toSpendId), 0, nil, 0)
+	txOut := NewTxOut(0, NewScript([]interface{}{106}))
+	return NewTx(0, []*TxIn{txIn}, []*TxOut{txOut}, 0, nil)
+}
+
+//bip322SigHash is the BIP143 SIGHASH_ALL hash of to_sign for a p2wpkh key
+func bip322SigHash(message string, h160 []byte) *big.Int {
+	toSign := bip322ToSign(message, p2wpkhScript(h160))
+	//the scriptCode of p2wpkh is the p2pkh ScriptPubKey
+	return toSign.sigHashBip143(0, p2pkhScript(h160).rawSerialize(), 0)
+}
+
+//bip322TaprootSigHash is the BIP341 key path hash of to_sign
+func bip322TaprootSigHash(message string, scriptPubkey []byte, hashType byte) ([]byte, error) {
+	toSign := bip322ToSign(message, scriptPubkey)
+	return toSign.sigHashBip341(0, []int64{0}, [][]byte{scriptPubkey}, hashType)
+}
+
+//serializeWitness is the item count then each item as a varstr
+func serializeWitness(items [][]byte) []byte {
+	result := encodeVarint(len(items))
+	for _, item := range items {
+		result = append(result, varstr(item)...)
+	}
+	return result
+}
+
+func parseWitness(raw []byte) ([][]byte, error) {
+	stream := bytes.NewReader(raw)
+	count, err := readVarint(stream)
+	if err != nil {
+		return nil, err
+	}
+	if count > uint64(stream.Len()) {
+		return nil, fmt.Errorf("SyntaxError: %v", "too many witness items")
+	}
+	items := make([][]byte, 0, count)
+	for i := uint64(0); i < count; i++ {
+		length, err := readVarint(stream)
+		if err != nil {
+			return nil, err
+		}
+		item, err := readBytes(stream, length)
+		if err != nil {
+			return nil, fmt.Errorf("SyntaxError: %v", "witness item too short")
+		}
+		items = append(items, item)
+	}
+	if stream.Len() != 0 {
+		return nil, fmt.Errorf("SyntaxError: %v", "trailing bytes after the witness")
+	}
+	return items, nil
+}
+
+//SignMessageBIP322 signs message for the p2wpkh output of the compressed key,
+//it returns that ScriptPubKey and the base64 encoded witness stack
+func SignMessageBIP322(privateKey *PrivateKey, message string) ([]byte, string) {
+	h160 := []byte(privateKey.point.hash160(true))
+	sig := privateKey.sign(bip322SigHash(message, h160), true)
+	witness := serializeWitness([][]byte{
+		append([]byte(sig.der()), byte(SIGHASHALL)),
+		[]byte(privateKey.point.sec(true)),
+	})
+	return p2wpkhScript(h160), base64.StdEncoding.EncodeToString(witness)
+}
+
+//SignMessageBIP322Taproot signs message with the key path of the key's p2tr
+//output without a script tree, it returns that ScriptPubKey and the base64
+//encoded witness stack
+func SignMessageBIP322Taproot(privateKey *PrivateKey, message string) ([]byte, string, error) {
+	tweaked, err := privateKey.taprootTweak(nil)
+	if err != nil {
+		return nil, "", err
+	}
+	scriptPubkey := segwitScript(1, tweaked.point.xonly())
+	msg, err := bip322TaprootSigHash(message, scriptPubkey, 0)
+	if err != nil {
+		return nil, "", err
+	}
+	auxRand := make([]byte, 32)
+	if _, err := rand.Read(auxRand); err != nil {
+		return nil, "", err
+	}
+	sig := tweaked.signSchnorr(msg, auxRand)
+	witness := serializeWitness([][]byte{sig.serialize()})
+	return scriptPubkey, base64.StdEncoding.EncodeToString(witness), nil
+}
+
+//VerifyMessageBIP322 checks a BIP322 simple signature for a p2wpkh, p2wsh or
+//p2tr ScriptPubKey. p2tr signatures have to use the key path.
+func VerifyMessageBIP322(scriptPubkey []byte, message string, signature string) (bool, error) {
+	var verify func([]byte, string, [][]byte) (bool, error)
+	switch {
+	case len(scriptPubkey) == 22 && scriptPubkey[0] == 0x00 && scriptPubkey[1] == 0x14:
+		verify = verifyBIP322P2wpkh
+	case len(scriptPubkey) == 34 && scriptPubkey[0] == 0x00 && scriptPubkey[1] == 0x20:
+		verify = verifyBIP322P2wsh
+	case len(scriptPubkey) == 34 && scriptPubkey[0] == 0x51 && scriptPubkey[1] == 0x20:
+		verify = verifyBIP322P2tr
+	default:
+		return false, fmt.Errorf("ValueError: %v", "only p2wpkh, p2wsh and p2tr ScriptPubKeys are supported")
+	}
+	raw, err := base64.StdEncoding.DecodeString(signature)
+	if err != nil {
+		return false, fmt.Errorf("SyntaxError: signature is not base64: %v", err)
+	}
+	witness, err := parseWitness(raw)
+	if err != nil {
+		return false, err
+	}
+	return verify(scriptPubkey, message, witness)
+}
+
+//verifyBIP322P2wpkh takes the witness [sig, sec]
+func verifyBIP322P2wpkh(scriptPubkey []byte, message string, witness [][]byte) (bool, error) {
+	if len(witness) != 2 {
+		return false, fmt.Errorf("SyntaxError: %v", "p2wpkh witness must have two items")
+	}
+	sigBin, sec := witness[0], witness[1]
+	if len(sigBin) < 1 || int(sigBin[len(sigBin)-1]) != SIGHASHALL {
+		return false, nil
+	}
+	sig, err := new(Signature).parse(sigBin[:len(sigBin)-1])
+	if err != nil {
+		return false, nil
+	}
+	//segwit v0 only allows compressed keys
+	if len(sec) != 33 || hash160(string(sec)) != string(scriptPubkey[2:]) {
+		return false, nil
+	}
+	point, err := parseSec(sec)
+	if err != nil {
+		return false, nil
+	}
+	return point.verify(bip322SigHash(message, scriptPubkey[2:]), sig), nil
+}
+
+//verifyBIP322P2wsh takes the witness items with the WitnessScript last and
+//runs the WitnessScript on the others
+func verifyBIP322P2wsh(scriptPubkey []byte, message string, witness [][]byte) (bool, error) {
+	if len(witness) == 0 {
+		return false, fmt.Errorf("SyntaxError: %v", "p2wsh witness needs the WitnessScript")
+	}
+	witnessScript := witness[len(witness)-1]
+	h := sha256.Sum256(witnessScript)
+	if !bytes.Equal(h[:], scriptPubkey[2:]) {
+		return false, nil
+	}
+	script, err := new(Script).parse(bytes.NewReader(varstr(witnessScript)))
+	if err != nil {
+		return false, nil
+	}
+	toSign := bip322ToSign(message, scriptPubkey)
+	z := toSign.sigHashBip143(0, witnessScript, 0)
+	lock := &LockContext{locktime: toSign.locktime, sequence: toSign.txIns[0].sequence, version: toSign.version}
+	stack, ok := script.run(witness[:len(witness)-1], z, lock)
+	//segwit scripts have to leave exactly one true element
+	return ok && len(stack) == 1 && castToBool(stack[0]), nil
+}
+
+//verifyBIP322P2tr takes the key path witness, a 64 byte signature for
+//SIGHASH_DEFAULT or 65 bytes ending in SIGHASH_ALL
+func verifyBIP322P2tr(scriptPubkey []byte, message string, witness [][]byte) (bool, error) {
+	if len(witness) != 1 {
+		return false, fmt.Errorf("ValueError: %v", "only p2tr key path signatures are supported")
+	}
+	sigBin := witness[0]
+	hashType := byte(0)
+	if len(sigBin) == 65 {
+		hashType = sigBin[64]
+		//an explicit SIGHASH_DEFAULT byte is not allowed
+		if hashType != byte(SIGHASHALL) {
+			return false, nil
+		}
+		sigBin = sigBin[:64]
+	}
+	if len(sigBin) != 64 {
+		return false, nil
+	}
+	msg, err := bip322TaprootSigHash(message, scriptPubkey, hashType)
+	if err != nil {
+		return false, err
+	}
+	return verifySchnorr(scriptPubkey[2:], msg, new(SchnorrSignature).parse(sigBin)), nil
+}
@@ -0,0 +1,184 @@
+package ecc
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"math/big"
+	"testing"
+)
+
+//the key of the BIP322 test vectors
+const bip322Wif = "L3VFeEujGtevx9w18HD1fhRbCH67Az2dpCymeRE1SoPK6XQtaN2k"
+
+func TestLegacyMessage(t *testing.T) {
+	//Bitcoin Core's rpc_signmessage.py
+	privateKey, compressed, net, err := parseWIF("cUeKHd5orzT3mz8P9pxyREHfsWtVfgsfDjiZZBcjUBAaGk1BTj7N")
+	if err != nil {
+		t.Fatal(err)
+	}
+	message := "This is just a test message"
+	want := "INbVnW4e6PeRmsv2Qgu8NuopvrVjkcxob+sX8OcZG0SALhWybUjzMLPdAsXI46YZGb0KQTRii+wWIQzRpG/U+S0="
+	address, signature := SignMessage(privateKey, message, compressed, net)
+	if address != "mpLQjfK79b7CCV4VMJWEWAj5Mpx8Up5zxB" || signature != want {
+		t.Fatalf("got %s %s", address, signature)
+	}
+	if ok, err := VerifyMessage(address, message, want); !ok || err != nil {
+		t.Fatalf("verify %v %v", ok, err)
+	}
+	if ok, _ := VerifyMessage(address, message+".", want); ok {
+		t.Fatal("verified another message")
+	}
+	//the address of another key
+	if ok, _ := VerifyMessage(NewPrivateKey(big.NewInt(2)).point.address(true, net), message, want); ok {
+		t.Fatal("verified for another address")
+	}
+}
+
+func TestBIP322MessageHash(t *testing.T) {
+	tests := map[string]string{
+		"":            "c90c269c4f8fcbe6880f72a721ddfbf1914268a794cbb21cfafee13770ae19f1",
+		"Hello World": "f0eb03b1a75ac6d9847f55c624a99169b5dccba2a31f5b23bea77ba270de0a7a",
+	}
+	for message, want := range tests {
+		if got := hex.EncodeToString(bip322MessageHash(message)); got != want {
+			t.Errorf("%q: %s", message, got)
+		}
+	}
+}
+
+func TestBIP322Transactions(t *testing.T) {
+	//to_spend and to_sign ids for bc1q9vza2e8x573nczrlzms0wvx3gsqjx7vavgkx0l
+	scriptPubkey := mustHex("00142b05d564e6a7a33c087f16e0f730d1440123799d")
+	tests := []struct {
+		message, toSpend, toSign string
+	}{
+		{"", "c5680aa69bb8d860bf82d4e9cd3504b55dde018de765a91bb566283c545a99a7", "1e9654e951a5ba44c8604c4de6c67fd78a27e81dcadcfe1edf638ba3aaebaed6"},
+		{"Hello World", "b79d196740ad5217771c1098fc4a4b51e0535c32236c71f1ea4d61a2d603352b", "88737ae86f2077145f93cc4b153ae9a1cb8d56afa511988c149c5c8c9d93bddf"},
+	}
+	for _, test := range tests {
+		toSpend := hex.EncodeToString([]byte(reverse(hash256(string(bip322ToSpend(test.message, scriptPubkey))))))
+		if toSpend != test.toSpend {
+			t.Errorf("%q to_spend %s", test.message, toSpend)
+		}
+		if toSign := bip322ToSign(test.message, scriptPubkey).id(); toSign != test.toSign {
+			t.Errorf("%q to_sign %s", test.message, toSign)
+		}
+	}
+}
+
+func TestBIP322P2wpkh(t *testing.T) {
+	privateKey, _, _, err := parseWIF(bip322Wif)
+	if err != nil {
+		t.Fatal(err)
+	}
+	address := "bc1q9vza2e8x573nczrlzms0wvx3gsqjx7vavgkx0l"
+	tests := map[string]string{
+		"":            "AkcwRAIgM2gBAQqvZX15ZiysmKmQpDrG83avLIT492QBzLnQIxYCIBaTpOaD20qRlEylyxFSeEA2ba9YOixpX8z46TSDtS40ASECx/EgAxlkQpQ9hYjgGu6EBCPMVPwVIVJqO4XCsMvViHI=",
+		"Hello World": "AkcwRAIgZRfIY3p7/DoVTty6YZbWS71bc5Vct9p9Fia83eRmw2QCICK/ENGfwLtptFluMGs2KsqoNSk89pO7F29zJLUx9a/sASECx/EgAxlkQpQ9hYjgGu6EBCPMVPwVIVJqO4XCsMvViHI=",
+	}
+	for message, want := range tests {
+		scriptPubkey, signature := SignMessageBIP322(privateKey, message)
+		if hex.EncodeToString(scriptPubkey) != "00142b05d564e6a7a33c087f16e0f730d1440123799d" || signature != want {
+			t.Errorf("%q signed %x %s", message, scriptPubkey, signature)
+		}
+		if ok, err := VerifyMessage(address, message, want); !ok || err != nil {
+			t.Errorf("%q verify %v %v", message, ok, err)
+		}
+	}
+	//the signatures swapped
+	if ok, _ := VerifyMessage(address, "Hello World", tests[""]); ok {
+		t.Fatal("verified the signature of another message")
+	}
+}
+
+func TestBIP322P2tr(t *testing.T) {
+	privateKey, _, _, err := parseWIF(bip322Wif)
+	if err != nil {
+		t.Fatal(err)
+	}
+	address := "bc1ppv609nr0vr25u07u95waq5lucwfm6tde4nydujnu8npg4q75mr5sxq8lt3"
+	if got, err := privateKey.point.p2trAddress(MAINNET, nil); err != nil || got != address {
+		t.Fatalf("address %s %v", got, err)
+	}
+	//a 65 byte signature with SIGHASH_ALL
+	signature := "AUHd69PrJQEv+oKTfZ8l+WROBHuy9HKrbFCJu7U1iK2iiEy1vMU5EfMtjc+VSHM7aU0SDbak5IUZRVno2P5mjSafAQ=="
+	if ok, err := VerifyMessage(address, "Hello World", signature); !ok || err != nil {
+		t.Fatalf("verify %v %v", ok, err)
+	}
+	if ok, _ := VerifyMessage(address, "", signature); ok {
+		t.Fatal("verified another message")
+	}
+	//our own signatures use SIGHASH_DEFAULT and random aux data
+	scriptPubkey, signature, err := SignMessageBIP322Taproot(privateKey, "Hello World")
+	if err != nil {
+		t.Fatal(err)
+	}
+	raw, _ := base64.StdEncoding.DecodeString(signature)
+	if len(raw) != 66 {
+		t.Fatalf("witness %x", raw)
+	}
+	if ok, err := VerifyMessageBIP322(scriptPubkey, "Hello World", signature); !ok || err != nil {
+		t.Fatalf("verify own %v %v", ok, err)
+	}
+	//an explicit SIGHASH_DEFAULT byte is not allowed
+	raw = append([]byte{1, 65}, append(raw[2:], 0)...)
+	if ok, _ := VerifyMessageBIP322(scriptPubkey, "Hello World", base64.StdEncoding.EncodeToString(raw)); ok {
+		t.Fatal("verified with a SIGHASH_DEFAULT byte")
+	}
+}
+
+func TestBIP322P2wsh(t *testing.T) {
+	//2-of-2 multisig WitnessScript
+	key1 := NewPrivateKey(big.NewInt(0x1111))
+	key2 := NewPrivateKey(big.NewInt(0x2222))
+	witnessScript := NewScript([]interface{}{82, []byte(key1.point.sec(true)), []byte(key2.point.sec(true)), 82, 174}).rawSerialize()
+	h := sha256.Sum256(witnessScript)
+	scriptPubkey := segwitScript(0, h[:])
+	address, err := p2wshAddress(MAINNET, witnessScript)
+	if err != nil {
+		t.Fatal(err)
+	}
+	message := "Hello World"
+	z := bip322ToSign(message, scriptPubkey).sigHashBip143(0, witnessScript, 0)
+	sig := func(key *PrivateKey) []byte {
+		return append([]byte(key.sign(z, true).der()), byte(SIGHASHALL))
+	}
+	signature := base64.StdEncoding.EncodeToString(serializeWitness([][]byte{{}, sig(key1), sig(key2), witnessScript}))
+	if ok, err := VerifyMessage(address, message, signature); !ok || err != nil {
+		t.Fatalf("verify %v %v", ok, err)
+	}
+	if ok, _ := VerifyMessage(address, "", signature); ok {
+		t.Fatal("verified another message")
+	}
+	//signatures out of key order fail
+	swapped := base64.StdEncoding.EncodeToString(serializeWitness([][]byte{{}, sig(key2), sig(key1), witnessScript}))
+	if ok, _ := VerifyMessage(address, message, swapped); ok {
+		t.Fatal("verified swapped signatures")
+	}
+	//an extra item breaks the clean stack rule
+	extra := base64.StdEncoding.EncodeToString(serializeWitness([][]byte{{1}, {}, sig(key1), sig(key2), witnessScript}))
+	if ok, _ := VerifyMessage(address, message, extra); ok {
+		t.Fatal("verified with an extra stack item")
+	}
+	//a WitnessScript that is not the one committed to
+	other := append(append([]byte{}, witnessScript[:len(witnessScript)-1]...), 175, 81)
+	wrong := base64.StdEncoding.EncodeToString(serializeWitness([][]byte{{}, sig(key1), sig(key2), other}))
+	if ok, _ := VerifyMessage(address, message, wrong); ok {
+		t.Fatal("verified another WitnessScript")
+	}
+}
+
+func TestParseWitness(t *testing.T) {
+	items := [][]byte{{}, bytes.Repeat([]byte{1}, 300), {2}}
+	parsed, err := parseWitness(serializeWitness(items))
+	if err != nil || len(parsed) != 3 || !bytes.Equal(parsed[1], items[1]) {
+		t.Fatalf("round trip %v", err)
+	}
+	for _, bad := range []string{"", "01", "0102aa", "ff", "0100ff"} {
+		if _, err := parseWitness(mustHex(bad)); err == nil {
+			t.Errorf("%s parsed", bad)
+		}
+	}
+}
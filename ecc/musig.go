@@ -0,0 +1,544 @@
+package ecc
+
+import (
+	"bytes"
+	"crypto/rand"
+	"encoding/binary"
+	"fmt"
+	"math/big"
+	"sort"
+)
+
+//BIP327 MuSig2. Every signer holds a key, the aggregate key of all of them
+//looks like any other BIP340 key and the final signature is a plain 64 byte
+//BIP340 signature. Public keys are 33 byte compressed sec, public nonces are
+//two of those and a secret nonce is k1 || k2 || our public key.
+
+//KeyAggContext is the aggregate key Q with the accumulated tweak state, gacc
+//is the sign Q was multiplied by and tacc the sum of the tweaks added to it
+type KeyAggContext struct {
+	q    *S256Point
+	gacc *big.Int
+	tacc *big.Int
+}
+
+//cpoint parses a compressed public key, uncompressed ones are not allowed
+func cpoint(b []byte) (*S256Point, error) {
+	if len(b) != 33 {
+		return nil, fmt.Errorf("ValueError: invalid public key %x", b)
+	}
+	point, err := parseSec(b)
+	if err != nil {
+		return nil, fmt.Errorf("ValueError: invalid public key %x", b)
+	}
+	return point, nil
+}
+
+//cpointExt also accepts 33 zero bytes as the point at infinity
+func cpointExt(b []byte) (*S256Point, error) {
+	if bytes.Equal(b, make([]byte, 33)) {
+		return NewS256Point(nil, nil), nil
+	}
+	return cpoint(b)
+}
+
+func cbytesExt(point *S256Point) []byte {
+	if point.isInfinity() {
+		return make([]byte, 33)
+	}
+	return []byte(point.sec(true))
+}
+
+func negatePoint(point *S256Point) *S256Point {
+	if point.isInfinity() {
+		return point
+	}
+	return NewS256Point(point.x.num, mod(new(big.Int).Neg(point.y.num), P))
+}
+
+//keySort returns the public keys in lexicographic order, sorting is
+//optional but lets signers agree on the aggregate key without coordinating
+func keySort(pubkeys [][]byte) [][]byte {
+	sorted := append([][]byte{}, pubkeys...)
+	sort.Slice(sorted, func(i, j int) bool { return bytes.Compare(sorted[i], sorted[j]) < 0 })
+	return sorted
+}
+
+//keyAggCoeff is the coefficient a_i of pubkey, the second distinct key in
+//the list gets 1 which saves a multiplication when aggregating
+func keyAggCoeff(pubkeys [][]byte, pubkey []byte) *big.Int {
+	second := make([]byte, 33)
+	for _, pk := range pubkeys[1:] {
+		if !bytes.Equal(pk, pubkeys[0]) {
+			second = pk
+			break
+		}
+	}
+	if bytes.Equal(pubkey, second) {
+		return big.NewInt(1)
+	}
+	list := taggedHash("KeyAgg list", pubkeys...)
+	h := taggedHash("KeyAgg coefficient", list[:], pubkey)
+	return mod(new(big.Int).SetBytes(h[:]), N)
+}
+
+//keyAgg computes Q = a_1*P_1 + ... + a_u*P_u in the order the keys are given
+func keyAgg(pubkeys [][]byte) (*KeyAggContext, error) {
+	if len(pubkeys) == 0 {
+		return nil, fmt.Errorf("ValueError: %v", "no public keys to aggregate")
+	}
+	Q := NewS256Point(nil, nil)
+	for i, pubkey := range pubkeys {
+		point, err := cpoint(pubkey)
+		if err != nil {
+			return nil, fmt.Errorf("%v (signer %d)", err, i)
+		}
+		Q = Q.SAdd(point.Rmul2(keyAggCoeff(pubkeys, pubkey)))
+	}
+	if Q.isInfinity() {
+		return nil, fmt.Errorf("ValueError: %v", "aggregate key is the point at infinity")
+	}
+	return &KeyAggContext{Q, big.NewInt(1), big.NewInt(0)}, nil
+}
+
+//applyTweak adds tweak*G to the aggregate key. An x-only tweak, like the
+//BIP341 taproot tweak, first negates Q if it has an odd y.
+func (ctx *KeyAggContext) applyTweak(tweak []byte, isXonly bool) (*KeyAggContext, error) {
+	if len(tweak) != 32 {
+		return nil, fmt.Errorf("ValueError: %v", "tweak must be 32 bytes")
+	}
+	t := new(big.Int).SetBytes(tweak)
+	if t.Cmp(N) >= 0 {
+		return nil, fmt.Errorf("ValueError: %v", "tweak not below N")
+	}
+	g := big.NewInt(1)
+	Q := ctx.q
+	if isXonly && !Q.hasEvenY() {
+		g = new(big.Int).Sub(N, g)
+		Q = negatePoint(Q)
+	}
+	Q = Q.SAdd(G.Rmul2(t))
+	if Q.isInfinity() {
+		return nil, fmt.Errorf("ValueError: %v", "tweaked key is the point at infinity")
+	}
+	gacc := mod(new(big.Int).Mul(g, ctx.gacc), N)
+	tacc := mod(new(big.Int).Add(t, new(big.Int).Mul(g, ctx.tacc)), N)
+	return &KeyAggContext{Q, gacc, tacc}, nil
+}
+
+//xonly is the BIP340 public key the final signature verifies under
+func (ctx *KeyAggContext) xonly() []byte {
+	return ctx.q.xonly()
+}
+
+func nonceHash(randBytes, pubkey, aggpk, msgPrefixed, extraIn []byte, i byte) *big.Int {
+	extraLen := make([]byte, 4)
+	binary.BigEndian.PutUint32(extraLen, uint32(len(extraIn)))
+	h := taggedHash("MuSig/nonce", randBytes, []byte{byte(len(pubkey))}, pubkey, []byte{byte(len(aggpk))}, aggpk,
+		msgPrefixed, extraLen, extraIn, []byte{i})
+	return mod(new(big.Int).SetBytes(h[:]), N)
+}
+
+//nonceGen makes a fresh secret and public nonce for pubkey. The private
+//key, aggregate x-only key, message and extra input are all optional (nil)
+//and only add defence in depth, a nil msg is not the same as an empty one.
+//randBytes must be 32 bytes that are never used again, nil reads them from
+//crypto/rand. The secret nonce must be used for one signature only.
+func nonceGen(privateKey *PrivateKey, pubkey, aggpk, msg, extraIn, randBytes []byte) ([]byte, []byte, error) {
+	if len(pubkey) != 33 {
+		return nil, nil, fmt.Errorf("ValueError: %v", "public key must be 33 bytes")
+	}
+	if randBytes == nil {
+		randBytes = make([]byte, 32)
+		if _, err := rand.Read(randBytes); err != nil {
+			return nil, nil, err
+		}
+	}
+	if len(randBytes) != 32 {
+		return nil, nil, fmt.Errorf("ValueError: %v", "randomness must be 32 bytes")
+	}
+	randBytes = append([]byte{}, randBytes...)
+	if privateKey != nil {
+		aux := taggedHash("MuSig/aux", randBytes)
+		secret := intToBytes32(privateKey.secret)
+		for i := range randBytes {
+			randBytes[i] = secret[i] ^ aux[i]
+		}
+	}
+	var msgPrefixed []byte
+	if msg == nil {
+		msgPrefixed = []byte{0}
+	} else {
+		msgPrefixed = make([]byte, 9)
+		msgPrefixed[0] = 1
+		binary.BigEndian.PutUint64(msgPrefixed[1:], uint64(len(msg)))
+		msgPrefixed = append(msgPrefixed, msg...)
+	}
+	k1 := nonceHash(randBytes, pubkey, aggpk, msgPrefixed, extraIn, 0)
+	k2 := nonceHash(randBytes, pubkey, aggpk, msgPrefixed, extraIn, 1)
+	if k1.Sign() == 0 || k2.Sign() == 0 {
+		return nil, nil, fmt.Errorf("RuntimeError: %v", "nonce is zero")
+	}
+	secnonce := append(append(intToBytes32(k1), intToBytes32(k2)...), pubkey...)
+	pubnonce := append([]byte(G.rmulSecret(k1).sec(true)), G.rmulSecret(k2).sec(true)...)
+	return secnonce, pubnonce, nil
+}
+
+//nonceAgg sums the signers' public nonces into the 66 byte aggregate nonce
+func nonceAgg(pubnonces [][]byte) ([]byte, error) {
+	var aggnonce []byte
+	for j := 0; j < 2; j++ {
+		R := NewS256Point(nil, nil)
+		for i, pubnonce := range pubnonces {
+			if len(pubnonce) != 66 {
+				return nil, fmt.Errorf("ValueError: signer %d: public nonce must be 66 bytes", i)
+			}
+			point, err := cpoint(pubnonce[33*j : 33*(j+1)])
+			if err != nil {
+				return nil, fmt.Errorf("%v (signer %d)", err, i)
+			}
+			R = R.SAdd(point)
+		}
+		aggnonce = append(aggnonce, cbytesExt(R)...)
+	}
+	return aggnonce, nil
+}
+
+//MuSigSessionContext is everything the signers have to agree on
+//before anyone produces a partial signature
+type MuSigSessionContext struct {
+	aggnonce []byte
+	pubkeys  [][]byte
+	tweaks   [][]byte
+	isXonly  []bool
+	msg      []byte
+}
+
+func NewMuSigSessionContext(aggnonce []byte, pubkeys [][]byte, tweaks [][]byte, isXonly []bool, msg []byte) (ctx *MuSigSessionContext) {
+	ctx = new(MuSigSessionContext)
+	ctx.aggnonce = aggnonce
+	ctx.pubkeys = pubkeys
+	ctx.tweaks = tweaks
+	ctx.isXonly = isXonly
+	ctx.msg = msg
+	return
+}
+
+type musigSessionValues struct {
+	keyAgg *KeyAggContext
+	b      *big.Int
+	R      *S256Point
+	e      *big.Int
+}
+
+func (ctx *MuSigSessionContext) values() (*musigSessionValues, error) {
+	if len(ctx.tweaks) != len(ctx.isXonly) {
+		return nil, fmt.Errorf("ValueError: %v", "every tweak needs an x-only flag")
+	}
+	if len(ctx.aggnonce) != 66 {
+		return nil, fmt.Errorf("ValueError: %v", "aggregate nonce must be 66 bytes")
+	}
+	keyCtx, err := keyAgg(ctx.pubkeys)
+	if err != nil {
+		return nil, err
+	}
+	for i := range ctx.tweaks {
+		if keyCtx, err = keyCtx.applyTweak(ctx.tweaks[i], ctx.isXonly[i]); err != nil {
+			return nil, err
+		}
+	}
+	bHash := taggedHash("MuSig/noncecoef", ctx.aggnonce, keyCtx.xonly(), ctx.msg)
+	b := mod(new(big.Int).SetBytes(bHash[:]), N)
+	R1, err := cpointExt(ctx.aggnonce[:33])
+	if err != nil {
+		return nil, err
+	}
+	R2, err := cpointExt(ctx.aggnonce[33:])
+	if err != nil {
+		return nil, err
+	}
+	//R = R1 + b*R2, falling back to G so a malicious aggregator can't make it infinity
+	R := R1.SAdd(R2.Rmul2(b))
+	if R.isInfinity() {
+		R = G
+	}
+	eHash := taggedHash("BIP0340/challenge", R.xonly(), keyCtx.xonly(), ctx.msg)
+	e := mod(new(big.Int).SetBytes(eHash[:]), N)
+	return &musigSessionValues{keyCtx, b, R, e}, nil
+}
+
+//keyAggCoeff is the coefficient of pubkey, which has to be one of the signers
+func (ctx *MuSigSessionContext) keyAggCoeff(pubkey []byte) (*big.Int, error) {
+	for _, pk := range ctx.pubkeys {
+		if bytes.Equal(pk, pubkey) {
+			return keyAggCoeff(ctx.pubkeys, pubkey), nil
+		}
+	}
+	return nil, fmt.Errorf("ValueError: %v", "public key is not one of the signers")
+}
+
+//partialSign makes our partial signature. secnonce is wiped before anything
+//else happens so it can never sign a second time, even after an error.
+func partialSign(secnonce []byte, privateKey *PrivateKey, ctx *MuSigSessionContext) ([]byte, error) {
+	if len(secnonce) != 97 {
+		return nil, fmt.Errorf("ValueError: %v", "secret nonce must be 97 bytes")
+	}
+	k1Bytes := append([]byte{}, secnonce[:32]...)
+	k2Bytes := append([]byte{}, secnonce[32:64]...)
+	for i := 0; i < 64; i++ {
+		secnonce[i] = 0
+	}
+	k1, k2 := new(big.Int).SetBytes(k1Bytes), new(big.Int).SetBytes(k2Bytes)
+	if k1.Sign() == 0 || k1.Cmp(N) >= 0 || k2.Sign() == 0 || k2.Cmp(N) >= 0 {
+		return nil, fmt.Errorf("ValueError: %v", "secret nonce is invalid or was already used")
+	}
+	pubkey := []byte(privateKey.point.sec(true))
+	if !bytes.Equal(secnonce[64:], pubkey) {
+		return nil, fmt.Errorf("ValueError: %v", "secret nonce belongs to another key")
+	}
+	values, err := ctx.values()
+	if err != nil {
+		return nil, err
+	}
+	a, err := ctx.keyAggCoeff(pubkey)
+	if err != nil {
+		return nil, err
+	}
+	pubnonce := append([]byte(G.rmulSecret(k1).sec(true)), G.rmulSecret(k2).sec(true)...)
+	if !values.R.hasEvenY() {
+		k1.Sub(N, k1)
+		k2.Sub(N, k2)
+	}
+	g := big.NewInt(1)
+	if !values.keyAgg.q.hasEvenY() {
+		g.Sub(N, g)
+	}
+	//s = k1 + b*k2 + e*a*d with d = g*gacc*d', on constant time scalars
+	var d, bk2, ead, s scalarVal
+	gVal, gaccVal, dVal := newScalarVal(g), newScalarVal(values.keyAgg.gacc), newScalarVal(privateKey.secret)
+	d.mul(&gVal, &gaccVal)
+	d.mul(&d, &dVal)
+	bVal, eVal, aVal, k1Val, k2Val := newScalarVal(values.b), newScalarVal(values.e), newScalarVal(a), newScalarVal(k1), newScalarVal(k2)
+	bk2.mul(&bVal, &k2Val)
+	ead.mul(&eVal, &aVal)
+	ead.mul(&ead, &d)
+	s.add(&k1Val, &bk2)
+	s.add(&s, &ead)
+	psig := intToBytes32(s.big())
+	//make sure we never hand out a bad partial signature
+	if !partialSigVerifyInternal(psig, pubnonce, pubkey, ctx) {
+		return nil, fmt.Errorf("RuntimeError: %v", "created an invalid partial signature")
+	}
+	return psig, nil
+}
+
+//partialSigVerify checks signer i's partial signature given everyone's
+//public nonces and keys, it lets the aggregator find a misbehaving signer
+func partialSigVerify(psig []byte, pubnonces [][]byte, pubkeys [][]byte, tweaks [][]byte, isXonly []bool, msg []byte, i int) bool {
+	if len(pubnonces) != len(pubkeys) || i < 0 || i >= len(pubkeys) {
+		return false
+	}
+	aggnonce, err := nonceAgg(pubnonces)
+	if err != nil {
+		return false
+	}
+	ctx := NewMuSigSessionContext(aggnonce, pubkeys, tweaks, isXonly, msg)
+	return partialSigVerifyInternal(psig, pubnonces[i], pubkeys[i], ctx)
+}
+
+//partialSigVerifyInternal checks s*G == Re + e*a*g*gacc*P for one signer
+func partialSigVerifyInternal(psig []byte, pubnonce []byte, pubkey []byte, ctx *MuSigSessionContext) bool {
+	if len(psig) != 32 || len(pubnonce) != 66 {
+		return false
+	}
+	s := new(big.Int).SetBytes(psig)
+	if s.Cmp(N) >= 0 {
+		return false
+	}
+	values, err := ctx.values()
+	if err != nil {
+		return false
+	}
+	R1, err := cpoint(pubnonce[:33])
+	if err != nil {
+		return false
+	}
+	R2, err := cpoint(pubnonce[33:])
+	if err != nil {
+		return false
+	}
+	point, err := cpoint(pubkey)
+	if err != nil {
+		return false
+	}
+	a, err := ctx.keyAggCoeff(pubkey)
+	if err != nil {
+		return false
+	}
+	Re := R1.SAdd(R2.Rmul2(values.b))
+	if !values.R.hasEvenY() {
+		Re = negatePoint(Re)
+	}
+	g := big.NewInt(1)
+	if !values.keyAgg.q.hasEvenY() {
+		g.Sub(N, g)
+	}
+	coef := new(big.Int).Mul(values.e, a)
+	coef.Mul(coef, g)
+	coef.Mul(coef, values.keyAgg.gacc)
+	expected := Re.SAdd(point.Rmul2(mod(coef, N)))
+	return G.Rmul2(s).SEq(expected)
+}
+
+//partialSigAgg sums the partial signatures into the final BIP340 signature
+func partialSigAgg(psigs [][]byte, ctx *MuSigSessionContext) (*SchnorrSignature, error) {
+	values, err := ctx.values()
+	if err != nil {
+		return nil, err
+	}
+	g := big.NewInt(1)
+	if !values.keyAgg.q.hasEvenY() {
+		g.Sub(N, g)
+	}
+	s := new(big.Int).Mul(values.e, g)
+	s.Mul(s, values.keyAgg.tacc)
+	for i, psig := range psigs {
+		si := new(big.Int).SetBytes(psig)
+		if len(psig) != 32 || si.Cmp(N) >= 0 {
+			return nil, fmt.Errorf("ValueError: signer %d: invalid partial signature", i)
+		}
+		s.Add(s, si)
+	}
+	return NewSchnorrSignature(values.R.x.num, mod(s, N)), nil
+}
+
+//MuSigSession is one signer's view of a signing session. It keeps the
+//secret nonce to itself and throws it away after the first signature, so
+//a nonce can't be reused by signing twice or by restarting with other
+//nonces from the other signers.
+type MuSigSession struct {
+	privateKey *PrivateKey
+	pubkeys    [][]byte
+	tweaks     [][]byte
+	isXonly    []bool
+	msg        []byte
+	secnonce   []byte
+	pubnonce   []byte
+	ctx        *MuSigSessionContext
+	pubnonces  [][]byte
+	signed     bool
+}
+
+//NewMuSigSession starts a session for privateKey, whose compressed key has
+//to be among pubkeys, and generates our nonce for msg
+func NewMuSigSession(privateKey *PrivateKey, pubkeys [][]byte, tweaks [][]byte, isXonly []bool, msg []byte) (*MuSigSession, error) {
+	pubkey := []byte(privateKey.point.sec(true))
+	found := false
+	for _, pk := range pubkeys {
+		found = found || bytes.Equal(pk, pubkey)
+	}
+	if !found {
+		return nil, fmt.Errorf("ValueError: %v", "our public key is not one of the signers")
+	}
+	keyCtx, err := keyAgg(pubkeys)
+	if err != nil {
+		return nil, err
+	}
+	if len(tweaks) != len(isXonly) {
+		return nil, fmt.Errorf("ValueError: %v", "every tweak needs an x-only flag")
+	}
+	for i := range tweaks {
+		if keyCtx, err = keyCtx.applyTweak(tweaks[i], isXonly[i]); err != nil {
+			return nil, err
+		}
+	}
+	secnonce, pubnonce, err := nonceGen(privateKey, pubkey, keyCtx.xonly(), msg, nil, nil)
+	if err != nil {
+		return nil, err
+	}
+	Ms := &MuSigSession{privateKey: privateKey, pubkeys: pubkeys, tweaks: tweaks, isXonly: isXonly, msg: msg}
+	Ms.secnonce, Ms.pubnonce = secnonce, pubnonce
+	return Ms, nil
+}
+
+//publicNonce is what we send to the other signers
+func (Ms *MuSigSession) publicNonce() []byte {
+	return Ms.pubnonce
+}
+
+//aggregateKey is the x-only key the final signature is valid for
+func (Ms *MuSigSession) aggregateKey() ([]byte, error) {
+	keyCtx, err := keyAgg(Ms.pubkeys)
+	if err != nil {
+		return nil, err
+	}
+	for i := range Ms.tweaks {
+		if keyCtx, err = keyCtx.applyTweak(Ms.tweaks[i], Ms.isXonly[i]); err != nil {
+			return nil, err
+		}
+	}
+	return keyCtx.xonly(), nil
+}
+
+//setNonces takes every signer's public nonce, in the order of pubkeys and
+//including ours. It can only be called once per session.
+func (Ms *MuSigSession) setNonces(pubnonces [][]byte) error {
+	if Ms.ctx != nil {
+		return fmt.Errorf("RuntimeError: %v", "nonces already set, start a new session")
+	}
+	if len(pubnonces) != len(Ms.pubkeys) {
+		return fmt.Errorf("ValueError: %v", "need one public nonce per signer")
+	}
+	ours := false
+	pubkey := []byte(Ms.privateKey.point.sec(true))
+	for i := range pubnonces {
+		ours = ours || (bytes.Equal(Ms.pubkeys[i], pubkey) && bytes.Equal(pubnonces[i], Ms.pubnonce))
+	}
+	if !ours {
+		return fmt.Errorf("ValueError: %v", "our public nonce is missing")
+	}
+	aggnonce, err := nonceAgg(pubnonces)
+	if err != nil {
+		return err
+	}
+	Ms.pubnonces = pubnonces
+	Ms.ctx = NewMuSigSessionContext(aggnonce, Ms.pubkeys, Ms.tweaks, Ms.isXonly, Ms.msg)
+	return nil
+}
+
+//sign returns our partial signature, a second call is an error
+func (Ms *MuSigSession) sign() ([]byte, error) {
+	if Ms.ctx == nil {
+		return nil, fmt.Errorf("RuntimeError: %v", "nonces have not been set")
+	}
+	if Ms.signed {
+		return nil, fmt.Errorf("RuntimeError: %v", "already signed, nonces can not be reused")
+	}
+	Ms.signed = true
+	return partialSign(Ms.secnonce, Ms.privateKey, Ms.ctx)
+}
+
+//verifyPartial checks the partial signature of signer i
+func (Ms *MuSigSession) verifyPartial(i int, psig []byte) bool {
+	if Ms.ctx == nil || i < 0 || i >= len(Ms.pubkeys) {
+		return false
+	}
+	return partialSigVerifyInternal(psig, Ms.pubnonces[i], Ms.pubkeys[i], Ms.ctx)
+}
+
+//aggregate checks every partial signature, in the order of pubkeys, and
+//combines them into the final BIP340 signature
+func (Ms *MuSigSession) aggregate(psigs [][]byte) (*SchnorrSignature, error) {
+	if Ms.ctx == nil {
+		return nil, fmt.Errorf("RuntimeError: %v", "nonces have not been set")
+	}
+	if len(psigs) != len(Ms.pubkeys) {
+		return nil, fmt.Errorf("ValueError: %v", "need one partial signature per signer")
+	}
+	for i, psig := range psigs {
+		if !Ms.verifyPartial(i, psig) {
+			return nil, fmt.Errorf("ValueError: signer %d sent an invalid partial signature", i)
+		}
+	}
+	return partialSigAgg(psigs, Ms.ctx)
+}
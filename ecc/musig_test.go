@@ -0,0 +1,305 @@
+package ecc
+
+import (
+	"bytes"
+	"math/big"
+	"strings"
+	"testing"
+)
+
+func hexList(list ...string) [][]byte {
+	result := make([][]byte, len(list))
+	for i, s := range list {
+		result[i] = mustHex(s)
+	}
+	return result
+}
+
+func pick(list [][]byte, indices ...int) [][]byte {
+	result := make([][]byte, len(indices))
+	for i, index := range indices {
+		result[i] = list[index]
+	}
+	return result
+}
+
+//key_agg_vectors.json of BIP327
+func TestMuSigKeyAgg(t *testing.T) {
+	pubkeys := hexList(
+		"02F9308A019258C31049344F85F89D5229B531C845836F99B08601F113BCE036F9",
+		"03DFF1D77F2A671C5F36183726DB2341BE58FEAE1DA2DECED843240F7B502BA659",
+		"023590A94E768F8E1815C2F24B4D80A8E3149316C3518CE7B7AD338368D038CA66",
+		"020000000000000000000000000000000000000000000000000000000000000005",
+		"02FFFFFFFFFFFFFFFFFFFFFFFFFFFFFFFFFFFFFFFFFFFFFFFFFFFFFEFFFFFC30",
+		"04F9308A019258C31049344F85F89D5229B531C845836F99B08601F113BCE036F9",
+		"03935F972DA013F80AE011890FA89B67A27B7BE6CCB24D3274D18B2D4067F261A9",
+	)
+	tweaks := hexList(
+		"FFFFFFFFFFFFFFFFFFFFFFFFFFFFFFFEBAAEDCE6AF48A03BBFD25E8CD0364141",
+		"252E4BD67410A76CDF933D30EAA1608214037F1B105A013ECCD3C5C184A6110B",
+	)
+	valid := []struct {
+		keyIndices []int
+		expected   string
+	}{
+		{[]int{0, 1, 2}, "90539EEDE565F5D054F32CC0C220126889ED1E5D193BAF15AEF344FE59D4610C"},
+		{[]int{2, 1, 0}, "6204DE8B083426DC6EAF9502D27024D53FC826BF7D2012148A0575435DF54B2B"},
+		{[]int{0, 0, 0}, "B436E3BAD62B8CD409969A224731C193D051162D8C5AE8B109306127DA3AA935"},
+		{[]int{0, 0, 1, 1}, "69BC22BFA5D106306E48A20679DE1D7389386124D07571D0D872686028C26A3E"},
+	}
+	for _, test := range valid {
+		keyCtx, err := keyAgg(pick(pubkeys, test.keyIndices...))
+		if err != nil {
+			t.Fatal(err)
+		}
+		if !bytes.Equal(keyCtx.xonly(), mustHex(test.expected)) {
+			t.Errorf("%v: aggregate key %X, want %s", test.keyIndices, keyCtx.xonly(), test.expected)
+		}
+	}
+	errors := []struct {
+		keyIndices   []int
+		tweakIndices []int
+		isXonly      []bool
+		wantErr      string
+	}{
+		{[]int{0, 3}, nil, nil, "signer 1"},
+		{[]int{0, 4}, nil, nil, "signer 1"},
+		{[]int{5, 0}, nil, nil, "signer 0"},
+		{[]int{0, 1}, []int{0}, []bool{true}, "tweak not below N"},
+		{[]int{6}, []int{1}, []bool{false}, "infinity"},
+	}
+	for _, test := range errors {
+		keyCtx, err := keyAgg(pick(pubkeys, test.keyIndices...))
+		for i, index := range test.tweakIndices {
+			if err != nil {
+				break
+			}
+			keyCtx, err = keyCtx.applyTweak(tweaks[index], test.isXonly[i])
+		}
+		if err == nil || !strings.Contains(err.Error(), test.wantErr) {
+			t.Errorf("%v: error %v, want one about %s", test.keyIndices, err, test.wantErr)
+		}
+	}
+}
+
+//TestMuSigNonceGen checks the NonceGen algorithm of BIP327 on its
+//properties: the public nonce belongs to the secret one, every optional
+//input changes the result and an absent message is not an empty one
+func TestMuSigNonceGen(t *testing.T) {
+	privateKey := NewPrivateKey(hexInt("0202020202020202020202020202020202020202020202020202020202020202"))
+	pk := []byte(privateKey.point.sec(true))
+	aggpk := bytes.Repeat([]byte{0x07}, 32)
+	msg := bytes.Repeat([]byte{0x01}, 32)
+	extraIn := bytes.Repeat([]byte{0x08}, 32)
+	randBytes := make([]byte, 32)
+	secnonce, pubnonce, err := nonceGen(privateKey, pk, aggpk, msg, extraIn, randBytes)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(secnonce) != 97 || !bytes.Equal(secnonce[64:], pk) {
+		t.Fatalf("secnonce %X does not end in the public key", secnonce)
+	}
+	R1, R2 := G.Rmul2(new(big.Int).SetBytes(secnonce[:32])), G.Rmul2(new(big.Int).SetBytes(secnonce[32:64]))
+	if !bytes.Equal(pubnonce, []byte(R1.sec(true)+R2.sec(true))) {
+		t.Errorf("pubnonce %X does not match the secret nonce", pubnonce)
+	}
+	again, _, _ := nonceGen(privateKey, pk, aggpk, msg, extraIn, randBytes)
+	if !bytes.Equal(again, secnonce) {
+		t.Error("same inputs gave another nonce")
+	}
+	seen := map[string]bool{string(secnonce): true}
+	for name, variant := range map[string]func() ([]byte, []byte, error){
+		"no secret key":  func() ([]byte, []byte, error) { return nonceGen(nil, pk, aggpk, msg, extraIn, randBytes) },
+		"no aggpk":       func() ([]byte, []byte, error) { return nonceGen(privateKey, pk, nil, msg, extraIn, randBytes) },
+		"no message":     func() ([]byte, []byte, error) { return nonceGen(privateKey, pk, aggpk, nil, extraIn, randBytes) },
+		"empty message":  func() ([]byte, []byte, error) { return nonceGen(privateKey, pk, aggpk, []byte{}, extraIn, randBytes) },
+		"no extra input": func() ([]byte, []byte, error) { return nonceGen(privateKey, pk, aggpk, msg, nil, randBytes) },
+		"other rand": func() ([]byte, []byte, error) {
+			return nonceGen(privateKey, pk, aggpk, msg, extraIn, bytes.Repeat([]byte{1}, 32))
+		},
+		"fresh rand": func() ([]byte, []byte, error) { return nonceGen(privateKey, pk, aggpk, msg, extraIn, nil) },
+	} {
+		other, _, err := variant()
+		if err != nil {
+			t.Fatalf("%s: %v", name, err)
+		}
+		if seen[string(other)] {
+			t.Errorf("%s: nonce repeats", name)
+		}
+		seen[string(other)] = true
+	}
+	if _, _, err := nonceGen(privateKey, pk[1:], aggpk, msg, extraIn, randBytes); err == nil {
+		t.Error("32 byte public key accepted")
+	}
+	if _, _, err := nonceGen(privateKey, pk, aggpk, msg, extraIn, randBytes[1:]); err == nil {
+		t.Error("31 bytes of randomness accepted")
+	}
+}
+
+//sign_verify_vectors.json of BIP327, the valid cases and the sign errors
+func TestMuSigSignVerify(t *testing.T) {
+	privateKey := NewPrivateKey(hexInt("7FB9E0E687ADA1EEBF7ECFE2F21E73EBDB51A7D450948DFE8D76D7F2D1007671"))
+	pubkeys := hexList(
+		"03935F972DA013F80AE011890FA89B67A27B7BE6CCB24D3274D18B2D4067F261A9",
+		"02F9308A019258C31049344F85F89D5229B531C845836F99B08601F113BCE036F9",
+		"02DFF1D77F2A671C5F36183726DB2341BE58FEAE1DA2DECED843240F7B502BA661",
+		"020000000000000000000000000000000000000000000000000000000000000007",
+	)
+	secnonce := mustHex("508B81A611F100A6B2B6B29656590898AF488BCF2E1F55CF22E5CFB84421FE61FA27FD49B1D50085B481285E1CA205D55C82CC1B31FF5CD54A489829355901F703935F972DA013F80AE011890FA89B67A27B7BE6CCB24D3274D18B2D4067F261A9")
+	pnonces := hexList(
+		"0337C87821AFD50A8644D820A8F3E02E499C931865C2360FB43D0A0D20DAFE07EA0287BF891D2A6DEAEBADC909352AA9405D1428C15F4B75F04DAE642A95C2548480",
+		"0279BE667EF9DCBBAC55A06295CE870B07029BFCDB2DCE28D959F2815B16F817980279BE667EF9DCBBAC55A06295CE870B07029BFCDB2DCE28D959F2815B16F81798",
+		"032DE2662628C90B03F5E720284EB52FF7D71F4284F627B68A853D78C78E1FFE9303E4C5524E83FFE1493B9077CF1CA6BEB2090C93D930321071AD40B2F44E599046",
+		"0237C87821AFD50A8644D820A8F3E02E499C931865C2360FB43D0A0D20DAFE07EA0387BF891D2A6DEAEBADC909352AA9405D1428C15F4B75F04DAE642A95C2548480",
+	)
+	aggnonces := hexList(
+		"028465FCF0BBDBCF443AABCCE533D42B4B5A10966AC09A49655E8C42DAAB8FCD61037496A3CC86926D452CAFCFD55D25972CA1675D549310DE296BFF42F72EEEA8C9",
+		"000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000",
+	)
+	msgs := hexList(
+		"F95466D086770E689964664219266FE5ED215C92AE20BAB5C9D79ADDDDF3C0CF",
+		"",
+		"2626262626262626262626262626262626262626262626262626262626262626262626262626",
+	)
+	valid := []struct {
+		keyIndices, nonceIndices []int
+		aggnonceIndex, msgIndex  int
+		signerIndex              int
+		expected                 string
+	}{
+		{[]int{0, 1, 2}, []int{0, 1, 2}, 0, 0, 0, "012ABBCB52B3016AC03AD82395A1A415C48B93DEF78718E62A7A90052FE224FB"},
+		{[]int{1, 0, 2}, []int{1, 0, 2}, 0, 0, 1, "9FF2F7AAA856150CC8819254218D3ADEEB0535269051897724F9DB3789513A52"},
+		//the nonces cancel out, R falls back to G
+		{[]int{0, 1}, []int{0, 3}, 1, 0, 0, "AE386064B26105404798F75DE2EB9AF5EDA5387B064B83D049CB7C5E08879531"},
+		{[]int{0, 1, 2}, []int{0, 1, 2}, 0, 1, 0, "D7D63FFD644CCDA4E62BC2BC0B1D02DD32A1DC3030E155195810231D1037D82D"},
+		{[]int{0, 1, 2}, []int{0, 1, 2}, 0, 2, 0, "E184351828DA5094A97C79CABDAAA0BFB87608C32E8829A4DF5340A6F243B78C"},
+	}
+	for _, test := range valid {
+		signers, nonces := pick(pubkeys, test.keyIndices...), pick(pnonces, test.nonceIndices...)
+		aggnonce, err := nonceAgg(nonces)
+		if err != nil || !bytes.Equal(aggnonce, aggnonces[test.aggnonceIndex]) {
+			t.Fatalf("%v: aggregate nonce %X, %v", test.nonceIndices, aggnonce, err)
+		}
+		ctx := NewMuSigSessionContext(aggnonce, signers, nil, nil, msgs[test.msgIndex])
+		psig, err := partialSign(append([]byte{}, secnonce...), privateKey, ctx)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if !bytes.Equal(psig, mustHex(test.expected)) {
+			t.Errorf("%v: partial signature %X, want %s", test.keyIndices, psig, test.expected)
+		}
+		if !partialSigVerify(psig, nonces, signers, nil, nil, msgs[test.msgIndex], test.signerIndex) {
+			t.Errorf("%v: partial signature does not verify", test.keyIndices)
+		}
+		//a different signer's slot, or a changed message, must not verify
+		other := (test.signerIndex + 1) % len(signers)
+		if partialSigVerify(psig, nonces, signers, nil, nil, msgs[test.msgIndex], other) {
+			t.Errorf("%v: partial signature verifies for signer %d", test.keyIndices, other)
+		}
+		if partialSigVerify(psig, nonces, signers, nil, nil, append([]byte{0}, msgs[test.msgIndex]...), test.signerIndex) {
+			t.Errorf("%v: partial signature verifies for another message", test.keyIndices)
+		}
+	}
+	ctx := NewMuSigSessionContext(aggnonces[0], pick(pubkeys, 0, 1, 2), nil, nil, msgs[0])
+	signErrors := []struct {
+		name     string
+		secnonce []byte
+		ctx      *MuSigSessionContext
+		wantErr  string
+	}{
+		{"signer missing", secnonce, NewMuSigSessionContext(aggnonces[0], pick(pubkeys, 1, 2), nil, nil, msgs[0]), "not one of the signers"},
+		{"invalid pubkey", secnonce, NewMuSigSessionContext(aggnonces[0], pick(pubkeys, 1, 0, 3), nil, nil, msgs[0]), "invalid public key"},
+		{"invalid aggnonce", secnonce, NewMuSigSessionContext(append([]byte{0x04}, aggnonces[0][1:]...), pick(pubkeys, 1, 2, 0), nil, nil, msgs[0]), "invalid public key"},
+		{"used secnonce", append(make([]byte, 64), pubkeys[0]...), ctx, "already used"},
+		{"other key's secnonce", append(append([]byte{}, secnonce[:64]...), pubkeys[1]...), ctx, "another key"},
+	}
+	for _, test := range signErrors {
+		_, err := partialSign(append([]byte{}, test.secnonce...), privateKey, test.ctx)
+		if err == nil || !strings.Contains(err.Error(), test.wantErr) {
+			t.Errorf("%s: error %v, want one about %s", test.name, err, test.wantErr)
+		}
+	}
+	//partialSign wipes the nonce it was given
+	used := append([]byte{}, secnonce...)
+	if _, err := partialSign(used, privateKey, ctx); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := partialSign(used, privateKey, ctx); err == nil || !strings.Contains(err.Error(), "already used") {
+		t.Errorf("signed twice with one secret nonce: %v", err)
+	}
+}
+
+//TestMuSigSession runs three signers through a tweaked session the way
+//sig_agg_vectors.json does: the aggregate of the partial signatures has to
+//be a valid BIP340 signature for the tweaked aggregate key
+func TestMuSigSession(t *testing.T) {
+	var privateKeys []*PrivateKey
+	var pubkeys [][]byte
+	for _, secret := range []int64{0x1111, 0x2222, 0x3333} {
+		privateKey := NewPrivateKey(big.NewInt(secret))
+		privateKeys = append(privateKeys, privateKey)
+		pubkeys = append(pubkeys, []byte(privateKey.point.sec(true)))
+	}
+	pubkeys = keySort(pubkeys)
+	tweaks := hexList(
+		"B511DA492182A91B0FFB9A98020D55F260AE86D7ECBD0399C7383D59A5F2AF7C",
+		"A815FE049EE3C5AAB66310477FBC8BCCCAC2F3395F59F921C364ACD78A2F48DC",
+	)
+	isXonly := []bool{true, false}
+	msg := []byte("MuSig2 session")
+	var sessions []*MuSigSession
+	var pubnonces [][]byte
+	for _, pubkey := range pubkeys {
+		for _, privateKey := range privateKeys {
+			if privateKey.point.sec(true) != string(pubkey) {
+				continue
+			}
+			session, err := NewMuSigSession(privateKey, pubkeys, tweaks, isXonly, msg)
+			if err != nil {
+				t.Fatal(err)
+			}
+			sessions = append(sessions, session)
+			pubnonces = append(pubnonces, session.publicNonce())
+		}
+	}
+	var psigs [][]byte
+	for _, session := range sessions {
+		if err := session.setNonces(pubnonces); err != nil {
+			t.Fatal(err)
+		}
+		if err := session.setNonces(pubnonces); err == nil {
+			t.Error("nonces set twice")
+		}
+		psig, err := session.sign()
+		if err != nil {
+			t.Fatal(err)
+		}
+		psigs = append(psigs, psig)
+		if _, err := session.sign(); err == nil || !strings.Contains(err.Error(), "already signed") {
+			t.Errorf("session signed twice: %v", err)
+		}
+	}
+	sig, err := sessions[0].aggregate(psigs)
+	if err != nil {
+		t.Fatal(err)
+	}
+	aggpk, err := sessions[0].aggregateKey()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !verifySchnorr(aggpk, msg, sig) {
+		t.Error("aggregate signature does not verify")
+	}
+	if verifySchnorr(aggpk, []byte("another message"), sig) {
+		t.Error("aggregate signature verifies for another message")
+	}
+	//a bad partial signature is caught and blamed on its signer
+	psigs[1] = append([]byte{}, psigs[1]...)
+	psigs[1][31] ^= 1
+	if _, err := sessions[0].aggregate(psigs); err == nil || !strings.Contains(err.Error(), "signer 1") {
+		t.Errorf("bad partial signature: %v", err)
+	}
+	if _, err := NewMuSigSession(NewPrivateKey(big.NewInt(0x4444)), pubkeys, nil, nil, msg); err == nil {
+		t.Error("session started for a key that is not a signer")
+	}
+}
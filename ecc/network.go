@@ -2,20 +2,15 @@ package ecc
 
 import (
 	"bytes"
+	"crypto/rand"
 	"encoding/binary"
 	"fmt"
-	"math"
-	"math/rand"
-	"reflect"
+	"io"
+	"net"
 	"strings"
 	"time"
 )
 
-var (
-	NETWORKMAGIC        = []byte("f9beb4d9")
-	TESTNETNETWORKMAGIC = []byte("0b110907")
-)
-
 var (
 	TXDATATYPE            = 1
 	BLOCKDATATYPE         = 2
@@ -23,65 +18,64 @@ var (
 	COMPACTBLOCKDATATYPE  = 4
 )
 
+//MAXPAYLOADSIZE is Core's MAX_PROTOCOL_MESSAGE_LENGTH, bigger payloads are refused
+const MAXPAYLOADSIZE = 4 * 1000 * 1000
+
+//Message is anything that travels inside a NetworkEnvelope
+type Message interface {
+	Command() []byte
+	serialize() []byte
+}
+
 type NetworkEnvelope struct {
 	command []byte
 	payload []byte
 	magic   []byte
 }
 
-func NewNetworkEnvelope(command []byte, payload []byte, testnet bool) (Ne *NetworkEnvelope) {
+func NewNetworkEnvelope(command []byte, payload []byte, net *NetworkParams) (Ne *NetworkEnvelope) {
 	Ne = new(NetworkEnvelope)
 	Ne.command = command
 	Ne.payload = payload
-	if bool(testnet) {
-		Ne.magic = TESTNETNETWORKMAGIC
-	} else {
-		Ne.magic = NETWORKMAGIC
-	}
+	Ne.magic = net.magic
 	return
 }
 
 func (Ne *NetworkEnvelope) Repr() string {
-	return fmt.Sprintf("%s", "%x", Ne.command, Ne.payload)
+	return fmt.Sprintf("%s: %x", Ne.command, Ne.payload)
 }
 
-func (Ne *NetworkEnvelope) parse(s []byte, testnet bool) *NetworkEnvelope {
-	var expectedMagic []byte
-	var byt bytes.Buffer
-	byt.Write(s)
-	magic, _ := byt.ReadBytes(4)
-	if reflect.DeepEqual(magic, []byte("")) {
-		panic(fmt.Errorf("IOError: %v", "Connection reset!"))
+func (Ne *NetworkEnvelope) parse(s io.Reader, net *NetworkParams) (*NetworkEnvelope, error) {
+	header := make([]byte, 24)
+	if _, err := io.ReadFull(s, header); err != nil {
+		return nil, fmt.Errorf("IOError: %v", "Connection reset!")
 	}
-	if bool(testnet) {
-		expectedMagic = TESTNETNETWORKMAGIC
-	} else {
-		expectedMagic = NETWORKMAGIC
+	magic := header[:4]
+	if !bytes.Equal(magic, net.magic) {
+		return nil, fmt.Errorf("IOError: magic is not right %x vs %x", magic, net.magic)
 	}
-	if string(magic) != string(expectedMagic) {
-		panic(fmt.Errorf("magic is not right %s vs %x", magic, expectedMagic))
+	command := []byte(strings.TrimRight(string(header[4:16]), "\x00"))
+	payloadLength := littleEndianToInt(header[16:20])
+	if payloadLength > MAXPAYLOADSIZE {
+		return nil, fmt.Errorf("IOError: payload of %d bytes is too big", payloadLength)
+	}
+	checksum := header[20:24]
+	payload := make([]byte, payloadLength)
+	if _, err := io.ReadFull(s, payload); err != nil {
+		return nil, fmt.Errorf("IOError: %v", "truncated payload")
 	}
-	command, _ := byt.ReadBytes(12)
-	command = []byte(strings.Trim(string(command), string([]byte("\u0000"))))
-	x, _ := byt.ReadBytes(4)
-	payloadLength := littleEndianToInt(x)
-	checksum, _ := byt.ReadBytes(4)
-	payload, _ := byt.ReadBytes(byte(payloadLength))
 	calculatedChecksum := hash256(string(payload))[:4]
 	if calculatedChecksum != string(checksum) {
-		panic(fmt.Errorf("IOError: %v", "checksum does not match"))
+		return nil, fmt.Errorf("IOError: %v", "checksum does not match")
 	}
-	return NewNetworkEnvelope(command, payload, testnet)
+	return NewNetworkEnvelope(command, payload, net), nil
 }
 
 func (Ne *NetworkEnvelope) serialize() []byte {
-	result := Ne.magic
-	result = append(Ne.command, func(repeated []byte, n int) (result []byte) {
-		for i := 0; i < n; i++ {
-			result = append(result, repeated...)
-		}
-		return result
-	}([]byte("\u0000"), 12-len(Ne.command))...)
+	result := append([]byte{}, Ne.magic...)
+	//the command is padded with zeros to 12 bytes
+	result = append(result, Ne.command...)
+	result = append(result, make([]byte, 12-len(Ne.command))...)
 	result = append(result, intToLittleEndian(len(Ne.payload), 4)...)
 	result = append(result, hash256(string(Ne.payload))[:4]...)
 	result = append(result, Ne.payload...)
@@ -112,6 +106,8 @@ type VersionMessage struct {
 	relay            bool
 }
 
+//NewVersionMessage takes nil for timestamp and nonce to use the current
+//time and a random nonce
 func NewVersionMessage(
 	version int,
 	services int,
@@ -129,64 +125,62 @@ func NewVersionMessage(
 ) (Vm *VersionMessage) {
 	Vm = new(VersionMessage)
 	Vm.command = []byte("version")
-	Vm.version = 70015
-	Vm.services = 0
-	Vm.timestamp = nil
-	if &timestamp == nil {
-		Vm.timestamp = int(float64(time.Now().UnixNano()) / 1000000000.0)
+	Vm.version = version
+	Vm.services = services
+	if timestamp == nil {
+		Vm.timestamp = int(time.Now().Unix())
 	} else {
 		Vm.timestamp = timestamp
 	}
-	Vm.receiverServices = 0
-	Vm.receiverIp = []byte("\u0000\u0000\u0000\u0000")
-	Vm.receiverPort = 8333
-	Vm.senderServices = 0
-	Vm.senderIp = []byte("\u0000\u0000\u0000\u0000")
-	Vm.senderPort = 8333
-	Vm.nonce = nil
-	if &nonce == nil {
-		//Perhaps crypto/rand is better for security reasons
-		Vm.nonce = intToLittleEndian((rand.Intn(0) + int(math.Pow(2, 64))), 8)
+	Vm.receiverServices = receiverServices
+	Vm.receiverIp = receiverIp
+	Vm.receiverPort = receiverPort
+	Vm.senderServices = senderServices
+	Vm.senderIp = senderIp
+	Vm.senderPort = senderPort
+	if nonce == nil {
+		b := make([]byte, 8)
+		if _, err := rand.Read(b); err != nil {
+			panic(err)
+		}
+		Vm.nonce = b
 	} else {
 		Vm.nonce = nonce
 	}
-	Vm.userAgent = []byte("/programmingbitcoin:0.1/")
+	Vm.userAgent = userAgent
 	Vm.latestBlock = latestBlock
 	Vm.relay = relay
 	return
 }
 
-//not particularly sure about the 'ffff'
+//NewDefaultVersionMessage is the book's VersionMessage() for a peer on net
+func NewDefaultVersionMessage(net *NetworkParams) *VersionMessage {
+	return NewVersionMessage(70015, 0, nil,
+		0, []byte{0, 0, 0, 0}, net.defaultPort,
+		0, []byte{0, 0, 0, 0}, net.defaultPort,
+		nil, []byte("/programmingbitcoin:0.1/"), 0, false)
+}
+
+func (Vm *VersionMessage) Command() []byte {
+	return Vm.command
+}
+
+//networkAddress is services then the ipv4 address mapped into ipv6
+//(10 zero bytes and ffff) and the port in big endian
+func networkAddress(services int, ip []byte, port int) []byte {
+	result := intToLittleEndian(services, 8)
+	result = append(result, make([]byte, 10)...)
+	result = append(result, 0xff, 0xff)
+	result = append(result, ip...)
+	return append(result, byte(port>>8), byte(port))
+}
+
 func (Vm *VersionMessage) serialize() []byte {
 	result := intToLittleEndian(Vm.version, 4)
 	result = append(result, intToLittleEndian(Vm.services, 8)...)
 	result = append(result, intToLittleEndian(Vm.timestamp.(int), 8)...)
-	result = append(result, intToLittleEndian(Vm.receiverServices, 8)...)
-	result = append(result, func(repeated []byte, n int) (result []byte) {
-		for i := 0; i < n; i++ {
-			result = append(result, repeated...)
-		}
-		return result
-	}([]byte("\u0000"), 10)...)
-	result = append(result, []byte("ffff")...)
-	result = append(result, Vm.receiverIp...)
-
-	combined := make([]byte, 2)
-	binary.BigEndian.PutUint64(combined, uint64(Vm.receiverPort)) //int to bytes
-	result = append(result, combined...)
-	result = append(result, intToLittleEndian(Vm.senderServices, 8)...)
-	result = append(result, func(repeated []byte, n int) (result []byte) {
-		for i := 0; i < n; i++ {
-			result = append(result, repeated...)
-		}
-		return result
-	}([]byte("\u0000"), 10)...)
-	result = append(result, []byte("ffff")...)
-	result = append(result, Vm.senderIp...)
-
-	combined2 := make([]byte, 2)
-	binary.BigEndian.PutUint64(combined2, uint64(Vm.senderPort)) //int to bytes
-	result = append(result, combined2...)
+	result = append(result, networkAddress(Vm.receiverServices, Vm.receiverIp, Vm.receiverPort)...)
+	result = append(result, networkAddress(Vm.senderServices, Vm.senderIp, Vm.senderPort)...)
 	result = append(result, Vm.nonce.([]byte)...)
 	result = append(result, encodeVarint(len(Vm.userAgent))...)
 	result = append(result, Vm.userAgent...)
@@ -209,10 +203,13 @@ func NewVerAckMessage() (Vm *VerAckMessage) {
 }
 
 func (Vm *VerAckMessage) parse(s []byte) *VerAckMessage {
-	Vm = new(VerAckMessage)
 	return NewVerAckMessage()
 }
 
+func (Vm *VerAckMessage) Command() []byte {
+	return []byte("verack")
+}
+
 func (Vm *VerAckMessage) serialize() []byte {
 	return []byte("")
 }
@@ -227,11 +224,15 @@ func NewPingMessage(nonce []byte) (Pm *PingMessage) {
 	return
 }
 
-func (Pm *PingMessage) parse(s []byte) *PingMessage {
-	var byt bytes.Buffer
-	byt.Write(s)
-	nonce, _ := byt.ReadBytes(8)
-	return NewPingMessage(nonce)
+func (Pm *PingMessage) parse(s []byte) (*PingMessage, error) {
+	if len(s) < 8 {
+		return nil, fmt.Errorf("SyntaxError: %v", "ping nonce is 8 bytes")
+	}
+	return NewPingMessage(s[:8]), nil
+}
+
+func (Pm *PingMessage) Command() []byte {
+	return []byte("ping")
 }
 
 func (Pm *PingMessage) serialize() []byte {
@@ -248,11 +249,15 @@ func NewPongMessage(nonce []byte) (Pm *PongMessage) {
 	return
 }
 
-func (Pm *PongMessage) parse(s []byte) *PongMessage {
-	var byt bytes.Buffer
-	byt.Write(s)
-	nonce, _ := byt.ReadBytes(8)
-	return NewPongMessage(nonce)
+func (Pm *PongMessage) parse(s []byte) (*PongMessage, error) {
+	if len(s) < 8 {
+		return nil, fmt.Errorf("SyntaxError: %v", "pong nonce is 8 bytes")
+	}
+	return NewPongMessage(s[:8]), nil
+}
+
+func (Pm *PongMessage) Command() []byte {
+	return []byte("pong")
 }
 
 func (Pm *PongMessage) serialize() []byte {
@@ -266,80 +271,245 @@ type GetHeadersMessage struct {
 	endBlock   []byte
 }
 
+//NewGetHeadersMessage takes the block hashes byte reversed, a nil endBlock
+//asks for as many headers as the peer sends
 func NewGetHeadersMessage(version int, numHashes int, startBlock []byte, endBlock []byte) (Gh *GetHeadersMessage) {
 	Gh = new(GetHeadersMessage)
 	Gh.version = version
 	Gh.numHashes = numHashes
-	if &startBlock == nil {
+	if startBlock == nil {
 		panic(fmt.Errorf("RuntimeError: %v", "a start block is required"))
 	}
 	Gh.startBlock = startBlock
-	if &endBlock == nil {
-		Gh.endBlock = func(repeated []byte, n int) (result []byte) {
-			for i := 0; i < n; i++ {
-				result = append(result, repeated...)
-			}
-			return result
-		}([]byte("\u0000"), 32)
+	if endBlock == nil {
+		Gh.endBlock = make([]byte, 32)
 	} else {
 		Gh.endBlock = endBlock
 	}
 	return
 }
 
+func (Gh *GetHeadersMessage) Command() []byte {
+	return []byte("getheaders")
+}
+
 func (Gh *GetHeadersMessage) serialize() []byte {
 	result := intToLittleEndian(Gh.version, 4)
 	result = append(result, encodeVarint(Gh.numHashes)...)
-	result = append(result, Gh.startBlock[:len(Gh.startBlock)-1]...)
-	result = append(result, Gh.endBlock[:len(Gh.startBlock)-1]...)
+	result = append(result, reverse(string(Gh.startBlock))...)
+	result = append(result, reverse(string(Gh.endBlock))...)
 	return result
 }
 
 type GetDataMessage struct {
-	data [][2]int
+	data []inventory
+}
+
+//inventory is one getdata entry, the identifier is byte reversed
+type inventory struct {
+	dataType   int
+	identifier []byte
 }
 
 func NewGetDataMessage() (Dm *GetDataMessage) {
 	Dm = new(GetDataMessage)
-	//Dm.data = []int
 	return
 }
 
-func (Dm *GetDataMessage) addData(dataType int, identifier int) {
-	Dm.data = append(Dm.data, [2]int{dataType, identifier})
+func (Dm *GetDataMessage) addData(dataType int, identifier []byte) {
+	Dm.data = append(Dm.data, inventory{dataType, identifier})
 }
 
-/*
-func serialize(self interface {}) {
-	var data_type interface {
-	}
-	var identifier interface {
-	}
-	result := encode_varint(len(self.data))
-	for _, [2]interface {
-	}{data_type, identifier} := range self.data {
-		result += int_to_little_endian(data_type, 4)
-		result += identifier[::-1]
+func (Dm *GetDataMessage) Command() []byte {
+	return []byte("getdata")
+}
+
+func (Dm *GetDataMessage) serialize() []byte {
+	result := encodeVarint(len(Dm.data))
+	for _, item := range Dm.data {
+		result = append(result, intToLittleEndian(item.dataType, 4)...)
+		result = append(result, reverse(string(item.identifier))...)
 	}
 	return result
 }
-*/
+
 type GenericMessage struct {
-	command interface{}
-	payload interface{}
+	command []byte
+	payload []byte
 }
 
-func NewGenericMessage(command interface{}, payload interface{}) (self *GenericMessage) {
+func NewGenericMessage(command []byte, payload []byte) (self *GenericMessage) {
 	self = new(GenericMessage)
 	self.command = command
 	self.payload = payload
 	return
 }
 
-func (self *GenericMessage) serialize() interface{} {
+func (self *GenericMessage) Command() []byte {
+	return self.command
+}
+
+func (self *GenericMessage) serialize() []byte {
 	return self.payload
 }
 
+type FilterLoadMessage struct {
+	command []byte
+	filter  *BloomFilter
+}
+
+func NewFilterLoadMessage(filter *BloomFilter) (Fm *FilterLoadMessage) {
+	Fm = new(FilterLoadMessage)
+	Fm.command = []byte("filterload")
+	Fm.filter = filter
+	return
+}
+
+func (Fm *FilterLoadMessage) Command() []byte {
+	return Fm.command
+}
+
+//parse reads a filterload payload, a peer sending a filter over the BIP37
+//limits gets an error rather than a panic
+func (Fm *FilterLoadMessage) parse(s []byte) (*FilterLoadMessage, error) {
+	bitField, rest, err := readVarstr(s)
+	if err != nil {
+		return nil, err
+	}
+	if len(bitField) < 1 || len(bitField) > MAXBLOOMFILTERSIZE {
+		return nil, fmt.Errorf("ValueError: filter size must be 1 to %d bytes", MAXBLOOMFILTERSIZE)
+	}
+	if len(rest) != 9 {
+		return nil, fmt.Errorf("SyntaxError: %v", "bad filterload payload length")
+	}
+	functionCount := binary.LittleEndian.Uint32(rest[:4])
+	if functionCount < 1 || functionCount > MAXHASHFUNCS {
+		return nil, fmt.Errorf("ValueError: need 1 to %d hash functions", MAXHASHFUNCS)
+	}
+	filter := NewBloomFilter(len(bitField), int(functionCount), binary.LittleEndian.Uint32(rest[4:8]))
+	copy(filter.bitField, bitField)
+	filter.flags = int(rest[8])
+	return NewFilterLoadMessage(filter), nil
+}
+
+func (Fm *FilterLoadMessage) serialize() []byte {
+	result := encodeVarint(Fm.filter.size)
+	result = append(result, Fm.filter.filterBytes()...)
+	result = append(result, intToLittleEndian(int(Fm.filter.functionCount), 4)...)
+	result = append(result, intToLittleEndian(int(Fm.filter.tweak), 4)...)
+	result = append(result, intToLittleEndian(Fm.filter.flags, 1)...)
+	return result
+}
+
+//FilterAddMessage adds one item to the filter a peer already has loaded,
+//e.g. a new address the wallet has started watching
+type FilterAddMessage struct {
+	command []byte
+	data    []byte
+}
+
+//NewFilterAddMessage refuses an item a peer would reject, the caller
+//usually has it from a script or a wallet rather than from code
+func NewFilterAddMessage(data []byte) (*FilterAddMessage, error) {
+	if len(data) > MAXFILTERADDSIZE {
+		return nil, fmt.Errorf("ValueError: filteradd items are at most %d bytes", MAXFILTERADDSIZE)
+	}
+	Fm := new(FilterAddMessage)
+	Fm.command = []byte("filteradd")
+	Fm.data = data
+	return Fm, nil
+}
+
+func (Fm *FilterAddMessage) Command() []byte {
+	return Fm.command
+}
+
+func (Fm *FilterAddMessage) parse(s []byte) (*FilterAddMessage, error) {
+	data, rest, err := readVarstr(s)
+	if err != nil {
+		return nil, err
+	}
+	if len(rest) != 0 {
+		return nil, fmt.Errorf("SyntaxError: %v", "bad filteradd payload length")
+	}
+	return NewFilterAddMessage(data)
+}
+
+func (Fm *FilterAddMessage) serialize() []byte {
+	return varstr(Fm.data)
+}
+
+//FilterClearMessage drops the peer's filter, after it every transaction is relayed
+type FilterClearMessage struct {
+	command []byte
+}
+
+func NewFilterClearMessage() (Fm *FilterClearMessage) {
+	Fm = new(FilterClearMessage)
+	Fm.command = []byte("filterclear")
+	return
+}
+
+func (Fm *FilterClearMessage) Command() []byte {
+	return Fm.command
+}
+
+func (Fm *FilterClearMessage) parse(s []byte) (*FilterClearMessage, error) {
+	if len(s) != 0 {
+		return nil, fmt.Errorf("SyntaxError: %v", "filterclear has no payload")
+	}
+	return NewFilterClearMessage(), nil
+}
+
+func (Fm *FilterClearMessage) serialize() []byte {
+	return []byte{}
+}
+
+//applyFilterMessage is the receiving side of filterload, filteradd and
+//filterclear. It returns the filter to use for the peer from now on, nil
+//meaning no filter is loaded.
+func applyFilterMessage(filter *BloomFilter, envelope *NetworkEnvelope) (*BloomFilter, error) {
+	switch string(envelope.command) {
+	case "filterload":
+		message, err := new(FilterLoadMessage).parse(envelope.stream())
+		if err != nil {
+			return filter, err
+		}
+		return message.filter, nil
+	case "filteradd":
+		message, err := new(FilterAddMessage).parse(envelope.stream())
+		if err != nil {
+			return filter, err
+		}
+		if filter == nil {
+			return nil, fmt.Errorf("ValueError: %v", "filteradd without a loaded filter")
+		}
+		filter.Add(message.data)
+		return filter, nil
+	case "filterclear":
+		if _, err := new(FilterClearMessage).parse(envelope.stream()); err != nil {
+			return filter, err
+		}
+		return nil, nil
+	}
+	return filter, fmt.Errorf("ValueError: %s is not a filter message", envelope.command)
+}
+
+//readVarstr splits a varint length prefixed string off the front of s
+func readVarstr(s []byte) (data []byte, rest []byte, err error) {
+	r := bytes.NewReader(s)
+	length, err := readVarint(r)
+	if err != nil {
+		return nil, nil, err
+	}
+	if length > uint64(r.Len()) {
+		return nil, nil, fmt.Errorf("SyntaxError: %v", "truncated string")
+	}
+	start := len(s) - r.Len()
+	end := start + int(length)
+	return s[start:end], s[end:], nil
+}
+
 type HeadersMessage struct {
 	blocks []*Block
 }
@@ -350,97 +520,113 @@ func NewHeadersMessage(blocks []*Block) (Hm *HeadersMessage) {
 	return
 }
 
-func (Hm *HeadersMessage) parse(stream []byte) *HeadersMessage {
-	numHeaders := readVarint(stream)
+func (Hm *HeadersMessage) parse(stream []byte) (*HeadersMessage, error) {
+	s := bytes.NewReader(stream)
+	numHeaders, err := readVarint(s)
+	if err != nil {
+		return nil, err
+	}
+	//every header is 80 bytes and a 0 tx count
+	if numHeaders > uint64(s.Len()/81) {
+		return nil, fmt.Errorf("SyntaxError: %v", "too many headers")
+	}
 	var blocks []*Block
-	for i := 0; i < int(numHeaders); i++ {
-		B := new(Block)
-		blocks = append(blocks, B.parse(stream))
-		num_txs := readVarint(stream)
+	for i := uint64(0); i < numHeaders; i++ {
+		block, err := new(Block).parse(s)
+		if err != nil {
+			return nil, err
+		}
+		blocks = append(blocks, block)
+		num_txs, err := readVarint(s)
+		if err != nil {
+			return nil, err
+		}
 		if num_txs != 0 {
-			panic(fmt.Errorf("RuntimeError: %v", "number of txs not 0"))
+			return nil, fmt.Errorf("RuntimeError: %v", "number of txs not 0")
 		}
 	}
-	return NewHeadersMessage(blocks)
+	return NewHeadersMessage(blocks), nil
 }
 
 type SimpleNode struct {
-	testnet bool
+	net     *NetworkParams
 	logging bool
-	socket  interface{}
-	stream  []byte
-}
-
-func NewSimpleNode(host int, port int, testnet bool, logging bool) (Sn *SimpleNode) {
-	Sn = new(SimpleNode)
-	testnet = false
-	logging = false
-	if &port == nil {
-		if bool(testnet) {
-			port = 18333
-		} else {
-			port = 8333
-		}
+	socket  net.Conn
+}
+
+//NewSimpleNode connects to host on params, a port of 0 means the network's default
+func NewSimpleNode(host string, port int, params *NetworkParams, logging bool) (*SimpleNode, error) {
+	if port == 0 {
+		port = params.defaultPort
+	}
+	socket, err := net.Dial("tcp", net.JoinHostPort(host, fmt.Sprint(port)))
+	if err != nil {
+		return nil, err
 	}
-	Sn.testnet = testnet
+	Sn := new(SimpleNode)
+	Sn.net = params
 	Sn.logging = logging
-	Sn.socket = socket.socket(socket.AF_INET, socket.SOCK_STREAM)
-	Sn.socket.connect([2]int{host, port})
-	Sn.stream = Sn.socket.makefile("rb", nil)
-	return
+	Sn.socket = socket
+	return Sn, nil
 }
 
-func handshake(Sn *SimpleNode) {
+func (Sn *SimpleNode) close() error {
+	return Sn.socket.Close()
+}
+
+func (Sn *SimpleNode) handshake() error {
 	//Do a handshake with the other node.
 	//Handshake is sending a version message and getting a verack back.'''
-	version := new(VersionMessage)
-	Sn.send(version)
-	//Sn.waitFor(VerAckMessage)
+	if err := Sn.send(NewDefaultVersionMessage(Sn.net)); err != nil {
+		return err
+	}
+	_, err := Sn.waitFor("verack")
+	return err
 }
 
-func (Sn *SimpleNode) send(message *VersionMessage) {
+func (Sn *SimpleNode) send(message Message) error {
 	//"Send a message to the connected node"
-	envelope := NewNetworkEnvelope(message.command, message.serialize(), Sn.testnet)
+	envelope := NewNetworkEnvelope(message.Command(), message.serialize(), Sn.net)
 	if Sn.logging {
-		fmt.Println("sending: %d", (envelope))
+		fmt.Printf("sending: %s\n", envelope.Repr())
 	}
-	Sn.socket.sendall(envelope.serialize())
+	_, err := Sn.socket.Write(envelope.serialize())
+	return err
 }
 
-func (Sn *SimpleNode) read() *NetworkEnvelope {
+func (Sn *SimpleNode) read() (*NetworkEnvelope, error) {
 	//"Read a message from the socket"
-	ne := new(NetworkEnvelope)
-	envelope := ne.parse(Sn.stream, Sn.testnet)
+	envelope, err := new(NetworkEnvelope).parse(Sn.socket, Sn.net)
+	if err != nil {
+		return nil, err
+	}
 	if Sn.logging {
-		fmt.Println("receiving: %d", (envelope))
+		fmt.Printf("receiving: %s\n", envelope.Repr())
 	}
-	return envelope
+	return envelope, nil
 }
 
-//some significant issues that need more pairs of eyes
-/*
-func (Sn *SimpleNode) waitFor(messageClasses *VerAckMessage) {
-	//"Wait for one of the messages in the list"
-	command := nil
-	command_to_class := func() (d map[interface{}]interface{}) {
-		d = make(map[interface{}]interface{})
-		for _, m := range messageClasses {
-			d[m.command] = m
+//waitFor reads until one of commands arrives and returns its envelope.
+//A version is answered with a verack and a ping with a pong on the way.
+func (Sn *SimpleNode) waitFor(commands ...string) (*NetworkEnvelope, error) {
+	for {
+		envelope, err := Sn.read()
+		if err != nil {
+			return nil, err
+		}
+		command := string(envelope.command)
+		for _, c := range commands {
+			if command == c {
+				return envelope, nil
+			}
+		}
+		if command == "version" {
+			err = Sn.send(NewVerAckMessage())
+		} else if command == "ping" {
+			err = Sn.send(NewPongMessage(envelope.payload))
 		}
-		return
-	}()
-	for !func() bool {
-		_, ok := command_to_class[command]
-		return ok
-	}() {
-		//envelope = Sn.read()
-		command = envelope.command
-		if command == VersionMessage.command {
-			Sn.send(VerAckMessage())
-		} else if command == PingMessage.command {
-			Sn.send(PongMessage(envelope.payload))
+		if err != nil {
+			return nil, err
 		}
 	}
-	command_to_class[command].parse(envelope.stream())
 }
-*/
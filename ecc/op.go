@@ -1,26 +1,27 @@
 package ecc
 
 import (
+	"bytes"
 	"crypto/sha1"
 	"crypto/sha256"
-	"errors"
-	"math"
-	"reflect"
-	"strings"
+	"math/big"
 
 	"golang.org/x/crypto/ripemd160"
 )
 
 func encodeNum(num int) []byte {
-	if reflect.DeepEqual(num, 0) {
+	if num == 0 {
 		return []byte("")
 	}
-	absNum := math.Abs(float64(num))
-	negative := int(num) < 0
+	absNum := num
+	negative := num < 0
+	if negative {
+		absNum = -num
+	}
 	var result []byte
 	for absNum != 0 {
-		result = append(result, byte(int(absNum)&255))
-		int(absNum) >>= 8
+		result = append(result, byte(absNum&255))
+		absNum >>= 8
 	}
 	//if the top bit is set,
 	//for negative numbers we ensure that the top bit is set
@@ -34,34 +35,36 @@ func encodeNum(num int) []byte {
 	} else if negative {
 		result[len(result)-1] |= 128
 	}
-	return []byte(result)
+	return result
 }
 
+//reverse flips the byte order, hashes are shown byte reversed
 func reverse(str string) string {
-	output := ""
-	for _, char := range str {
-		output = string(char) + output
+	output := []byte(str)
+	for i, j := 0, len(output)-1; i < j; i, j = i+1, j-1 {
+		output[i], output[j] = output[j], output[i]
 	}
-	return output
+	return string(output)
 }
 
-func decodeNum(element string) int {
+func decodeNum(element []byte) int {
 	var negative bool
 	var result int
-	if reflect.DeepEqual(element, []byte("")) {
+	if len(element) == 0 {
 		return 0
 	}
-	bigEndian := reverse(element)
-	if bigEndian[0]&128 != 0 {
+	//the top byte holds the sign bit
+	top := element[len(element)-1]
+	if top&128 != 0 {
 		negative = true
-		result = int(bigEndian[0]) & 127
+		result = int(top) & 127
 	} else {
 		negative = false
-		result = int(bigEndian[0])
+		result = int(top)
 	}
-	for _, c := range bigEndian[1:] {
+	for i := len(element) - 2; i >= 0; i-- {
 		result <<= 8
-		result += int(c)
+		result += int(element[i])
 	}
 	if negative {
 		return -result
@@ -70,1107 +73,720 @@ func decodeNum(element string) int {
 	}
 }
 
-func op0(stack []byte, s interface{}) bool {
-	stack = append(stack, encodeNum(0)...)
+//castToBool is false for any encoding of zero, negative zero included
+func castToBool(element []byte) bool {
+	for i, c := range element {
+		if c != 0 {
+			return i != len(element)-1 || c != 128
+		}
+	}
+	return false
+}
+
+//pop removes the top element of the stack and returns it
+func pop(stack *[][]byte) []byte {
+	i := len(*stack) - 1
+	popped := (*stack)[i]
+	*stack = (*stack)[:i]
+	return popped
+}
+
+//remove takes out the element at index i, counted from the bottom
+func remove(stack *[][]byte, i int) []byte {
+	removed := (*stack)[i]
+	*stack = append((*stack)[:i:i], (*stack)[i+1:]...)
+	return removed
+}
+
+func push(stack *[][]byte, elements ...[]byte) {
+	*stack = append(*stack, elements...)
+}
+
+//pushNum is the book's stack.append(encode_num(n))
+func pushNum(stack *[][]byte, num int) {
+	push(stack, encodeNum(num))
+}
+
+//pushBool pushes 1 for true and 0 for false
+func pushBool(stack *[][]byte, b bool) {
+	if b {
+		pushNum(stack, 1)
+	} else {
+		pushNum(stack, 0)
+	}
+}
+
+//LockContext is what OP_CHECKLOCKTIMEVERIFY and OP_CHECKSEQUENCEVERIFY
+//compare against, the spending transaction's fields for the input
+type LockContext struct {
+	locktime int64
+	sequence int64
+	version  int64
+}
+
+func op0(stack *[][]byte, s interface{}) bool {
+	pushNum(stack, 0)
 	return true
 }
 
-func op1negate(stack []byte, s interface{}) bool {
-	stack = append(stack, encodeNum(-1)...)
+func op1negate(stack *[][]byte, s interface{}) bool {
+	pushNum(stack, -1)
 	return true
 }
 
-func op1(stack []byte, s interface{}) bool {
-	stack = append(stack, encodeNum(1)...)
+func op1(stack *[][]byte, s interface{}) bool {
+	pushNum(stack, 1)
 	return true
 }
 
-func op2(stack []byte, s interface{}) bool {
-	stack = append(stack, encodeNum(2)...)
+func op2(stack *[][]byte, s interface{}) bool {
+	pushNum(stack, 2)
 	return true
 }
 
-func op3(stack []byte, s interface{}) bool {
-	stack = append(stack, encodeNum(3)...)
+func op3(stack *[][]byte, s interface{}) bool {
+	pushNum(stack, 3)
 	return true
 }
 
-func op4(stack []byte, s interface{}) bool {
-	stack = append(stack, encodeNum(4)...)
+func op4(stack *[][]byte, s interface{}) bool {
+	pushNum(stack, 4)
 	return true
 }
 
-func op5(stack []byte, s interface{}) bool {
-	stack = append(stack, encodeNum(5)...)
+func op5(stack *[][]byte, s interface{}) bool {
+	pushNum(stack, 5)
 	return true
 }
 
-func op6(stack []byte, s interface{}) bool {
-	stack = append(stack, encodeNum(6)...)
+func op6(stack *[][]byte, s interface{}) bool {
+	pushNum(stack, 6)
 	return true
 }
 
-func op7(stack []byte, s interface{}) bool {
-	stack = append(stack, encodeNum(7)...)
+func op7(stack *[][]byte, s interface{}) bool {
+	pushNum(stack, 7)
 	return true
 }
 
-func op8(stack []byte, s interface{}) bool {
-	stack = append(stack, encodeNum(8)...)
+func op8(stack *[][]byte, s interface{}) bool {
+	pushNum(stack, 8)
 	return true
 }
 
-func op9(stack []byte, s interface{}) bool {
-	stack = append(stack, encodeNum(9)...)
+func op9(stack *[][]byte, s interface{}) bool {
+	pushNum(stack, 9)
 	return true
 }
 
-func op10(stack []byte, s interface{}) bool {
-	stack = append(stack, encodeNum(10)...)
+func op10(stack *[][]byte, s interface{}) bool {
+	pushNum(stack, 10)
 	return true
 }
 
-func op11(stack []byte, s interface{}) bool {
-	stack = append(stack, encodeNum(11)...)
+func op11(stack *[][]byte, s interface{}) bool {
+	pushNum(stack, 11)
 	return true
 }
 
-func op12(stack []byte, s interface{}) bool {
-	stack = append(stack, encodeNum(12)...)
+func op12(stack *[][]byte, s interface{}) bool {
+	pushNum(stack, 12)
 	return true
 }
 
-func op13(stack []byte, s interface{}) bool {
-	stack = append(stack, encodeNum(13)...)
+func op13(stack *[][]byte, s interface{}) bool {
+	pushNum(stack, 13)
 	return true
 }
 
-func op14(stack []byte, s interface{}) bool {
-	stack = append(stack, encodeNum(14)...)
+func op14(stack *[][]byte, s interface{}) bool {
+	pushNum(stack, 14)
 	return true
 }
 
-func op15(stack []byte, s interface{}) bool {
-	stack = append(stack, encodeNum(15)...)
+func op15(stack *[][]byte, s interface{}) bool {
+	pushNum(stack, 15)
 	return true
 }
 
-func op16(stack []byte, s interface{}) bool {
-	stack = append(stack, encodeNum(16)...)
+func op16(stack *[][]byte, s interface{}) bool {
+	pushNum(stack, 16)
 	return true
 }
 
-func opNop(stack []byte, s interface{}) bool {
+func opNop(stack *[][]byte, s interface{}) bool {
 	return true
 }
 
-func opIf(stack []byte, items []byte) bool {
-	if len(stack) < 1 {
-		return false
-	}
-	var trueItems []byte
-	var falseItems []byte
-	currentArray := trueItems
-	found := false
+//splitIf takes the commands up to the matching OP_ENDIF off items and
+//returns the two branches, found is false when there is no OP_ENDIF
+func splitIf(items *[]interface{}) (trueItems, falseItems []interface{}, found bool) {
+	inFalse := false
 	numEndifsNeeded := 1
-	for len(items) > 0 {
-		item := func(s *[]byte, i int) byte {
-			popped := (*s)[i]
-			*s = append((*s)[:i], (*s)[i+1:]...)
-			return popped
-		}(&items, 0)
-		if func() int {
-			for i, v := range [2]int{99, 100} {
-				if byte(v) == item {
-					return i
-				}
-			}
-			return -1
-		}() != -1 {
+	for len(*items) > 0 {
+		item := (*items)[0]
+		*items = (*items)[1:]
+		op, isOp := item.(int)
+		if isOp && (op == 99 || op == 100) {
 			numEndifsNeeded += 1
-			currentArray = append(currentArray, item)
-		} else if numEndifsNeeded == 1 && reflect.DeepEqual(item, 103) {
-			currentArray = falseItems
-		} else if reflect.DeepEqual(item, 104) {
+		} else if isOp && numEndifsNeeded == 1 && op == 103 {
+			inFalse = true
+			continue
+		} else if isOp && op == 104 {
 			if numEndifsNeeded == 1 {
-				found = true
-				break
-			} else {
-				numEndifsNeeded -= 1
-				currentArray = append(currentArray, item)
+				return trueItems, falseItems, true
 			}
+			numEndifsNeeded -= 1
+		}
+		if inFalse {
+			falseItems = append(falseItems, item)
 		} else {
-			currentArray = append(currentArray, item)
+			trueItems = append(trueItems, item)
 		}
 	}
+	return nil, nil, false
+}
+
+//opIf and opNotIf get a pointer to the commands still to run and put the
+//branch that is taken in front of them
+func opIf(stack *[][]byte, items interface{}) bool {
+	cmds, ok := items.(*[]interface{})
+	if !ok || len(*stack) < 1 {
+		return false
+	}
+	trueItems, falseItems, found := splitIf(cmds)
 	if !found {
 		return false
 	}
-	element := func(s *[]byte) string {
-		i := len(*s) - 1
-		popped := (*s)[i]
-		*s = (*s)[:i]
-		return string(popped)
-	}(&stack)
-	if decodeNum(element) == 0 {
-		items[:0] = falseItems
+	if castToBool(pop(stack)) {
+		*cmds = append(trueItems, *cmds...)
 	} else {
-		items[:0] = trueItems
+		*cmds = append(falseItems, *cmds...)
 	}
 	return true
 }
 
-func opNotIf(stack, items []byte) bool {
-	if len(stack) < 1 {
+func opNotIf(stack *[][]byte, items interface{}) bool {
+	cmds, ok := items.(*[]interface{})
+	if !ok || len(*stack) < 1 {
 		return false
 	}
-	trueItems := []interface{}{}
-	falseItems := []interface{}{}
-	currentArray := trueItems
-	found := false
-	numEndifsNeeded := 1
-	for len(items) > 0 {
-		item := func(s *[]byte, i int) byte {
-			popped := (*s)[i]
-			*s = append((*s)[:i], (*s)[i+1:]...)
-			return popped
-		}(&items, 0)
-		if func() int {
-			for i, v := range [2]int{99, 100} {
-				if byte(v) == item {
-					return i
-				}
-			}
-			return -1
-		}() != -1 {
-			numEndifsNeeded += 1
-			currentArray = append(currentArray, item)
-		} else if numEndifsNeeded == 1 && reflect.DeepEqual(item, 103) {
-			currentArray = falseItems
-		} else if reflect.DeepEqual(item, 104) {
-			if numEndifsNeeded == 1 {
-				found = true
-				break
-			} else {
-				numEndifsNeeded -= 1
-				currentArray = append(currentArray, item)
-			}
-		} else {
-			currentArray = append(currentArray, item)
-		}
-	}
+	trueItems, falseItems, found := splitIf(cmds)
 	if !found {
 		return false
 	}
-	element := func(s *[]byte) byte {
-		i := len(*s) - 1
-		popped := (*s)[i]
-		*s = (*s)[:i]
-		return popped
-	}(&stack)
-	if decodeNum(string(element)) == 0 {
-		items[:0] = trueItems
+	if castToBool(pop(stack)) {
+		*cmds = append(falseItems, *cmds...)
 	} else {
-		items[:0] = falseItems
+		*cmds = append(trueItems, *cmds...)
 	}
 	return true
 }
 
-func opVerify(stack []byte, s interface{}) bool {
-	if len(stack) < 1 {
+func opVerify(stack *[][]byte, s interface{}) bool {
+	if len(*stack) < 1 {
 		return false
 	}
-	element := func(s *[]byte) string { //pass by pointer so that it actually changes
-		i := len(*s) - 1
-		popped := (*s)[i]
-		*s = (*s)[:i]
-		return string(popped)
-	}(&stack)
-	if decodeNum(element) == 0 {
-		return false
-	}
-	return true
+	return castToBool(pop(stack))
 }
 
-func opReturn(stack []byte, s interface{}) bool {
+func opReturn(stack *[][]byte, s interface{}) bool {
 	return false
 }
 
-func opToAltStack(stack []byte, altstack []byte) bool {
-	if len(stack) < 1 {
+func opToAltStack(stack *[][]byte, altstack interface{}) bool {
+	alt, ok := altstack.(*[][]byte)
+	if !ok || len(*stack) < 1 {
 		return false
 	}
-	altstack = append(altstack, func(s *[]byte) byte {
-		i := len(*s) - 1
-		popped := (*s)[i]
-		*s = (*s)[:i]
-		return popped
-	}(&stack))
+	push(alt, pop(stack))
 	return true
 }
 
-func opFromAltStack(stack []byte, altstack []byte) bool {
-	if len(altstack) < 1 {
+func opFromAltStack(stack *[][]byte, altstack interface{}) bool {
+	alt, ok := altstack.(*[][]byte)
+	if !ok || len(*alt) < 1 {
 		return false
 	}
-	stack = append(stack, func(s *[]byte) byte {
-		i := len(*s) - 1
-		popped := (*s)[i]
-		*s = (*s)[:i]
-		return popped
-	}(&altstack))
+	push(stack, pop(alt))
 	return true
 }
 
-func op2Drop(stack []byte, s interface{}) bool {
-	if len(stack) < 2 {
+func op2Drop(stack *[][]byte, s interface{}) bool {
+	if len(*stack) < 2 {
 		return false
 	}
-	func(s *[]byte) interface{} {
-		i := len(*s) - 1
-		popped := (*s)[i]
-		*s = (*s)[:i]
-		return popped
-	}(&stack)
-	func(s *[]byte) interface{} {
-		i := len(*s) - 1
-		popped := (*s)[i]
-		*s = (*s)[:i]
-		return popped
-	}(&stack)
+	pop(stack)
+	pop(stack)
 	return true
 }
 
-func op2Dup(stack []byte, s interface{}) bool {
-	if len(stack) < 2 {
+func op2Dup(stack *[][]byte, s interface{}) bool {
+	if len(*stack) < 2 {
 		return false
 	}
-	stack = append(stack, stack[len(stack)-2:]...)
+	push(stack, (*stack)[len(*stack)-2:]...)
 	return true
 }
 
-func op3Dup(stack []byte, s interface{}) bool {
-	if len(stack) < 3 {
+func op3Dup(stack *[][]byte, s interface{}) bool {
+	if len(*stack) < 3 {
 		return false
 	}
-	stack = append(stack, stack[len(stack)-3:]...)
+	push(stack, (*stack)[len(*stack)-3:]...)
 	return true
 }
 
-func op2Over(stack []byte, s interface{}) bool {
-	if len(stack) < 4 {
+func op2Over(stack *[][]byte, s interface{}) bool {
+	if len(*stack) < 4 {
 		return false
 	}
-	stack = append(stack, stack[len(stack)-4:len(stack)-2]...)
+	push(stack, (*stack)[len(*stack)-4:len(*stack)-2]...)
 	return true
 }
 
-func op2Rot(stack []byte, s interface{}) bool {
-	if len(stack) < 6 {
+func op2Rot(stack *[][]byte, s interface{}) bool {
+	if len(*stack) < 6 {
 		return false
 	}
-	stack = append(stack, stack[len(stack)-6:len(stack)-4]...)
+	i := len(*stack) - 6
+	first := remove(stack, i)
+	second := remove(stack, i)
+	push(stack, first, second)
 	return true
 }
 
-func op2Swap(stack []byte, s interface{}) bool {
-	if len(stack) < 4 {
+func op2Swap(stack *[][]byte, s interface{}) bool {
+	if len(*stack) < 4 {
 		return false
 	}
-	stack[len(stack)-4:] = append(stack[len(stack)-2:], stack[len(stack)-4:len(stack)-2]...)
+	i := len(*stack) - 4
+	first := remove(stack, i)
+	second := remove(stack, i)
+	push(stack, first, second)
 	return true
 }
 
-func opIfDup(stack []byte, s interface{}) bool {
-	if len(stack) < 1 {
+func opIfDup(stack *[][]byte, s interface{}) bool {
+	if len(*stack) < 1 {
 		return false
 	}
-	if decodeNum(string(stack[len(stack)-1])) != 0 {
-		stack = append(stack, stack[len(stack)-1])
+	top := (*stack)[len(*stack)-1]
+	if castToBool(top) {
+		push(stack, top)
 	}
 	return true
 }
 
-func opDepth(stack []byte, s interface{}) bool {
-	stack = append(stack, encodeNum(len(stack))...)
+func opDepth(stack *[][]byte, s interface{}) bool {
+	pushNum(stack, len(*stack))
 	return true
 }
 
-func opDrop(stack []byte, s interface{}) bool {
-	if len(stack) < 1 {
+func opDrop(stack *[][]byte, s interface{}) bool {
+	if len(*stack) < 1 {
 		return false
 	}
-	func(s *[]byte) interface{} {
-		i := len(*s) - 1
-		popped := (*s)[i]
-		*s = (*s)[:i]
-		return popped
-	}(&stack)
+	pop(stack)
 	return true
 }
 
-func opDup(stack []byte, s interface{}) bool {
-	if len(stack) < 1 {
+func opDup(stack *[][]byte, s interface{}) bool {
+	if len(*stack) < 1 {
 		return false
 	}
-	stack = append(stack, stack[len(stack)-1])
+	push(stack, (*stack)[len(*stack)-1])
 	return true
 }
 
-func opNip(stack []byte, s interface{}) bool {
-	if len(stack) < 2 {
+func opNip(stack *[][]byte, s interface{}) bool {
+	if len(*stack) < 2 {
 		return false
 	}
-	//copy(stack[len(stack)-2:], stack[len(stack)-1:])
-	stack[len(stack)-2:] = stack[len(stack)-1:]
-
+	remove(stack, len(*stack)-2)
 	return true
 }
 
-func opOver(stack []byte, s interface{}) bool {
-	if len(stack) < 2 {
+func opOver(stack *[][]byte, s interface{}) bool {
+	if len(*stack) < 2 {
 		return false
 	}
-	stack = append(stack, stack[len(stack)-2])
+	push(stack, (*stack)[len(*stack)-2])
 	return true
 }
 
-func opPick(stack []byte, s interface{}) bool {
-	if len(stack) < 1 {
+func opPick(stack *[][]byte, s interface{}) bool {
+	if len(*stack) < 1 {
 		return false
 	}
-	n := decodeNum(func(s *[]byte) string {
-		i := len(*s) - 1
-		popped := (*s)[i]
-		*s = (*s)[:i]
-		return string(popped)
-	}(&stack))
-	if len(stack) < n+1 {
+	n := decodeNum(pop(stack))
+	if n < 0 || len(*stack) < n+1 {
 		return false
 	}
-	stack = append(stack, stack[-n-1])
+	push(stack, (*stack)[len(*stack)-n-1])
 	return true
 }
 
-func opRoll(stack []byte, s interface{}) bool {
-	if len(stack) < 1 {
+func opRoll(stack *[][]byte, s interface{}) bool {
+	if len(*stack) < 1 {
 		return false
 	}
-	n := decodeNum(func(s *[]byte) string {
-		i := len(*s) - 1
-		popped := (*s)[i]
-		*s = (*s)[:i]
-		return string(popped)
-	}(&stack))
-	if len(stack) < n+1 {
+	n := decodeNum(pop(stack))
+	if n < 0 || len(*stack) < n+1 {
 		return false
 	}
-	if n == 0 {
-		return true
-	}
-	stack = append(stack, func(s *[]byte, i int) byte {
-		popped := (*s)[i]
-		*s = append((*s)[:i], (*s)[i+1:]...)
-		return popped
-	}(&stack, -n-1))
+	push(stack, remove(stack, len(*stack)-n-1))
 	return true
 }
 
-func opRot(stack []byte, s interface{}) bool {
-	if len(stack) < 3 {
+func opRot(stack *[][]byte, s interface{}) bool {
+	if len(*stack) < 3 {
 		return false
 	}
-	stack = append(stack, func(s *[]byte, i int) byte {
-		popped := (*s)[i]
-		*s = append((*s)[:i], (*s)[i+1:]...)
-		return popped
-	}(&stack, -3))
+	push(stack, remove(stack, len(*stack)-3))
 	return true
 }
 
-func opSwap(stack []byte, s interface{}) bool {
-	if len(stack) < 2 {
+func opSwap(stack *[][]byte, s interface{}) bool {
+	if len(*stack) < 2 {
 		return false
 	}
-	stack = append(stack, func(s *[]byte, i int) byte {
-		popped := (*s)[i]
-		*s = append((*s)[:i], (*s)[i+1:]...)
-		return popped
-	}(&stack, -2))
+	push(stack, remove(stack, len(*stack)-2))
 	return true
 }
 
-func opTuck(stack []byte, s interface{}) bool {
-	if len(stack) < 2 {
+func opTuck(stack *[][]byte, s interface{}) bool {
+	if len(*stack) < 2 {
 		return false
 	}
-	stack = append(stack, stack[len(stack)-1])
-	copy(stack[len(stack)-2+1:], stack[len(stack)-2:])
-	stack[len(stack)-2] = stack[len(stack)-1]
+	top := pop(stack)
+	second := pop(stack)
+	push(stack, top, second, top)
 	return true
 }
 
-func opSize(stack []byte, s interface{}) bool {
-	if len(stack) < 1 {
+func opSize(stack *[][]byte, s interface{}) bool {
+	if len(*stack) < 1 {
 		return false
 	}
-	stack = append(stack, encodeNum(len(string(stack[len(stack)-1])))...)
+	pushNum(stack, len((*stack)[len(*stack)-1]))
 	return true
 }
 
-func opEqual(stack []byte, s interface{}) bool {
-	if len(stack) < 2 {
+func opEqual(stack *[][]byte, s interface{}) bool {
+	if len(*stack) < 2 {
 		return false
 	}
-	element1 := func(s *[]byte) interface{} {
-		i := len(*s) - 1
-		popped := (*s)[i]
-		*s = (*s)[:i]
-		return popped
-	}(&stack)
-	element2 := func(s *[]byte) interface{} {
-		i := len(*s) - 1
-		popped := (*s)[i]
-		*s = (*s)[:i]
-		return popped
-	}(&stack)
-	if reflect.DeepEqual(element1, element2) {
-		stack = append(stack, encodeNum(1)...)
-	} else {
-		stack = append(stack, encodeNum(0)...)
-	}
+	element1 := pop(stack)
+	element2 := pop(stack)
+	pushBool(stack, bytes.Equal(element1, element2))
 	return true
 }
 
-func opEqualVerify(stack []byte, s interface{}) bool {
-	return opEqual(stack) && opVerify(stack)
+func opEqualVerify(stack *[][]byte, s interface{}) bool {
+	return opEqual(stack, s) && opVerify(stack, s)
 }
 
-func op1Add(stack []byte) bool {
-	if len(stack) < 1 {
+//unaryNum pops one number and pushes f of it
+func unaryNum(stack *[][]byte, f func(int) int) bool {
+	if len(*stack) < 1 {
 		return false
 	}
-	element := decodeNum(func(s *[]byte) string {
-		i := len(*s) - 1
-		popped := (*s)[i]
-		*s = (*s)[:i]
-		return string(popped)
-	}(&stack))
-	stack = append(stack, encodeNum(element+1)...)
+	pushNum(stack, f(decodeNum(pop(stack))))
 	return true
 }
 
-func op1Sub(stack []byte, s interface{}) bool {
-	if len(stack) < 1 {
+//binaryNum pops b then a and pushes f(a, b)
+func binaryNum(stack *[][]byte, f func(a, b int) int) bool {
+	if len(*stack) < 2 {
 		return false
 	}
-	element := decodeNum(func(s *[]byte) string {
-		i := len(*s) - 1
-		popped := (*s)[i]
-		*s = (*s)[:i]
-		return string(popped)
-	}(&stack))
-	stack = append(stack, encodeNum(element-1)...)
+	b := decodeNum(pop(stack))
+	a := decodeNum(pop(stack))
+	pushNum(stack, f(a, b))
 	return true
 }
 
-func opNegate(stack []byte, s interface{}) bool {
-	if len(stack) < 1 {
-		return false
+func boolNum(b bool) int {
+	if b {
+		return 1
 	}
-	element := decodeNum(func(s *[]byte) string {
-		i := len(*s) - 1
-		popped := (*s)[i]
-		*s = (*s)[:i]
-		return string(popped)
-	}(&stack))
-	stack = append(stack, encodeNum(-element)...)
-	return true
+	return 0
 }
 
-func opAbs(stack []byte, s interface{}) bool {
-	if len(stack) < 1 {
-		return false
-	}
-	element := decodeNum(func(s *[]byte) string {
-		i := len(*s) - 1
-		popped := (*s)[i]
-		*s = (*s)[:i]
-		return string(popped)
-	}(&stack))
-	if element < 0 {
-		stack = append(stack, encodeNum(-element)...)
-	} else {
-		stack = append(stack, encodeNum(element)...)
-	}
-	return true
+func op1Add(stack *[][]byte, s interface{}) bool {
+	return unaryNum(stack, func(a int) int { return a + 1 })
 }
 
-func opNot(stack []byte, s interface{}) bool {
-	if len(stack) < 1 {
-		return false
-	}
-	element := func(s *[]byte) string {
-		i := len(*s) - 1
-		popped := (*s)[i]
-		*s = (*s)[:i]
-		return string(popped)
-	}(&stack)
-	if decodeNum(element) == 0 {
-		stack = append(stack, encodeNum(1)...)
-	} else {
-		stack = append(stack, encodeNum(0)...)
-	}
-	return true
+func op1Sub(stack *[][]byte, s interface{}) bool {
+	return unaryNum(stack, func(a int) int { return a - 1 })
 }
 
-func op0NotEqual(stack []byte, s interface{}) bool {
-	if len(stack) < 1 {
-		return false
-	}
-	element := func(s *[]byte) string {
-		i := len(*s) - 1
-		popped := (*s)[i]
-		*s = (*s)[:i]
-		return string(popped)
-	}(&stack)
-	if decodeNum(element) == 0 {
-		stack = append(stack, encodeNum(0)...)
-	} else {
-		stack = append(stack, encodeNum(1)...)
-	}
-	return true
+func opNegate(stack *[][]byte, s interface{}) bool {
+	return unaryNum(stack, func(a int) int { return -a })
 }
 
-func opAdd(stack []byte, s interface{}) bool {
-	if len(stack) < 2 {
-		return false
-	}
-	element1 := decodeNum(func(s *[]byte) string {
-		i := len(*s) - 1
-		popped := (*s)[i]
-		*s = (*s)[:i]
-		return string(popped)
-	}(&stack))
-	element2 := decodeNum(func(s *[]byte) string {
-		i := len(*s) - 1
-		popped := (*s)[i]
-		*s = (*s)[:i]
-		return string(popped)
-	}(&stack))
-	stack = append(stack, encodeNum(element1+element2)...)
-	return true
+func opAbs(stack *[][]byte, s interface{}) bool {
+	return unaryNum(stack, func(a int) int {
+		if a < 0 {
+			return -a
+		}
+		return a
+	})
 }
 
-func opSub(stack []byte, s interface{}) bool {
-	if len(stack) < 2 {
-		return false
-	}
-	element1 := decodeNum(func(s *[]byte) string {
-		i := len(*s) - 1
-		popped := (*s)[i]
-		*s = (*s)[:i]
-		return string(popped)
-	}(&stack))
-	element2 := decodeNum(func(s *[]byte) string {
-		i := len(*s) - 1
-		popped := (*s)[i]
-		*s = (*s)[:i]
-		return string(popped)
-	}(&stack))
-	stack = append(stack, encodeNum(element2-element1)...)
-	return true
+func opNot(stack *[][]byte, s interface{}) bool {
+	return unaryNum(stack, func(a int) int { return boolNum(a == 0) })
 }
 
-func opBoolAnd(stack []byte, s interface{}) bool {
-	if len(stack) < 2 {
-		return false
-	}
-	element1 := decodeNum(func(s *[]byte) string {
-		i := len(*s) - 1
-		popped := (*s)[i]
-		*s = (*s)[:i]
-		return string(popped)
-	}(&stack))
-	element2 := decodeNum(func(s *[]byte) string {
-		i := len(*s) - 1
-		popped := (*s)[i]
-		*s = (*s)[:i]
-		return string(popped)
-	}(&stack))
-	if element1 && element2 {
-		stack = append(stack, encodeNum(1)...)
-	} else {
-		stack = append(stack, encodeNum(0)...)
-	}
-	return true
+func op0NotEqual(stack *[][]byte, s interface{}) bool {
+	return unaryNum(stack, func(a int) int { return boolNum(a != 0) })
 }
 
-func opBoolOr(stack []byte, s interface{}) bool {
-	if len(stack) < 2 {
-		return false
-	}
-	element1 := decodeNum(func(s *[]byte) string {
-		i := len(*s) - 1
-		popped := (*s)[i]
-		*s = (*s)[:i]
-		return string(popped)
-	}(&stack))
-	element2 := decodeNum(func(s *[]byte) string {
-		i := len(*s) - 1
-		popped := (*s)[i]
-		*s = (*s)[:i]
-		return string(popped)
-	}(&stack))
-	if element1 || element2 {
-		stack = append(stack, encodeNum(1)...)
-	} else {
-		stack = append(stack, encodeNum(0)...)
-	}
-	return true
+func opAdd(stack *[][]byte, s interface{}) bool {
+	return binaryNum(stack, func(a, b int) int { return a + b })
 }
 
-func opNumEqual(stack []byte, s interface{}) bool {
-	if len(stack) < 2 {
-		return false
-	}
-	element1 := decodeNum(func(s *[]byte) string {
-		i := len(*s) - 1
-		popped := (*s)[i]
-		*s = (*s)[:i]
-		return string(popped)
-	}(&stack))
-	element2 := decodeNum(func(s *[]byte) string {
-		i := len(*s) - 1
-		popped := (*s)[i]
-		*s = (*s)[:i]
-		return string(popped)
-	}(&stack))
-	if element1 == element2 {
-		stack = append(stack, encodeNum(1)...)
-	} else {
-		stack = append(stack, encodeNum(0)...)
-	}
-	return true
+func opSub(stack *[][]byte, s interface{}) bool {
+	return binaryNum(stack, func(a, b int) int { return a - b })
 }
 
-func opNumEqualVerify(stack []byte, s interface{}) bool {
-	return opNumEqual(stack) && opVerify(stack)
+func opBoolAnd(stack *[][]byte, s interface{}) bool {
+	return binaryNum(stack, func(a, b int) int { return boolNum(a != 0 && b != 0) })
 }
 
-func opNumNotEqual(stack []byte, s interface{}, s interface{}) bool {
-	if len(stack) < 2 {
-		return false
-	}
-	element1 := decodeNum(func(s *[]byte) string {
-		i := len(*s) - 1
-		popped := (*s)[i]
-		*s = (*s)[:i]
-		return string(popped)
-	}(&stack))
-	element2 := decodeNum(func(s *[]byte) string {
-		i := len(*s) - 1
-		popped := (*s)[i]
-		*s = (*s)[:i]
-		return string(popped)
-	}(&stack))
-	if element1 == element2 {
-		stack = append(stack, encodeNum(0)...)
-	} else {
-		stack = append(stack, encodeNum(1)...)
-	}
-	return true
+func opBoolOr(stack *[][]byte, s interface{}) bool {
+	return binaryNum(stack, func(a, b int) int { return boolNum(a != 0 || b != 0) })
 }
 
-func opLessThan(stack []byte, s interface{}) bool {
-	if len(stack) < 2 {
-		return false
-	}
-	element1 := decodeNum(func(s *[]byte) string {
-		i := len(*s) - 1
-		popped := (*s)[i]
-		*s = (*s)[:i]
-		return string(popped)
-	}(&stack))
-	element2 := decodeNum(func(s *[]byte) string {
-		i := len(*s) - 1
-		popped := (*s)[i]
-		*s = (*s)[:i]
-		return string(popped)
-	}(&stack))
-	if element2 < element1 {
-		stack = append(stack, encodeNum(1)...)
-	} else {
-		stack = append(stack, encodeNum(0)...)
-	}
-	return true
+func opNumEqual(stack *[][]byte, s interface{}) bool {
+	return binaryNum(stack, func(a, b int) int { return boolNum(a == b) })
 }
 
-func opGreaterThan(stack []byte, s interface{}) bool {
-	if len(stack) < 2 {
-		return false
-	}
-	element1 := decodeNum(func(s *[]byte) string {
-		i := len(*s) - 1
-		popped := (*s)[i]
-		*s = (*s)[:i]
-		return string(popped)
-	}(&stack))
-	element2 := decodeNum(func(s *[]byte) string {
-		i := len(*s) - 1
-		popped := (*s)[i]
-		*s = (*s)[:i]
-		return string(popped)
-	}(&stack))
-	if element2 > element1 {
-		stack = append(stack, encodeNum(1)...)
-	} else {
-		stack = append(stack, encodeNum(0)...)
-	}
-	return true
+func opNumEqualVerify(stack *[][]byte, s interface{}) bool {
+	return opNumEqual(stack, s) && opVerify(stack, s)
 }
 
-func opLessThanOrEqual(stack []byte, s interface{}) bool {
-	if len(stack) < 2 {
-		return false
-	}
-	element1 := decodeNum(func(s *[]byte) string {
-		i := len(*s) - 1
-		popped := (*s)[i]
-		*s = (*s)[:i]
-		return string(popped)
-	}(&stack))
-	element2 := decodeNum(func(s *[]byte) string {
-		i := len(*s) - 1
-		popped := (*s)[i]
-		*s = (*s)[:i]
-		return string(popped)
-	}(&stack))
-	if element2 <= element1 {
-		stack = append(stack, encodeNum(1)...)
-	} else {
-		stack = append(stack, encodeNum(0)...)
-	}
-	return true
+func opNumNotEqual(stack *[][]byte, s interface{}) bool {
+	return binaryNum(stack, func(a, b int) int { return boolNum(a != b) })
 }
 
-func opGreaterThanOrEqual(stack []byte, s interface{}) bool {
-	if len(stack) < 2 {
-		return false
-	}
-	element1 := decodeNum(func(s *[]byte) string {
-		i := len(*s) - 1
-		popped := (*s)[i]
-		*s = (*s)[:i]
-		return string(popped)
-	}(&stack))
-	element2 := decodeNum(func(s *[]byte) string {
-		i := len(*s) - 1
-		popped := (*s)[i]
-		*s = (*s)[:i]
-		return string(popped)
-	}(&stack))
-	if element2 >= element1 {
-		stack = append(stack, encodeNum(1)...)
-	} else {
-		stack = append(stack, encodeNum(0)...)
-	}
-	return true
+func opLessThan(stack *[][]byte, s interface{}) bool {
+	return binaryNum(stack, func(a, b int) int { return boolNum(a < b) })
 }
 
-func opMin(stack []byte, s interface{}) bool {
-	if len(stack) < 2 {
+func opGreaterThan(stack *[][]byte, s interface{}) bool {
+	return binaryNum(stack, func(a, b int) int { return boolNum(a > b) })
+}
+
+func opLessThanOrEqual(stack *[][]byte, s interface{}) bool {
+	return binaryNum(stack, func(a, b int) int { return boolNum(a <= b) })
+}
+
+func opGreaterThanOrEqual(stack *[][]byte, s interface{}) bool {
+	return binaryNum(stack, func(a, b int) int { return boolNum(a >= b) })
+}
+
+func opMin(stack *[][]byte, s interface{}) bool {
+	return binaryNum(stack, func(a, b int) int {
+		if a < b {
+			return a
+		}
+		return b
+	})
+}
+
+func opMax(stack *[][]byte, s interface{}) bool {
+	return binaryNum(stack, func(a, b int) int {
+		if a > b {
+			return a
+		}
+		return b
+	})
+}
+
+func opWithin(stack *[][]byte, s interface{}) bool {
+	if len(*stack) < 3 {
 		return false
 	}
-	element1 := decodeNum(func(s *[]byte) string {
-		i := len(*s) - 1
-		popped := (*s)[i]
-		*s = (*s)[:i]
-		return string(popped)
-	}(&stack))
-	element2 := decodeNum(func(s *[]byte) string {
-		i := len(*s) - 1
-		popped := (*s)[i]
-		*s = (*s)[:i]
-		return string(popped)
-	}(&stack))
-	if element1 < element2 {
-		stack = append(stack, encodeNum(element1)...)
-	} else {
-		stack = append(stack, encodeNum(element2)...)
-	}
+	maximum := decodeNum(pop(stack))
+	minimum := decodeNum(pop(stack))
+	element := decodeNum(pop(stack))
+	pushBool(stack, element >= minimum && element < maximum)
 	return true
 }
 
-func opMax(stack []byte, s interface{}) bool {
-	if len(stack) < 2 {
+func opRipemd160(stack *[][]byte, s interface{}) bool {
+	if len(*stack) < 1 {
 		return false
 	}
-	element1 := decodeNum(func(s *[]byte) string {
-		i := len(*s) - 1
-		popped := (*s)[i]
-		*s = (*s)[:i]
-		return string(popped)
-	}(&stack))
-	element2 := decodeNum(func(s *[]byte) string {
-		i := len(*s) - 1
-		popped := (*s)[i]
-		*s = (*s)[:i]
-		return string(popped)
-	}(&stack))
-	if element1 > element2 {
-		stack = append(stack, encodeNum(element1)...)
-	} else {
-		stack = append(stack, encodeNum(element2)...)
-	}
+	hasher := ripemd160.New()
+	hasher.Write(pop(stack))
+	push(stack, hasher.Sum(nil))
 	return true
 }
 
-func opWithin(stack []byte, s interface{}) bool {
-	if len(stack) < 3 {
+func opSha1(stack *[][]byte, s interface{}) bool {
+	if len(*stack) < 1 {
 		return false
 	}
-	maximum := decodeNum(func(s *[]byte) string {
-		i := len(*s) - 1
-		popped := (*s)[i]
-		*s = (*s)[:i]
-		return string(popped)
-	}(&stack))
-	minimum := decodeNum(func(s *[]byte) string {
-		i := len(*s) - 1
-		popped := (*s)[i]
-		*s = (*s)[:i]
-		return string(popped)
-	}(&stack))
-	element := decodeNum(func(s *[]byte) string {
-		i := len(*s) - 1
-		popped := (*s)[i]
-		*s = (*s)[:i]
-		return string(popped)
-	}(&stack))
-	if element >= minimum && element < maximum {
-		stack = append(stack, encodeNum(1)...)
-	} else {
-		stack = append(stack, encodeNum(0)...)
-	}
+	h := sha1.Sum(pop(stack))
+	push(stack, h[:])
 	return true
 }
 
-func opRipemd160(stack []byte, s interface{}) bool {
-	if len(stack) < 1 {
+func opSha256(stack *[][]byte, s interface{}) bool {
+	if len(*stack) < 1 {
 		return false
 	}
-	element := func(s *[]byte) string {
-		i := len(*s) - 1
-		popped := (*s)[i]
-		*s = (*s)[:i]
-		return string(popped)
-	}(&stack)
-	stack = append(stack, func([]byte) []byte {
-		first := sha256.Sum256([]byte(element))
-		hasher := ripemd160.New()
-		hasher.Write(first[:])
-		hash := hasher.Sum(nil)
-		return hash[:]
-	}([]byte(element))...) //hashlib.new("ripemd160", element).digest())
+	h := sha256.Sum256(pop(stack))
+	push(stack, h[:])
 	return true
 }
 
-func opSha1(stack []byte, s interface{}) bool {
-	if len(stack) < 1 {
+func opHash160(stack *[][]byte, s interface{}) bool {
+	if len(*stack) < 1 {
 		return false
 	}
-	element := func(s *[]byte) string {
-		i := len(*s) - 1
-		popped := (*s)[i]
-		*s = (*s)[:i]
-		return string(popped)
-	}(&stack)
-	stack = append(stack, func() []byte {
-		sha1 := sha1.Sum([]byte(element))
-		return sha1[:]
-	}()...) //hashlib.sha1(element).digest())
+	push(stack, []byte(hash160(string(pop(stack)))))
 	return true
 }
 
-func opSha256(stack []byte, s interface{}) bool {
-	if len(stack) < 1 {
+func opHash256(stack *[][]byte, s interface{}) bool {
+	if len(*stack) < 1 {
 		return false
 	}
-	element := func(s *[]byte) string {
-		i := len(*s) - 1
-		popped := (*s)[i]
-		*s = (*s)[:i]
-		return string(popped)
-	}(&stack)
-	stack = append(stack, func() []byte {
-		sha256 := sha256.Sum256([]byte(element))
-		return sha256[:]
-	}()...)
+	push(stack, []byte(hash256(string(pop(stack)))))
 	return true
 }
 
-func opHash160(stack []byte, s interface{}) {
-	panic(errors.New("Exception"))
+//checkSig verifies a signature with its trailing sighash byte against sec,
+//a bad encoding of either or a high s is an error rather than a false
+func checkSig(z *big.Int, sec, sig []byte) (bool, bool) {
+	if len(sig) == 0 {
+		return false, true
+	}
+	if checkSignatureEncoding(sig) != nil {
+		return false, false
+	}
+	signature, err := new(Signature).parse(sig[:len(sig)-1])
+	if err != nil || !signature.isLowS() {
+		return false, false
+	}
+	point, err := parseSec(sec)
+	if err != nil {
+		return false, false
+	}
+	return point.verify(z, signature), true
 }
 
-func opHash256(stack []byte, s interface{}) bool {
-	if len(stack) < 1 {
+func opCheckSig(stack *[][]byte, z interface{}) bool {
+	h, ok := z.(*big.Int)
+	if !ok || len(*stack) < 2 {
 		return false
 	}
-	element := func(s *[]byte) string {
-		i := len(*s) - 1
-		popped := (*s)[i]
-		*s = (*s)[:i]
-		return string(popped)
-	}(&stack)
-	stack = append(stack, hash256(element)...)
+	secPubkey := pop(stack)
+	sig := pop(stack)
+	valid, ok := checkSig(h, secPubkey, sig)
+	if !ok {
+		return false
+	}
+	pushBool(stack, valid)
 	return true
 }
 
-func opCheckSig(stack []byte, z interface{}) bool {
-	panic(errors.New("Exception"))
-}
-
-func opCheckSigVerify(stack []byte, z interface{}) bool {
-	return opCheckSig(stack, z) && opVerify(stack)
+func opCheckSigVerify(stack *[][]byte, z interface{}) bool {
+	return opCheckSig(stack, z) && opVerify(stack, z)
 }
 
-func opCheckMultiSig(stack []byte, z interface{}) bool {
-	if len(stack) < 1 {
+func opCheckMultiSig(stack *[][]byte, z interface{}) bool {
+	h, ok := z.(*big.Int)
+	if !ok || len(*stack) < 1 {
 		return false
 	}
-	n := decodeNum(func(s *[]byte) string {
-		i := len(*s) - 1
-		popped := (*s)[i]
-		*s = (*s)[:i]
-		return string(popped)
-	}(&stack))
-	if len(stack) < n+1 {
+	n := decodeNum(pop(stack))
+	if n < 0 || len(*stack) < n+1 {
 		return false
 	}
-	var secPubkeys []byte
-	for i := 0; i < n; i++ {
-		secPubkeys = append(secPubkeys, func(s *[]byte) byte {
-			i := len(*s) - 1
-			popped := (*s)[i]
-			*s = (*s)[:i]
-			return popped
-		}(&stack))
-	}
-	m := decodeNum(func(s *[]byte) string {
-		i := len(*s) - 1
-		popped := (*s)[i]
-		*s = (*s)[:i]
-		return string(popped)
-	}(&stack))
-	if len(stack) < m+1 {
+	secPubkeys := make([][]byte, n)
+	for i := n - 1; i >= 0; i-- {
+		secPubkeys[i] = pop(stack)
+	}
+	m := decodeNum(pop(stack))
+	if m < 0 || m > n || len(*stack) < m+1 {
 		return false
 	}
-	var derSignatures []byte
-	for x := 0; x < m; x++ {
-		derSignatures = append(derSignatures, func(s *[]byte) []byte {
-			i := len(*s) - 1
-			popped := (*s)[i]
-			*s = (*s)[:i]
-			return []byte(popped)
-		}(&stack)[:len(stack)-1]...)
-	}
-	func(s *[]byte) byte {
-		i := len(*s) - 1
-		popped := (*s)[i]
-		*s = (*s)[:i]
-		return popped
-	}(&stack)
-	func() bool {
-		defer func() bool {
-			if r := recover(); r != nil {
-				if err, ok := r.(error); ok {
-					if strings.HasPrefix(err.Error(), "ValueError") ||
-						strings.HasPrefix(err.Error(), "SyntaxError") {
-						return false
-					}
-				}
-				panic(r)
-			}
-		}()
-		points := func() (elts []interface{}) {
-			for _, sec := range secPubkeys {
-				s256 := new(S256Point)
-				elts = append(elts, s256.parse(sec))
-			}
-			return
-		}()
-		sigs := func() (elts []interface{}) {
-			for _, der := range derSignatures {
-				s := new(Signature)
-				elts = append(elts, s.parse(der))
-			}
-			return
-		}()
-		for _, sig := range sigs {
-			if len(points) == 0 {
+	sigs := make([][]byte, m)
+	for i := m - 1; i >= 0; i-- {
+		sigs[i] = pop(stack)
+	}
+	//OP_CHECKMULTISIG off-by-one bug
+	pop(stack)
+	//every signature has to match a key, in the same order as the keys
+	for _, sig := range sigs {
+		matched := false
+		for len(secPubkeys) > 0 && !matched {
+			valid, ok := checkSig(h, secPubkeys[0], sig)
+			if !ok {
 				return false
 			}
-			for len(points) != 0 {
-				point := func(s *[]byte, i int) byte {
-					popped := (*s)[i]
-					*s = append((*s)[:i], (*s)[i+1:]...)
-					return popped
-				}(&points, 0)
-				if point.verify(z, sig) {
-					break
-				}
-			}
+			secPubkeys = secPubkeys[1:]
+			matched = valid
 		}
-		stack = append(stack, encodeNum(1)...)
-	}()
+		if !matched {
+			pushNum(stack, 0)
+			return true
+		}
+	}
+	pushNum(stack, 1)
 	return true
 }
 
-func opCheckMultiSigVerify(stack []byte, z interface{}) bool {
+func opCheckMultiSigVerify(stack *[][]byte, z interface{}) bool {
 	return opCheckMultiSig(stack, z) && opVerify(stack, z)
 }
 
-func opCheckLocktimeVerify(stack []byte, locktime int, sequence interface{}) bool {
-	if reflect.DeepEqual(sequence, 4294967295) {
+//opCheckLocktimeVerify gets the *LockContext of the input, without one it fails
+func opCheckLocktimeVerify(stack *[][]byte, lock interface{}) bool {
+	ctx, ok := lock.(*LockContext)
+	if !ok || ctx == nil || ctx.sequence == 0xffffffff {
 		return false
 	}
-	if len(stack) < 1 {
+	if len(*stack) < 1 {
 		return false
 	}
-	element := decodeNum(string(stack[len(stack)-1]))
+	element := int64(decodeNum((*stack)[len(*stack)-1]))
 	if element < 0 {
 		return false
 	}
-	if element < 500000000 && int(locktime) > 500000000 {
+	//block heights and timestamps can't be compared
+	if (element < 500000000) != (ctx.locktime < 500000000) {
 		return false
 	}
-	if locktime < element {
+	if ctx.locktime < element {
 		return false
 	}
 	return true
 }
 
-func opCheckSequenceVerify(stack []byte, version int, sequence int) bool {
-	if reflect.DeepEqual(int(sequence)&(1<<31), 1<<31) {
-		return false
-	}
-	if len(stack) < 1 {
+func opCheckSequenceVerify(stack *[][]byte, lock interface{}) bool {
+	ctx, ok := lock.(*LockContext)
+	if !ok || ctx == nil || len(*stack) < 1 {
 		return false
 	}
-	element := decodeNum(string(stack[len(stack)-1]))
+	element := int64(decodeNum((*stack)[len(*stack)-1]))
 	if element < 0 {
 		return false
 	}
-	if element&(1<<31) == 1<<31 {
-		if int(version) < 2 {
+	//with the disable flag set the opcode is a nop
+	if element&(1<<31) == 0 {
+		if ctx.version < 2 {
 			return false
-		} else if reflect.DeepEqual(int(sequence)&(1<<31), 1<<31) {
+		} else if ctx.sequence&(1<<31) == 1<<31 {
 			return false
-		} else if !reflect.DeepEqual(element&(1<<22), int(sequence)&(1<<22)) {
+		} else if element&(1<<22) != ctx.sequence&(1<<22) {
 			return false
-		} else if element&65535 > int(sequence)&65535 {
+		} else if element&65535 > ctx.sequence&65535 {
 			return false
 		}
 	}
@@ -1178,7 +794,7 @@ func opCheckSequenceVerify(stack []byte, version int, sequence int) bool {
 }
 
 //use interface in declaration of them all
-var OPCODEFUNCTIONS = map[int]func([]byte, interface{}) bool{
+var OPCODEFUNCTIONS = map[int]func(*[][]byte, interface{}) bool{
 	0:   op0,
 	79:  op1negate,
 	81:  op1,
@@ -0,0 +1,144 @@
+package ecc
+
+import "fmt"
+
+//NetworkParams holds everything that differs between the Bitcoin networks,
+//pass one of MAINNET, TESTNET3, TESTNET4, SIGNET or REGTEST around instead
+//of a testnet flag
+type NetworkParams struct {
+	name          string
+	magic         []byte //first 4 bytes of every p2p message
+	defaultPort   int
+	p2pkhPrefix   byte
+	p2shPrefix    byte
+	wifPrefix     byte
+	xprvVersion   []byte
+	xpubVersion   []byte
+	bech32Hrp     string
+	genesisHeader []byte
+	checkpoints   map[int]string //height to block id
+	explorerUrl   string         //esplora api used by TxFetcher, empty if there is none
+}
+
+var MAINNET = &NetworkParams{
+	name:          "mainnet",
+	magic:         []byte{0xf9, 0xbe, 0xb4, 0xd9},
+	defaultPort:   8333,
+	p2pkhPrefix:   0x00,
+	p2shPrefix:    0x05,
+	wifPrefix:     0x80,
+	xprvVersion:   XPRVVERSION,
+	xpubVersion:   XPUBVERSION,
+	bech32Hrp:     "bc",
+	genesisHeader: mustHex("0100000000000000000000000000000000000000000000000000000000000000000000003ba3edfd7a7b12b27ac72c3e67768f617fc81bc3888a51323a9fb8aa4b1e5e4a29ab5f49ffff001d1dac2b7c"),
+	checkpoints: map[int]string{
+		0:      "000000000019d6689c085ae165831e934ff763ae46a2a6c172b3f1b60a8ce26f",
+		11111:  "0000000069e244f73d78e8fd29ba2fd2ed618bd6fa2ee92559f542fdb26e7c1d",
+		33333:  "000000002dd5588a74784eaa7ab0507a18ad16a236e7b1ce69f00d7ddfb5d0a6",
+		74000:  "0000000000573993a3c9e41ce34471c079dcf5f52a0e824a81e7f953b8661a20",
+		105000: "00000000000291ce28027faea320c8d2b054b2e0fe44a773f3eefb151d6bdc97",
+		134444: "00000000000005b12ffd4cd315cd34ffd4a594f430ac814c91184a0d42d2b0fe",
+		168000: "000000000000099e61ea72015e79632f216fe6cb33d7899acb35b75c8303b763",
+		193000: "000000000000059f452a5f7340de6682a977387c17010ff6e6c3bd83ca8b1317",
+		210000: "000000000000048b95347e83192f69cf0366076336c639f9b7228e9ba171342e",
+		216116: "00000000000001b4f4b433e81ee46494af945cf96014816a4e2370f11b23df4e",
+		225430: "00000000000001c108384350f74090433e7fcf79a606b8e797f065b130575932",
+		250000: "000000000000003887df1f29024b06fc2200b55f8af8f35453d7be294df2d214",
+		279000: "0000000000000001ae8c72a0b0c301f67e3afca10e819efa9041e458e9bd7e40",
+		295000: "00000000000000004d9b4ef50f0f9d686fd69db2e03af35a100370c64632a983",
+	},
+	explorerUrl: "https://blockstream.info/api/",
+}
+
+var TESTNET3 = &NetworkParams{
+	name:          "testnet3",
+	magic:         []byte{0x0b, 0x11, 0x09, 0x07},
+	defaultPort:   18333,
+	p2pkhPrefix:   0x6f,
+	p2shPrefix:    0xc4,
+	wifPrefix:     0xef,
+	xprvVersion:   TPRVVERSION,
+	xpubVersion:   TPUBVERSION,
+	bech32Hrp:     "tb",
+	genesisHeader: mustHex("0100000000000000000000000000000000000000000000000000000000000000000000003ba3edfd7a7b12b27ac72c3e67768f617fc81bc3888a51323a9fb8aa4b1e5e4adae5494dffff001d1aa4ae18"),
+	checkpoints: map[int]string{
+		0:   "000000000933ea01ad0ee984209779baaec3ced90fa3f408719526f8d77f4943",
+		546: "000000002a936ca763904c3c35fce2f3556c559c0214345d31b1bcebf76acb70",
+	},
+	explorerUrl: "https://blockstream.info/testnet/api/",
+}
+
+var TESTNET4 = &NetworkParams{
+	name:          "testnet4",
+	magic:         []byte{0x1c, 0x16, 0x3f, 0x28},
+	defaultPort:   48333,
+	p2pkhPrefix:   0x6f,
+	p2shPrefix:    0xc4,
+	wifPrefix:     0xef,
+	xprvVersion:   TPRVVERSION,
+	xpubVersion:   TPUBVERSION,
+	bech32Hrp:     "tb",
+	genesisHeader: mustHex("0100000000000000000000000000000000000000000000000000000000000000000000004e7b2b9128fe0291db0693af2ae418b767e657cd407e80cb1434221eaea7a07a046f3566ffff001dbb0c7817"),
+	checkpoints: map[int]string{
+		0: "00000000da84f2bafbbc53dee25a72ae507ff4914b867c565be350b0da8bf043",
+	},
+	explorerUrl: "https://mempool.space/testnet4/api/",
+}
+
+var SIGNET = &NetworkParams{
+	name:          "signet",
+	magic:         []byte{0x0a, 0x03, 0xcf, 0x40},
+	defaultPort:   38333,
+	p2pkhPrefix:   0x6f,
+	p2shPrefix:    0xc4,
+	wifPrefix:     0xef,
+	xprvVersion:   TPRVVERSION,
+	xpubVersion:   TPUBVERSION,
+	bech32Hrp:     "tb",
+	genesisHeader: mustHex("0100000000000000000000000000000000000000000000000000000000000000000000003ba3edfd7a7b12b27ac72c3e67768f617fc81bc3888a51323a9fb8aa4b1e5e4a008f4d5fae77031e8ad22203"),
+	checkpoints: map[int]string{
+		0: "00000008819873e925422c1ff0f99f7cc9bbb232af63a077a480a3633bee1ef6",
+	},
+	explorerUrl: "https://mempool.space/signet/api/",
+}
+
+var REGTEST = &NetworkParams{
+	name:          "regtest",
+	magic:         []byte{0xfa, 0xbf, 0xb5, 0xda},
+	defaultPort:   18444,
+	p2pkhPrefix:   0x6f,
+	p2shPrefix:    0xc4,
+	wifPrefix:     0xef,
+	xprvVersion:   TPRVVERSION,
+	xpubVersion:   TPUBVERSION,
+	bech32Hrp:     "bcrt",
+	genesisHeader: mustHex("0100000000000000000000000000000000000000000000000000000000000000000000003ba3edfd7a7b12b27ac72c3e67768f617fc81bc3888a51323a9fb8aa4b1e5e4adae5494dffff7f2002000000"),
+	checkpoints: map[int]string{
+		0: "0f9188f13cb7b2c71f2a335e3a4fc328bf5beb436012afca590b1a11466e2206",
+	},
+}
+
+//NETWORKS lists the known networks. The test networks share their Base58
+//prefixes, so lookups by prefix find TESTNET3 for all of them.
+var NETWORKS = []*NetworkParams{MAINNET, TESTNET3, TESTNET4, SIGNET, REGTEST}
+
+//NetworkByName finds the params for "mainnet", "testnet3", "testnet4",
+//"signet" or "regtest"
+func NetworkByName(name string) (*NetworkParams, error) {
+	for _, net := range NETWORKS {
+		if net.name == name {
+			return net, nil
+		}
+	}
+	return nil, fmt.Errorf("ValueError: unknown network %q", name)
+}
+
+func (net *NetworkParams) String() string {
+	return net.name
+}
+
+//checkpoint returns the block id expected at height, if there is one
+func (net *NetworkParams) checkpoint(height int) (string, bool) {
+	id, ok := net.checkpoints[height]
+	return id, ok
+}
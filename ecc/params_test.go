@@ -0,0 +1,62 @@
+package ecc
+
+import (
+	"bytes"
+	"encoding/hex"
+	"testing"
+)
+
+func TestNetworkParams(t *testing.T) {
+	tests := []struct {
+		net                      *NetworkParams
+		name                     string
+		xprvVersion, xpubVersion []byte
+		hrp                      string
+	}{
+		{MAINNET, "mainnet", XPRVVERSION, XPUBVERSION, "bc"},
+		{TESTNET3, "testnet3", TPRVVERSION, TPUBVERSION, "tb"},
+		{TESTNET4, "testnet4", TPRVVERSION, TPUBVERSION, "tb"},
+		{SIGNET, "signet", TPRVVERSION, TPUBVERSION, "tb"},
+		{REGTEST, "regtest", TPRVVERSION, TPUBVERSION, "bcrt"},
+	}
+	if len(tests) != len(NETWORKS) {
+		t.Fatalf("%d networks, the table has %d", len(NETWORKS), len(tests))
+	}
+	for i, test := range tests {
+		net := test.net
+		if NETWORKS[i] != net {
+			t.Errorf("NETWORKS[%d] = %s, want %s", i, NETWORKS[i], net)
+		}
+		if net.String() != test.name {
+			t.Errorf("String() = %q, want %q", net.String(), test.name)
+		}
+		if got, err := NetworkByName(test.name); err != nil || got != net {
+			t.Errorf("NetworkByName(%q) = %v, %v", test.name, got, err)
+		}
+		if !bytes.Equal(net.xprvVersion, test.xprvVersion) || !bytes.Equal(net.xpubVersion, test.xpubVersion) {
+			t.Errorf("%s: versions %x %x", net, net.xprvVersion, net.xpubVersion)
+		}
+		if net.bech32Hrp != test.hrp {
+			t.Errorf("%s: hrp %q, want %q", net, net.bech32Hrp, test.hrp)
+		}
+		//the genesis header hashes to the height 0 checkpoint
+		if len(net.genesisHeader) != 80 {
+			t.Errorf("%s: genesis header is %d bytes", net, len(net.genesisHeader))
+		}
+		genesis := hex.EncodeToString([]byte(reverse(hash256(string(net.genesisHeader)))))
+		if id, ok := net.checkpoint(0); !ok || id != genesis {
+			t.Errorf("%s: checkpoint(0) = %s, %v, genesis hashes to %s", net, id, ok, genesis)
+		}
+		if id, ok := net.checkpoint(1); ok {
+			t.Errorf("%s: checkpoint(1) = %s", net, id)
+		}
+	}
+	if id, ok := MAINNET.checkpoint(11111); !ok || id != "0000000069e244f73d78e8fd29ba2fd2ed618bd6fa2ee92559f542fdb26e7c1d" {
+		t.Errorf("checkpoint(11111) = %s, %v", id, ok)
+	}
+	for _, name := range []string{"", "testnet", "Mainnet", "main", "regtest "} {
+		if net, err := NetworkByName(name); err == nil {
+			t.Errorf("NetworkByName(%q) = %s", name, net)
+		}
+	}
+}
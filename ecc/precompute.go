@@ -0,0 +1,131 @@
+package ecc
+
+import (
+	"math/big"
+)
+
+//everything in this file is variable time and must only ever see public
+//values, i.e. signature verification. Secrets go through rmulSecret.
+
+//gWindow is the wNAF width used for G, its table is built once at startup
+//pointWindow is used for arbitrary points where the table is built per call
+const (
+	gWindow     = 8
+	pointWindow = 5
+)
+
+//gOddMultiples holds G, 3G, 5G ... (2**(gWindow-1)-1)G
+var gOddMultiples = oddMultiples(newProjPoint(G), gWindow)
+
+//wnaf returns the width-w non-adjacent form of k, least significant digit first.
+//Every non-zero digit is odd and lies between -(2**(w-1)) and 2**(w-1),
+//and of any w consecutive digits at most one is non-zero.
+func wnaf(k *big.Int, w uint) []int8 {
+	d := new(big.Int).Set(k)
+	window := uint64(1) << w
+	var naf []int8
+	for d.Sign() > 0 {
+		var digit int64
+		if d.Bit(0) == 1 {
+			digit = int64(d.Uint64() & (window - 1))
+			if digit >= int64(window/2) {
+				digit -= int64(window)
+			}
+			d.Sub(d, big.NewInt(digit))
+		}
+		naf = append(naf, int8(digit))
+		d.Rsh(d, 1)
+	}
+	return naf
+}
+
+//oddMultiples returns p, 3p, 5p ... (2**(w-1)-1)p
+func oddMultiples(p projPoint, w uint) []projPoint {
+	table := make([]projPoint, 1<<(w-2))
+	var double projPoint
+	double.double(&p)
+	table[0] = p
+	for i := 1; i < len(table); i++ {
+		table[i].add(&table[i-1], &double)
+	}
+	return table
+}
+
+//addDigit adds digit times the base point of an odd multiples table to p
+func (p *projPoint) addDigit(table []projPoint, digit int8) {
+	if digit > 0 {
+		p.add(p, &table[(digit-1)/2])
+	} else if digit < 0 {
+		var negated projPoint
+		negated.neg(&table[(-digit-1)/2])
+		p.add(p, &negated)
+	}
+}
+
+//mulWNAF computes k*p for any point p
+func (p *projPoint) mulWNAF(k *big.Int) projPoint {
+	table := oddMultiples(*p, pointWindow)
+	naf := wnaf(k, pointWindow)
+	result := newProjInfinity()
+	for i := len(naf) - 1; i >= 0; i-- {
+		result.double(&result)
+		result.addDigit(table, naf[i])
+	}
+	return result
+}
+
+//wnafTerm is one k*P of a multi scalar multiplication, the digits of k
+//and the odd multiples of P they index into
+type wnafTerm struct {
+	naf   []int8
+	table []projPoint
+}
+
+//interleave computes the sum of all the terms with one shared chain of doublings
+func interleave(terms []wnafTerm) projPoint {
+	length := 0
+	for _, term := range terms {
+		if len(term.naf) > length {
+			length = len(term.naf)
+		}
+	}
+	result := newProjInfinity()
+	for i := length - 1; i >= 0; i-- {
+		result.double(&result)
+		for _, term := range terms {
+			if i < len(term.naf) {
+				result.addDigit(term.table, term.naf[i])
+			}
+		}
+	}
+	return result
+}
+
+//shamirMul computes u*G + v*p in one pass (Shamir's trick), so both
+//multiplications share the same chain of doublings
+func shamirMul(u, v *big.Int, p *projPoint) projPoint {
+	return interleave([]wnafTerm{
+		{wnaf(u, gWindow), gOddMultiples},
+		{wnaf(v, pointWindow), oddMultiples(*p, pointWindow)},
+	})
+}
+
+//hasXCoordinate reports whether the affine x of p reduced mod N equals r,
+//comparing r*Z against X so no field inversion is needed
+func (p *projPoint) hasXCoordinate(r *big.Int) bool {
+	if p.z.isZero() == 1 {
+		return false
+	}
+	var rz fieldVal
+	candidate := new(big.Int).Set(r)
+	//x is below P but may be above N, in which case x mod N == x - N
+	for candidate.Cmp(P) < 0 {
+		rVal := newFieldVal(candidate)
+		rz.mul(&rVal, &p.z)
+		if rz.equal(&p.x) == 1 {
+			return true
+		}
+		candidate.Add(candidate, N)
+	}
+	return false
+}
@@ -0,0 +1,179 @@
+package ecc
+
+import (
+	"crypto/subtle"
+	"fmt"
+	"math/big"
+)
+
+//projPoint is a secp256k1 point in homogeneous projective coordinates,
+//(X:Y:Z) stands for the affine point (X/Z, Y/Z) and (0:1:0) is the point
+//at infinity. The add and double methods use the complete formulas from
+//Renes, Costello and Batina (https://eprint.iacr.org/2015/1060, algorithms
+//7 and 9), which have no special cases and therefore no branches at all.
+type projPoint struct {
+	x fieldVal
+	y fieldVal
+	z fieldVal
+}
+
+func newProjInfinity() projPoint {
+	return projPoint{y: fieldOne}
+}
+
+func newProjPoint(sp *S256Point) projPoint {
+	if sp.isInfinity() {
+		return newProjInfinity()
+	}
+	return projPoint{x: newFieldVal(sp.x.num), y: newFieldVal(sp.y.num), z: fieldOne}
+}
+
+//toAffine divides out Z, the inversion is constant time
+func (p *projPoint) toAffine() *S256Point {
+	if p.z.isZero() == 1 {
+		return NewS256Point(nil, nil)
+	}
+	var zInv, x, y fieldVal
+	zInv.inverse(&p.z)
+	x.mul(&p.x, &zInv)
+	y.mul(&p.y, &zInv)
+	return NewS256Point(x.big(), y.big())
+}
+
+//affineBytes is toAffine without the trip through math/big and the on
+//curve check, for points that have to stay secret. ok is false at infinity.
+func (p *projPoint) affineBytes() (x, y [32]byte, ok bool) {
+	if p.z.isZero() == 1 {
+		return x, y, false
+	}
+	var zInv, xVal, yVal fieldVal
+	zInv.inverse(&p.z)
+	xVal.mul(&p.x, &zInv)
+	yVal.mul(&p.y, &zInv)
+	return xVal.bytes(), yVal.bytes(), true
+}
+
+func (p *projPoint) cmov(q *projPoint, flag uint64) {
+	p.x.cmov(&q.x, flag)
+	p.y.cmov(&q.y, flag)
+	p.z.cmov(&q.z, flag)
+}
+
+func (p *projPoint) neg(q *projPoint) *projPoint {
+	p.x = q.x
+	p.y.neg(&q.y)
+	p.z = q.z
+	return p
+}
+
+//add sets p = a + b, it is also correct when a == b or either is infinity
+func (p *projPoint) add(a, b *projPoint) *projPoint {
+	var t0, t1, t2, t3, t4, x3, y3, z3 fieldVal
+	t0.mul(&a.x, &b.x)
+	t1.mul(&a.y, &b.y)
+	t2.mul(&a.z, &b.z)
+	t3.add(&a.x, &a.y)
+	t4.add(&b.x, &b.y)
+	t3.mul(&t3, &t4)
+	t4.add(&t0, &t1)
+	t3.sub(&t3, &t4)
+	t4.add(&a.y, &a.z)
+	x3.add(&b.y, &b.z)
+	t4.mul(&t4, &x3)
+	x3.add(&t1, &t2)
+	t4.sub(&t4, &x3)
+	x3.add(&a.x, &a.z)
+	y3.add(&b.x, &b.z)
+	x3.mul(&x3, &y3)
+	y3.add(&t0, &t2)
+	y3.sub(&x3, &y3)
+	x3.add(&t0, &t0)
+	t0.add(&x3, &t0)
+	t2.mul(&fieldB3, &t2)
+	z3.add(&t1, &t2)
+	t1.sub(&t1, &t2)
+	y3.mul(&fieldB3, &y3)
+	x3.mul(&t4, &y3)
+	t2.mul(&t3, &t1)
+	x3.sub(&t2, &x3)
+	y3.mul(&y3, &t0)
+	t1.mul(&t1, &z3)
+	y3.add(&t1, &y3)
+	t0.mul(&t0, &t3)
+	z3.mul(&z3, &t4)
+	z3.add(&z3, &t0)
+	p.x, p.y, p.z = x3, y3, z3
+	return p
+}
+
+//double sets p = 2a
+func (p *projPoint) double(a *projPoint) *projPoint {
+	var t0, t1, t2, x3, y3, z3 fieldVal
+	t0.sqr(&a.y)
+	z3.add(&t0, &t0)
+	z3.add(&z3, &z3)
+	z3.add(&z3, &z3)
+	t1.mul(&a.y, &a.z)
+	t2.sqr(&a.z)
+	t2.mul(&fieldB3, &t2)
+	x3.mul(&t2, &z3)
+	y3.add(&t0, &t2)
+	z3.mul(&t1, &z3)
+	t1.add(&t2, &t2)
+	t2.add(&t1, &t2)
+	t0.sub(&t0, &t2)
+	y3.mul(&t0, &y3)
+	y3.add(&x3, &y3)
+	t1.mul(&a.x, &a.y)
+	x3.mul(&t0, &t1)
+	x3.add(&x3, &x3)
+	p.x, p.y, p.z = x3, y3, z3
+	return p
+}
+
+//mulConstTime computes k*p with a fixed 4-bit window. Every window does
+//four doublings and one addition of a table entry, and the entry is picked
+//by scanning the whole table, so neither the control flow nor the memory
+//access pattern depends on the bits of k.
+func (p *projPoint) mulConstTime(k *[32]byte) projPoint {
+	var table [16]projPoint
+	table[0] = newProjInfinity()
+	table[1] = *p
+	for i := 2; i < 16; i++ {
+		table[i].add(&table[i-1], p)
+	}
+	result := newProjInfinity()
+	var selected projPoint
+	for i := 0; i < 64; i++ {
+		window := int32(k[i/2] >> (4 * uint(1-i%2)) & 0x0f)
+		for j := 0; j < 4; j++ {
+			result.double(&result)
+		}
+		selected = newProjInfinity()
+		for j := int32(0); j < 16; j++ {
+			selected.cmov(&table[j], uint64(subtle.ConstantTimeEq(window, j)))
+		}
+		result.add(&result, &selected)
+	}
+	return result
+}
+
+//rmulSecret is the constant time counterpart of Rmul2,
+//use it whenever the coefficient is a private key or a nonce
+func (sp *S256Point) rmulSecret(secret *big.Int) *S256Point {
+	result := sp.mulSecret(secret)
+	return result.toAffine()
+}
+
+//mulSecret leaves the product in projective form. N*P is infinity for
+//every point so the secret needs no reduction mod N, it only has to fit
+//in 32 bytes.
+func (sp *S256Point) mulSecret(secret *big.Int) projPoint {
+	if secret.Sign() < 0 || secret.BitLen() > 256 {
+		panic(fmt.Errorf("ValueError: %v", "secret not in range 0 to 2**256-1"))
+	}
+	var k [32]byte
+	secret.FillBytes(k[:])
+	p := newProjPoint(sp)
+	return p.mulConstTime(&k)
+}
@@ -0,0 +1,80 @@
+package ecc
+
+import (
+	"fmt"
+	"math/big"
+)
+
+//compact signatures are what Bitcoin Core's signmessage produces:
+//a header byte 27 + recid (+4 for a compressed key) followed by r and s
+
+//compact returns the 65 byte recoverable encoding, sign fills in the recid
+func (S *Signature) compact(compressed bool) []byte {
+	if S.recid < 0 || S.recid > 3 {
+		panic(fmt.Errorf("ValueError: %v", "signature has no recovery id"))
+	}
+	header := 27 + S.recid
+	if compressed {
+		header += 4
+	}
+	result := append([]byte{byte(header)}, intToBytes32(S.r)...)
+	return append(result, intToBytes32(S.s)...)
+}
+
+//parseCompact reads a 65 byte compact signature, it also returns
+//whether the signer's key was compressed
+func (S *Signature) parseCompact(b []byte) (*Signature, bool, error) {
+	if len(b) != 65 {
+		return nil, false, fmt.Errorf("SyntaxError: %v", "compact signatures are 65 bytes")
+	}
+	header := int(b[0])
+	if header < 27 || header > 34 {
+		return nil, false, fmt.Errorf("SyntaxError: invalid compact signature header %d", header)
+	}
+	compressed := header >= 31
+	sig := NewSignature(new(big.Int).SetBytes(b[1:33]), new(big.Int).SetBytes(b[33:65]))
+	sig.recid = (header - 27) & 3
+	return sig, compressed, nil
+}
+
+//recoverPubkey finds the public key that made sig over z, using the
+//recovery id to pick kG among the points with x coordinate r.
+//Q = r**-1 * (s*R - z*G)
+func recoverPubkey(z *big.Int, sig *Signature) (*S256Point, error) {
+	if sig.recid < 0 || sig.recid > 3 {
+		return nil, fmt.Errorf("ValueError: %v", "signature has no recovery id")
+	}
+	if sig.r.Sign() <= 0 || sig.r.Cmp(N) >= 0 || sig.s.Sign() <= 0 || sig.s.Cmp(N) >= 0 {
+		return nil, fmt.Errorf("ValueError: %v", "r and s must be between 1 and N-1")
+	}
+	x := new(big.Int).Set(sig.r)
+	if sig.recid&2 != 0 {
+		x.Add(x, N)
+	}
+	if x.Cmp(P) >= 0 {
+		return nil, fmt.Errorf("ValueError: %v", "recovered x not in field range")
+	}
+	R, err := liftX(x)
+	if err != nil {
+		return nil, fmt.Errorf("ValueError: %v", "r is not the x coordinate of a point")
+	}
+	//liftX gives the even y, flip it for an odd recid
+	if sig.recid&1 == 1 {
+		R = NewS256Point(R.x.num, new(big.Int).Sub(P, R.y.num))
+	}
+	rInv := new(big.Int).Exp(sig.r, new(big.Int).Sub(N, big.NewInt(2)), N)
+	u1 := mod(new(big.Int).Mul(new(big.Int).Neg(z), rInv), N)
+	u2 := mod(new(big.Int).Mul(sig.s, rInv), N)
+	p := newProjPoint(R)
+	var total projPoint
+	if GLVENABLED {
+		total = shamirMulGLV(u1, u2, &p)
+	} else {
+		total = shamirMul(u1, u2, &p)
+	}
+	Q := total.toAffine()
+	if Q.isInfinity() {
+		return nil, fmt.Errorf("ValueError: %v", "recovered the point at infinity")
+	}
+	return Q, nil
+}
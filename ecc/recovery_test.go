@@ -0,0 +1,115 @@
+package ecc
+
+import (
+	"bytes"
+	"encoding/base64"
+	"math/big"
+	"testing"
+)
+
+func TestRecoverKnownMessage(t *testing.T) {
+	//Bitcoin Core's rpc_signmessage.py, signed by a compressed key
+	raw, _ := base64.StdEncoding.DecodeString("INbVnW4e6PeRmsv2Qgu8NuopvrVjkcxob+sX8OcZG0SALhWybUjzMLPdAsXI46YZGb0KQTRii+wWIQzRpG/U+S0=")
+	sig, compressed, err := new(Signature).parseCompact(raw)
+	if err != nil || !compressed {
+		t.Fatalf("parse %v %v", compressed, err)
+	}
+	point, err := recoverPubkey(legacyMessageHash("This is just a test message"), sig)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if point.address(true, TESTNET3) != "mpLQjfK79b7CCV4VMJWEWAj5Mpx8Up5zxB" {
+		t.Fatalf("recovered %s", point.address(true, TESTNET3))
+	}
+	if !bytes.Equal(sig.compact(compressed), raw) {
+		t.Fatal("compact round trip")
+	}
+}
+
+func TestRecoverEveryRecid(t *testing.T) {
+	for i := int64(1); i <= 20; i++ {
+		privateKey := NewPrivateKey(big.NewInt(i * 7919))
+		z := legacyMessageHash(string(rune('a' + i)))
+		sig := privateKey.sign(z, false)
+		for _, compressed := range []bool{true, false} {
+			parsed, gotCompressed, err := new(Signature).parseCompact(sig.compact(compressed))
+			if err != nil || gotCompressed != compressed || parsed.recid != sig.recid {
+				t.Fatalf("%d: compact round trip %v", i, err)
+			}
+		}
+		point, err := recoverPubkey(z, sig)
+		if err != nil || point.sec(true) != privateKey.point.sec(true) {
+			t.Fatalf("%d: recovered the wrong key %v", i, err)
+		}
+		//any other recid is a different key or no key at all
+		for recid := 0; recid < 4; recid++ {
+			if recid == sig.recid {
+				continue
+			}
+			other := NewSignature(sig.r, sig.s)
+			other.recid = recid
+			if point, err := recoverPubkey(z, other); err == nil && point.sec(true) == privateKey.point.sec(true) {
+				t.Fatalf("%d: recid %d recovered the signer too", i, recid)
+			}
+		}
+	}
+}
+
+func TestParseCompactErrors(t *testing.T) {
+	sig := NewPrivateKey(big.NewInt(12345)).sign(big.NewInt(1), false)
+	good := sig.compact(true)
+	for _, header := range []byte{0, 26, 35, 255} {
+		bad := append([]byte{header}, good[1:]...)
+		if _, _, err := new(Signature).parseCompact(bad); err == nil {
+			t.Errorf("header %d parsed", header)
+		}
+	}
+	if _, _, err := new(Signature).parseCompact(good[:64]); err == nil {
+		t.Error("64 bytes parsed")
+	}
+	//r and s out of range are errors, not panics
+	for _, bad := range []*Signature{NewSignature(big.NewInt(0), sig.s), NewSignature(sig.r, N)} {
+		bad.recid = 0
+		if _, err := recoverPubkey(big.NewInt(1), bad); err == nil {
+			t.Error("recovered from an out of range signature")
+		}
+	}
+	//r that is not the x of any point, x = 5 has no y on secp256k1
+	bad := NewSignature(big.NewInt(5), sig.s)
+	bad.recid = 0
+	if _, err := recoverPubkey(big.NewInt(1), bad); err == nil {
+		t.Error("recovered from an r off the curve")
+	}
+}
+
+func TestParseSecErrors(t *testing.T) {
+	point := NewPrivateKey(big.NewInt(999)).point
+	for _, compressed := range []bool{true, false} {
+		parsed, err := parseSec([]byte(point.sec(compressed)))
+		if err != nil || parsed.sec(true) != point.sec(true) {
+			t.Fatalf("round trip %v", err)
+		}
+	}
+	uncompressed := []byte(point.sec(false))
+	offCurve := append([]byte{}, uncompressed...)
+	offCurve[64] ^= 1
+	tests := [][]byte{
+		nil,
+		{2},
+		append([]byte{5}, uncompressed[1:33]...),
+		uncompressed[:64],
+		offCurve,
+		//x = 5 is not on the curve
+		append([]byte{2}, intToBytes32(big.NewInt(5))...),
+		//x >= P
+		append([]byte{3}, intToBytes32(P)...),
+	}
+	for i, sec := range tests {
+		if _, err := parseSec(sec); err == nil {
+			t.Errorf("%d: %x parsed", i, sec)
+		}
+	}
+	if _, err := liftX(big.NewInt(5)); err == nil {
+		t.Error("lifted x = 5")
+	}
+}
@@ -0,0 +1,140 @@
+package ecc
+
+import (
+	"fmt"
+	"math/big"
+	"math/bits"
+)
+
+//scalarVal is an integer mod N stored in Montgomery form (x * 2**256 mod N)
+//as four little-endian 64-bit limbs. It is used for the nonce and secret
+//arithmetic in signing, where math/big would leak timing information.
+type scalarVal [4]uint64
+
+var scalarN = scalarVal{0xbfd25e8cd0364141, 0xbaaedce6af48a03b, 0xfffffffffffffffe, 0xffffffffffffffff}
+
+//scalarNInv is -N**-1 mod 2**64
+const scalarNInv = 0x4b0dff665588b13f
+
+//scalarR2 is 2**512 mod N, multiplying by it moves a value into Montgomery form
+var scalarR2 = func() (s scalarVal) {
+	r2 := new(big.Int).Lsh(big.NewInt(1), 512)
+	s.setLimbs(r2.Mod(r2, N))
+	return
+}()
+
+func (s *scalarVal) setLimbs(num *big.Int) {
+	var b [32]byte
+	num.FillBytes(b[:])
+	for i := 0; i < 4; i++ {
+		s[i] = 0
+		for j := 0; j < 8; j++ {
+			s[i] |= uint64(b[31-8*i-j]) << (8 * j)
+		}
+	}
+}
+
+//newScalarVal converts num, which has to be in 0 to 2**256-1, into
+//Montgomery form. Anything below 2**256 is less than 2N so a masked
+//subtraction reduces it, big.Int's Mod would take variable time.
+func newScalarVal(num *big.Int) (s scalarVal) {
+	if num.Sign() < 0 || num.BitLen() > 256 {
+		panic(fmt.Errorf("ValueError: %v", "scalar not in range 0 to 2**256-1"))
+	}
+	s.setLimbs(num)
+	s.reduceOnce(0)
+	s.mul(&s, &scalarR2)
+	return
+}
+
+//big converts out of Montgomery form
+func (s *scalarVal) big() *big.Int {
+	one := scalarVal{1, 0, 0, 0}
+	var t scalarVal
+	t.mul(s, &one)
+	var b [32]byte
+	for i := 0; i < 4; i++ {
+		for j := 0; j < 8; j++ {
+			b[31-8*i-j] = byte(t[i] >> (8 * j))
+		}
+	}
+	return new(big.Int).SetBytes(b[:])
+}
+
+//reduceOnce subtracts N when s (plus an overflow bit of 2**256) is at least N
+func (s *scalarVal) reduceOnce(carry uint64) {
+	var t scalarVal
+	var borrow uint64
+	t[0], borrow = bits.Sub64(s[0], scalarN[0], 0)
+	t[1], borrow = bits.Sub64(s[1], scalarN[1], borrow)
+	t[2], borrow = bits.Sub64(s[2], scalarN[2], borrow)
+	t[3], borrow = bits.Sub64(s[3], scalarN[3], borrow)
+	mask := -(carry | (borrow ^ 1))
+	for i := 0; i < 4; i++ {
+		s[i] = s[i]&^mask | t[i]&mask
+	}
+}
+
+func (s *scalarVal) add(a, b *scalarVal) *scalarVal {
+	var carry uint64
+	s[0], carry = bits.Add64(a[0], b[0], 0)
+	s[1], carry = bits.Add64(a[1], b[1], carry)
+	s[2], carry = bits.Add64(a[2], b[2], carry)
+	s[3], carry = bits.Add64(a[3], b[3], carry)
+	s.reduceOnce(carry)
+	return s
+}
+
+//mul is Montgomery multiplication (CIOS), it returns a*b/2**256 mod N
+func (s *scalarVal) mul(a, b *scalarVal) *scalarVal {
+	var t [6]uint64
+	for i := 0; i < 4; i++ {
+		var carry, c uint64
+		for j := 0; j < 4; j++ {
+			hi, lo := bits.Mul64(a[j], b[i])
+			lo, c = bits.Add64(lo, t[j], 0)
+			hi += c
+			lo, c = bits.Add64(lo, carry, 0)
+			hi += c
+			t[j] = lo
+			carry = hi
+		}
+		t[4], c = bits.Add64(t[4], carry, 0)
+		t[5] = c
+		//add m*N so the lowest limb becomes zero, then shift it out
+		m := t[0] * scalarNInv
+		hi, lo := bits.Mul64(m, scalarN[0])
+		_, c = bits.Add64(lo, t[0], 0)
+		carry = hi + c
+		for j := 1; j < 4; j++ {
+			hi, lo = bits.Mul64(m, scalarN[j])
+			lo, c = bits.Add64(lo, t[j], 0)
+			hi += c
+			lo, c = bits.Add64(lo, carry, 0)
+			hi += c
+			t[j-1] = lo
+			carry = hi
+		}
+		t[3], c = bits.Add64(t[4], carry, 0)
+		t[4] = t[5] + c
+	}
+	*s = scalarVal{t[0], t[1], t[2], t[3]}
+	s.reduceOnce(t[4])
+	return s
+}
+
+//inverse raises a to N-2, the exponent is public so this runs in constant time
+func (s *scalarVal) inverse(a *scalarVal) *scalarVal {
+	exponent := scalarN
+	exponent[0] -= 2
+	result := newScalarVal(big.NewInt(1))
+	base := *a
+	for i := 255; i >= 0; i-- {
+		result.mul(&result, &result)
+		if (exponent[i/64]>>(uint(i)%64))&1 == 1 {
+			result.mul(&result, &base)
+		}
+	}
+	*s = result
+	return s
+}
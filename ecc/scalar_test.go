@@ -0,0 +1,134 @@
+package ecc
+
+import (
+	"crypto/rand"
+	"math"
+	"math/big"
+	"sort"
+	"testing"
+	"time"
+)
+
+func TestNewScalarVal(t *testing.T) {
+	for _, num := range []*big.Int{
+		big.NewInt(0),
+		big.NewInt(7),
+		new(big.Int).Sub(N, big.NewInt(1)),
+		new(big.Int).Set(N),
+		new(big.Int).Add(N, big.NewInt(5)),
+		new(big.Int).Sub(new(big.Int).Lsh(big.NewInt(1), 256), big.NewInt(1)),
+	} {
+		s := newScalarVal(num)
+		if got := s.big(); got.Cmp(mod(num, N)) != 0 {
+			t.Errorf("newScalarVal(%x) = %x, want %x", num, got, mod(num, N))
+		}
+	}
+	for _, num := range []*big.Int{big.NewInt(-1), new(big.Int).Lsh(big.NewInt(1), 256)} {
+		func() {
+			defer func() {
+				if recover() == nil {
+					t.Errorf("newScalarVal(%x) did not panic", num)
+				}
+			}()
+			newScalarVal(num)
+		}()
+	}
+}
+
+func TestRmulSecret(t *testing.T) {
+	for _, secret := range []*big.Int{
+		big.NewInt(1),
+		big.NewInt(0xdeadbeef),
+		new(big.Int).Sub(N, big.NewInt(1)),
+		new(big.Int).Add(N, big.NewInt(3)),
+	} {
+		got := G.rmulSecret(secret)
+		if got.sec(false) != G.Rmul2(secret).sec(false) {
+			t.Errorf("rmulSecret(%x) = %s", secret, got.Repr())
+		}
+	}
+	if !G.rmulSecret(new(big.Int).Set(N)).isInfinity() {
+		t.Error("N*G is not the point at infinity")
+	}
+}
+
+//TestRmulSecretTiming is a dudect style check (Reparaz, Balasch and
+//Verbauwhede, https://eprint.iacr.org/2016/1123): the multiplication is
+//timed with a fixed secret of a single bit and with random secrets, and
+//Welch's t statistic of the two groups must stay small.
+func TestRmulSecretTiming(t *testing.T) {
+	if testing.Short() {
+		t.Skip("timing test skipped in short mode")
+	}
+	const samples = 2000
+	peer := G.Rmul2(big.NewInt(0x5eed))
+	fixed := big.NewInt(1)
+	classes := make([]byte, 2*samples)
+	if _, err := rand.Read(classes); err != nil {
+		t.Fatal(err)
+	}
+	var durations [2][]float64
+	for _, class := range classes {
+		secret := fixed
+		if class&1 == 1 {
+			secret = randomScalar(t)
+		}
+		start := time.Now()
+		shared := peer.mulSecret(secret)
+		shared.affineBytes()
+		durations[class&1] = append(durations[class&1], float64(time.Since(start)))
+	}
+	//drop the slowest tenth, those are scheduler and GC noise
+	cutoff := percentile(append(append([]float64{}, durations[0]...), durations[1]...), 0.9)
+	tValue := welchT(below(durations[0], cutoff), below(durations[1], cutoff))
+	t.Logf("t = %.2f", tValue)
+	//dudect calls 4.5 a leak, the margin is for shared test machines
+	if math.Abs(tValue) > 10 {
+		t.Errorf("timing depends on the secret, t = %.2f", tValue)
+	}
+}
+
+func randomScalar(tb testing.TB) *big.Int {
+	b := make([]byte, 32)
+	if _, err := rand.Read(b); err != nil {
+		tb.Fatal(err)
+	}
+	return mod(new(big.Int).SetBytes(b), N)
+}
+
+func percentile(values []float64, p float64) float64 {
+	sort.Float64s(values)
+	return values[int(p*float64(len(values)-1))]
+}
+
+func below(values []float64, cutoff float64) (result []float64) {
+	for _, v := range values {
+		if v <= cutoff {
+			result = append(result, v)
+		}
+	}
+	return
+}
+
+func welchT(a, b []float64) float64 {
+	meanVar := func(values []float64) (mean, variance float64) {
+		for _, v := range values {
+			mean += v
+		}
+		mean /= float64(len(values))
+		for _, v := range values {
+			variance += (v - mean) * (v - mean)
+		}
+		return mean, variance / float64(len(values)-1)
+	}
+	meanA, varA := meanVar(a)
+	meanB, varB := meanVar(b)
+	return (meanA - meanB) / math.Sqrt(varA/float64(len(a))+varB/float64(len(b)))
+}
+
+func BenchmarkRmulSecret(b *testing.B) {
+	secret := randomScalar(b)
+	for i := 0; i < b.N; i++ {
+		G.rmulSecret(secret)
+	}
+}
@@ -0,0 +1,164 @@
+package ecc
+
+import (
+	"crypto/sha256"
+	"fmt"
+	"math/big"
+)
+
+//BIP340 Schnorr signatures. Public keys are x-only, the 32 byte x coordinate
+//of the point with the even y, and signatures are a fixed 64 bytes (r, s).
+
+//taggedHash is sha256(sha256(tag) || sha256(tag) || msg...)
+func taggedHash(tag string, msgs ...[]byte) [32]byte {
+	tagHash := sha256.Sum256([]byte(tag))
+	h := sha256.New()
+	h.Write(tagHash[:])
+	h.Write(tagHash[:])
+	for _, msg := range msgs {
+		h.Write(msg)
+	}
+	var result [32]byte
+	copy(result[:], h.Sum(nil))
+	return result
+}
+
+func intToBytes32(n *big.Int) []byte {
+	b := make([]byte, 32)
+	n.FillBytes(b)
+	return b
+}
+
+//xonly returns the BIP340 public key, the 32 byte x coordinate
+func (sp *S256Point) xonly() []byte {
+	return intToBytes32(sp.x.num)
+}
+
+func (sp *S256Point) hasEvenY() bool {
+	return sp.y.num.Bit(0) == 0
+}
+
+//liftX returns the point with the given x coordinate and an even y
+func liftX(x *big.Int) (*S256Point, error) {
+	if x.Cmp(P) >= 0 {
+		return nil, fmt.Errorf("ValueError: %v", "x not in field range")
+	}
+	xField := NewS256Field(x)
+	alpha := xField.Pow(big.NewInt(3)).Add(NewS256Field(B))
+	beta := alpha.sqrt()
+	if !beta.Mul(beta).Eq(alpha) {
+		return nil, fmt.Errorf("ValueError: %v", "x is not on the curve")
+	}
+	if beta.num.Bit(0) == 0 {
+		return NewS256Point(x, beta.num), nil
+	}
+	return NewS256Point(x, new(big.Int).Sub(P, beta.num)), nil
+}
+
+//parseXonly turns a 32 byte x-only public key back into a point
+func (sp *S256Point) parseXonly(b []byte) (*S256Point, error) {
+	if len(b) != 32 {
+		return nil, fmt.Errorf("SyntaxError: %v", "x-only public keys are 32 bytes")
+	}
+	return liftX(new(big.Int).SetBytes(b))
+}
+
+type SchnorrSignature struct {
+	r *big.Int //x coordinate of R
+	s *big.Int
+}
+
+func NewSchnorrSignature(r *big.Int, s *big.Int) (ss *SchnorrSignature) {
+	ss = new(SchnorrSignature)
+	ss.r = r
+	ss.s = s
+	return
+}
+
+func (S *SchnorrSignature) Repr() string {
+	return fmt.Sprintf("SchnorrSignature(%x,%x)", S.r, S.s)
+}
+
+func (S *SchnorrSignature) serialize() []byte {
+	return append(intToBytes32(S.r), intToBytes32(S.s)...)
+}
+
+func (S *SchnorrSignature) parse(signatureBin []byte) *SchnorrSignature {
+	if len(signatureBin) != 64 {
+		panic(fmt.Errorf("SyntaxError: %v", "Schnorr signatures are 64 bytes"))
+	}
+	return NewSchnorrSignature(new(big.Int).SetBytes(signatureBin[:32]), new(big.Int).SetBytes(signatureBin[32:]))
+}
+
+//signSchnorr follows the BIP340 default signing algorithm. auxRand should
+//be 32 fresh random bytes, nil is treated as all zeros.
+func (pk *PrivateKey) signSchnorr(msg []byte, auxRand []byte) *SchnorrSignature {
+	if auxRand == nil {
+		auxRand = make([]byte, 32)
+	}
+	if len(auxRand) != 32 {
+		panic(fmt.Errorf("ValueError: %v", "auxiliary randomness must be 32 bytes"))
+	}
+	//the secret that goes with the even y version of our point
+	d := new(big.Int).Set(pk.secret)
+	if !pk.point.hasEvenY() {
+		d.Sub(N, d)
+	}
+	pubkey := pk.point.xonly()
+	//t = d xor hash_aux(a), masks the secret before it meets the nonce hash
+	t := intToBytes32(d)
+	aux := taggedHash("BIP0340/aux", auxRand)
+	for i := range t {
+		t[i] ^= aux[i]
+	}
+	rand := taggedHash("BIP0340/nonce", t, pubkey, msg)
+	k := mod(new(big.Int).SetBytes(rand[:]), N)
+	if k.Sign() == 0 {
+		panic(fmt.Errorf("RuntimeError: %v", "nonce is zero"))
+	}
+	R := G.rmulSecret(k)
+	if !R.hasEvenY() {
+		k.Sub(N, k)
+	}
+	rBytes := R.xonly()
+	eHash := taggedHash("BIP0340/challenge", rBytes, pubkey, msg)
+	//s = k + e*d, on constant time scalars
+	var eD, sum scalarVal
+	eVal, dVal, kVal := newScalarVal(new(big.Int).SetBytes(eHash[:])), newScalarVal(d), newScalarVal(k)
+	eD.mul(&eVal, &dVal)
+	sum.add(&kVal, &eD)
+	sig := NewSchnorrSignature(R.x.num, sum.big())
+	//make sure we never hand out a bad signature
+	if !verifySchnorr(pubkey, msg, sig) {
+		panic(fmt.Errorf("RuntimeError: %v", "created an invalid Schnorr signature"))
+	}
+	return sig
+}
+
+//verifySchnorr checks a BIP340 signature against a 32 byte x-only public key
+func verifySchnorr(pubkey []byte, msg []byte, sig *SchnorrSignature) bool {
+	if sig.r.Cmp(P) >= 0 || sig.s.Cmp(N) >= 0 {
+		return false
+	}
+	//an x that is not on the curve just means invalid
+	point, err := new(S256Point).parseXonly(pubkey)
+	if err != nil {
+		return false
+	}
+	eHash := taggedHash("BIP0340/challenge", intToBytes32(sig.r), pubkey, msg)
+	e := mod(new(big.Int).SetBytes(eHash[:]), N)
+	//R = s*G - e*P
+	p := newProjPoint(point)
+	var total projPoint
+	minusE := mod(new(big.Int).Neg(e), N)
+	if GLVENABLED {
+		total = shamirMulGLV(sig.s, minusE, &p)
+	} else {
+		total = shamirMul(sig.s, minusE, &p)
+	}
+	R := total.toAffine()
+	if R.isInfinity() || !R.hasEvenY() {
+		return false
+	}
+	return R.x.num.Cmp(sig.r) == 0
+}
@@ -0,0 +1,40 @@
+package ecc
+
+import (
+	"bytes"
+	"encoding/csv"
+	"math/big"
+	"os"
+	"testing"
+)
+
+//TestBIP340Vectors runs test-vectors.csv from BIP340. Rows with a secret
+//key are signed and checked byte for byte, every row is verified.
+func TestBIP340Vectors(t *testing.T) {
+	f, err := os.Open("testdata/bip340_vectors.csv")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer f.Close()
+	rows, err := csv.NewReader(f).ReadAll()
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, row := range rows[1:] {
+		index, secret, pubkey, auxRand, msg, sigBin := row[0], row[1], mustHex(row[2]), row[3], mustHex(row[4]), mustHex(row[5])
+		want := row[6] == "TRUE"
+		if secret != "" {
+			privateKey := NewPrivateKey(new(big.Int).SetBytes(mustHex(secret)))
+			if got := privateKey.point.xonly(); !bytes.Equal(got, pubkey) {
+				t.Errorf("row %s: public key %X, want %X", index, got, pubkey)
+			}
+			if got := privateKey.signSchnorr(msg, mustHex(auxRand)).serialize(); !bytes.Equal(got, sigBin) {
+				t.Errorf("row %s: signature %X, want %X", index, got, sigBin)
+			}
+		}
+		sig := new(SchnorrSignature).parse(sigBin)
+		if got := verifySchnorr(pubkey, msg, sig); got != want {
+			t.Errorf("row %s (%s): verify = %v, want %v", index, row[7], got, want)
+		}
+	}
+}
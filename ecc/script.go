@@ -2,10 +2,9 @@ package ecc
 
 import (
 	"bytes"
+	"encoding/hex"
 	"fmt"
 	"log"
-	"reflect"
-	"strconv"
 	"strings"
 )
 
@@ -19,9 +18,8 @@ type Script struct {
 }
 
 func NewScript(cmds []interface{}) (s *Script) {
-	cmds = nil
 	s = new(Script)
-	if &cmds == nil {
+	if cmds == nil {
 		s.cmds = []interface{}{}
 	} else {
 		s.cmds = cmds
@@ -29,68 +27,79 @@ func NewScript(cmds []interface{}) (s *Script) {
 	return
 }
 
-func (s *Script) Repr() {
+func (s *Script) Repr() string {
 	var result []string
 	var name string
 	for _, cmd := range s.cmds {
-		if reflect.TypeOf(cmd).Kind() == reflect.Int {
-			if OPCODENAMES[cmd.(int)] != "" {
-				name = OPCODENAMES[cmd.(int)] //didnt use get function
+		if op, ok := cmd.(int); ok {
+			if OPCODENAMES[op] != "" {
+				name = OPCODENAMES[op] //didnt use get function
 			} else {
-				name = fmt.Sprintf("OP_[%d]", cmd)
+				name = fmt.Sprintf("OP_[%d]", op)
 			}
 			result = append(result, name)
 		} else {
-			result = append(result, strconv.FormatInt(cmd.(int64), 16))
+			result = append(result, hex.EncodeToString(cmd.([]byte)))
 		}
 	}
-	strings.Join(result, " ")
+	return strings.Join(result, " ")
 }
 
-func (S *Script) parse(s []byte) *Script {
-	length := readVarint(s)
-	var cmds []interface{}
-	count := 0
-	for int64(count) < length {
+//add is the book's __add__, the commands of s followed by those of other
+func (s *Script) add(other *Script) *Script {
+	cmds := append([]interface{}{}, s.cmds...)
+	return NewScript(append(cmds, other.cmds...))
+}
+
+//parse reads a varint length prefixed script off the stream
+func (S *Script) parse(s *bytes.Reader) (*Script, error) {
+	length, err := readVarint(s)
+	if err != nil {
+		return nil, err
+	}
+	cmds := []interface{}{}
+	count := uint64(0)
+	for count < length {
 		//get the current byte
-		var byt bytes.Buffer
-		byt.Write(s)
-		current, _ := byt.ReadByte()
+		current, err := s.ReadByte()
+		if err != nil {
+			return nil, fmt.Errorf("SyntaxError: %v", "parsing script failed")
+		}
 		//increment the bytes we've read
 		count += 1
 		//convert current byte to integer
 		currentByte := int(current)
+		var n uint64
 		//if the current byte is between 1 and 75 inclusive
 		if currentByte >= 1 && currentByte <= 75 {
-			n := currentByte
-			//add the next n bytes as an cmd
-			x, _ := byt.ReadBytes(byte(n))
-			cmds = append(cmds, x)
-			//increase the count by n
-			count += n
-		} else if currentByte == 76 {
-			//op_pushdata1
-			x, _ := byt.ReadBytes(1)
-			dataLength := littleEndianToInt(x)
-			y, _ := byt.ReadBytes(byte(dataLength))
-			cmds = append(cmds, y)
-			count += int(dataLength) + 1
-		} else if currentByte == 77 {
-			//op_pushdata2
-			x, _ := byt.ReadBytes(2)
-			dataLength := littleEndianToInt(x)
-			y, _ := byt.ReadBytes(byte(dataLength))
-			cmds = append(cmds, y)
-			count += int(dataLength) + 2
+			//the next n bytes are an cmd
+			n = uint64(currentByte)
+		} else if currentByte >= 76 && currentByte <= 78 {
+			//op_pushdata1, op_pushdata2 and op_pushdata4 give the length
+			//in the next 1, 2 or 4 bytes
+			size := uint64(1) << uint(currentByte-76)
+			x, err := readBytes(s, size)
+			if err != nil {
+				return nil, fmt.Errorf("SyntaxError: %v", "parsing script failed")
+			}
+			n = uint64(littleEndianToInt(x))
+			count += size
 		} else {
-			op_code := currentByte
-			cmds = append(cmds, op_code)
+			cmds = append(cmds, currentByte)
+			continue
 		}
+		y, err := readBytes(s, n)
+		if err != nil {
+			return nil, fmt.Errorf("SyntaxError: %v", "parsing script failed")
+		}
+		cmds = append(cmds, y)
+		//increase the count by n
+		count += n
 	}
-	if count != int(length) {
-		panic(fmt.Errorf("SyntaxError: %v", "parsing script failed"))
+	if count != length {
+		return nil, fmt.Errorf("SyntaxError: %v", "parsing script failed")
 	}
-	return NewScript(cmds)
+	return NewScript(cmds), nil
 }
 
 func (s *Script) rawSerialize() []byte {
@@ -99,28 +108,29 @@ func (s *Script) rawSerialize() []byte {
 	//go through each cmd
 	for _, cmd := range s.cmds {
 		//if the cmd is an integer, it's an opcode
-		if reflect.TypeOf(cmd).Kind() == reflect.Int {
+		if op, ok := cmd.(int); ok {
 			//turn the cmd into a single byte integer using int_to_little_endian
-			result = append(result, intToLittleEndian(cmd.(int), 1)...)
+			result = append(result, intToLittleEndian(op, 1)...)
 		} else {
 			//otherwise, this is an element
+			element := cmd.([]byte)
 			//get the length in bytes
-			length := len(cmd.([]byte))
+			length := len(element)
 			//for large lengths, we have to use a pushdata opcode
-			if length < 75 {
+			if length <= 75 {
 				result = append(result, intToLittleEndian(length, 1)...)
-			} else if length > 75 && length < 256 {
+			} else if length < 256 {
 				//76 is pushdata1
 				result = append(result, intToLittleEndian(76, 1)...)
 				result = append(result, intToLittleEndian(length, 1)...)
-			} else if length >= 256 && length <= 520 {
+			} else if length <= 520 {
 				//77 is pushdata2
 				result = append(result, intToLittleEndian(77, 1)...)
 				result = append(result, intToLittleEndian(length, 2)...)
 			} else {
 				panic(fmt.Errorf("ValueError: %v", "too long an cmd"))
 			}
-			result = append(result, cmd.(byte))
+			result = append(result, element...)
 		}
 	}
 	return result
@@ -133,90 +143,101 @@ func (s *Script) serialize() []byte {
 }
 
 func (s *Script) evaluate(z interface{}) bool {
-	cmds := s.cmds[:]
-	var stack []byte
-	var altstack []byte
-	for len(cmds) > 0 {
-		cmd := func(s *[]interface{}, i int) byte {
-			popped := (*s)[i]
-			*s = append((*s)[:i], (*s)[i+1:]...)
-			return popped.(byte)
-		}(&cmds, 0)
-		if reflect.TypeOf(cmd).Kind() == reflect.Int {
-			operation := OPCODEFUNCTIONS[int(cmd)]
-			if func() int {
-				for i, v := range [2]int{99, 100} {
-					if byte(v) == cmd {
-						return i
-					}
-				}
-				return -1
-			}() != -1 {
-				if !operation(stack, cmds) {
-					log.Print("bad op: {}", OPCODENAMES[int(cmd)])
-					return false
-				}
-			} else if func() int {
-				for i, v := range [2]int{107, 108} {
-					if byte(v) == cmd {
-						return i
-					}
-				}
-				return -1
-			}() != -1 {
-				if !operation(stack, altstack) {
-					log.Print("bad op: {}", OPCODENAMES[int(cmd)])
-					return false
-				}
-			} else if func() int {
-				for i, v := range [4]int{172, 173, 174, 175} {
-					if byte(v) == cmd {
-						return i
-					}
-				}
-				return -1
-			}() != -1 {
-				if !operation(stack, z) {
-					log.Print("bad op: {}", OPCODENAMES[int(cmd)])
-					return false
-				}
-			} else if !operation(stack, nil) {
-				log.Print("bad op: {}", OPCODENAMES[int(cmd)])
-				return false
-			}
-		} else {
-			stack = append(stack, cmd)
-		}
-	}
-	if len(stack) == 0 {
+	return s.evaluateWith(nil, z, nil)
+}
+
+//evaluateWith runs the script on top of an initial stack, a segwit input's
+//witness items. lock is passed to OP_CHECKLOCKTIMEVERIFY and
+//OP_CHECKSEQUENCEVERIFY, nil makes them fail.
+func (s *Script) evaluateWith(stack [][]byte, z interface{}, lock *LockContext) bool {
+	stack, ok := s.run(stack, z, lock)
+	if !ok || len(stack) == 0 {
 		return false
 	}
-	if reflect.DeepEqual(func(s *[]byte) interface{} {
-		i := len(*s) - 1
-		popped := (*s)[i]
-		*s = (*s)[:i]
-		return popped
-	}(&stack), []byte("")) {
-		return false
+	return castToBool(pop(&stack))
+}
+
+//run is evaluateWith returning the stack the script leaves, segwit needs
+//it to hold exactly one true element
+func (s *Script) run(stack [][]byte, z interface{}, lock *LockContext) ([][]byte, bool) {
+	cmds := append([]interface{}{}, s.cmds...)
+	stack = append([][]byte{}, stack...)
+	var altstack [][]byte
+	for len(cmds) > 0 {
+		cmd := cmds[0]
+		cmds = cmds[1:]
+		op, ok := cmd.(int)
+		if !ok {
+			stack = append(stack, cmd.([]byte))
+			continue
+		}
+		operation, ok := OPCODEFUNCTIONS[op]
+		if !ok {
+			log.Printf("bad op: %s", OPCODENAMES[op])
+			return nil, false
+		}
+		var arg interface{}
+		switch op {
+		case 99, 100:
+			arg = &cmds
+		case 107, 108:
+			arg = &altstack
+		case 172, 173, 174, 175:
+			arg = z
+		case 177, 178:
+			arg = lock
+		}
+		if !operation(&stack, arg) {
+			log.Printf("bad op: %s", OPCODENAMES[op])
+			return nil, false
+		}
 	}
-	return true
+	return stack, true
 }
 
 func (s *Script) isP2pkhScriptPubkey() bool {
 	//"Returns whether this follows the
 	//OP_DUP OP_HASH160 <20 byte hash> OP_EQUALVERIFY OP_CHECKSIG pattern."
-	return len(s.cmds) == 5 && s.cmds[0] == 118 &&
-		(s.cmds[0] == 118 && s.cmds[1] == 169) &&
-		(s.cmds[0] == 118 && s.cmds[1] == 169 && reflect.TypeOf(s.cmds[2]).Kind() == reflect.Slice) &&
-		(s.cmds[0] == 118 && s.cmds[1] == 169 && reflect.TypeOf(s.cmds[2]).Kind() == reflect.Slice && len(s.cmds[2].([]byte)) == 20) &&
-		(s.cmds[0] == 118 && s.cmds[1] == 169 && reflect.TypeOf(s.cmds[2]).Kind() == reflect.Slice && len(s.cmds[2].([]byte)) == 20 && s.cmds[3] == 136) &&
-		(s.cmds[0] == 118 && s.cmds[1] == 169 && reflect.TypeOf(s.cmds[2]).Kind() == reflect.Slice && len(s.cmds[2].([]byte)) == 20 && s.cmds[3] == 136 && s.cmds[4] == 172)
+	if len(s.cmds) != 5 || s.cmds[0] != 118 || s.cmds[1] != 169 || s.cmds[3] != 136 || s.cmds[4] != 172 {
+		return false
+	}
+	h160, ok := s.cmds[2].([]byte)
+	return ok && len(h160) == 20
 }
 
 func (s *Script) isP2shScriptPubkey() bool {
 	//"Returns whether this follows the OP_HASH160 <20 byte hash> OP_EQUAL pattern."
-	return len(s.cmds) == 3 && s.cmds[0] == 169 &&
-		(s.cmds[0] == 169 && reflect.TypeOf(s.cmds[1]).Kind() == reflect.Slice) &&
-		(s.cmds[0] == 169 && reflect.TypeOf(s.cmds[1]).Kind() == reflect.Slice && len(s.cmds[1].([]byte)) == 20) &&
-		(s.cmds[0] == 169 && reflect.TypeOf(s.cmds[1]).Kind() == reflect.Slice && len(s.cmds[1].([]byte)) == 20 && s.cmds[2] == 135)
+	if len(s.cmds) != 3 || s.cmds[0] != 169 || s.cmds[2] != 135 {
+		return false
+	}
+	h160, ok := s.cmds[1].([]byte)
+	return ok && len(h160) == 20
+}
+
+func (s *Script) isP2pkScriptPubkey() bool {
+	//"Returns whether this follows the <pubkey> OP_CHECKSIG pattern."
+	if len(s.cmds) != 2 || s.cmds[1] != 172 {
+		return false
+	}
+	pubkey, ok := s.cmds[0].([]byte)
+	return ok && (len(pubkey) == 33 || len(pubkey) == 65)
+}
+
+func (s *Script) isMultisigScriptPubkey() bool {
+	//"Returns whether this follows the OP_m <pubkey>... OP_n OP_CHECKMULTISIG pattern."
+	if len(s.cmds) < 4 || s.cmds[len(s.cmds)-1] != 174 {
+		return false
+	}
+	m, ok1 := s.cmds[0].(int)
+	n, ok2 := s.cmds[len(s.cmds)-2].(int)
+	if !ok1 || !ok2 || m < 81 || m > 96 || n < m || n > 96 || n-80 != len(s.cmds)-3 {
+		return false
+	}
+	for _, cmd := range s.cmds[1 : len(s.cmds)-2] {
+		pubkey, ok := cmd.([]byte)
+		if !ok || (len(pubkey) != 33 && len(pubkey) != 65) {
+			return false
+		}
+	}
+	return true
 }
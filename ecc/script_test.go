@@ -0,0 +1,136 @@
+package ecc
+
+import (
+	"bytes"
+	"encoding/hex"
+	"math/big"
+	"testing"
+)
+
+func TestScriptEvaluateP2pk(t *testing.T) {
+	//the p2pk example from chapter 6 of Programming Bitcoin
+	z, _ := new(big.Int).SetString("7c076ff316692a3d7eb3c3bb0f8b1488cf72e1afcd929e29307032997a838a3d", 16)
+	sec := mustHex("04887387e452b8eacc4acfde10d9aaf7f6d9a0f975aabb10d006e4da568744d06c61de6d95231cd89026e286df3b6ae4a894a3378e393e93a0f45b666329a0ae34")
+	sig := mustHex("3045022000eff69ef2b1bd93a66ed5219add4fb51e11a840f404876325a1e8ffe0529a2c022100c7207fee197d27c618aea621406f6bf5ef6fca38681d82b2f06fddbdce6feab601")
+	scriptPubkey := NewScript([]interface{}{sec, 172})
+	//the book's signature has a high s, OP_CHECKSIG only takes the low one
+	if NewScript([]interface{}{sig}).add(scriptPubkey).evaluate(z) {
+		t.Fatal("high s p2pk passed")
+	}
+	parsed, err := new(Signature).parse(sig[:len(sig)-1])
+	if err != nil {
+		t.Fatal(err)
+	}
+	lowS := append([]byte(parsed.normalizeS().der()), byte(SIGHASHALL))
+	scriptSig := NewScript([]interface{}{lowS})
+	if !scriptSig.add(scriptPubkey).evaluate(z) {
+		t.Fatal("valid p2pk failed")
+	}
+	if scriptSig.add(scriptPubkey).evaluate(new(big.Int).Add(z, big.NewInt(1))) {
+		t.Fatal("p2pk passed with the wrong z")
+	}
+}
+
+func TestScriptEvaluateOps(t *testing.T) {
+	tests := []struct {
+		cmds []interface{}
+		want bool
+	}{
+		//2 3 OP_ADD 5 OP_EQUAL
+		{[]interface{}{82, 83, 147, 85, 135}, true},
+		{[]interface{}{82, 83, 147, 86, 135}, false},
+		//1 OP_IF 2 OP_ELSE 0 OP_ENDIF
+		{[]interface{}{81, 99, 82, 103, 0, 104}, true},
+		//0 OP_IF 2 OP_ELSE 0 OP_ENDIF
+		{[]interface{}{0, 99, 82, 103, 0, 104}, false},
+		//0 OP_NOTIF 1 OP_ENDIF
+		{[]interface{}{0, 100, 81, 104}, true},
+		//an OP_IF without its OP_ENDIF
+		{[]interface{}{81, 99, 81}, false},
+		//1 2 3 OP_ROT leaves 2 3 1, 1 OP_EQUALVERIFY 3 OP_EQUAL
+		{[]interface{}{81, 82, 83, 123, 81, 136, 83, 135}, true},
+		//1 2 OP_SWAP 2 OP_EQUAL
+		{[]interface{}{81, 82, 124, 82, 135}, false},
+		//1 OP_TOALTSTACK OP_FROMALTSTACK
+		{[]interface{}{81, 107, 108}, true},
+		//negative zero is false
+		{[]interface{}{[]byte{0x80}}, false},
+		{[]interface{}{[]byte{0, 0x80, 0}}, true},
+		//OP_CHECKLOCKTIMEVERIFY has no transaction to look at
+		{[]interface{}{81, 177}, false},
+		{[]interface{}{81, 106}, false},
+		{[]interface{}{[]byte("abc"), 169, []byte(hash160("abc")), 135}, true},
+	}
+	for i, test := range tests {
+		if got := NewScript(test.cmds).evaluate(nil); got != test.want {
+			t.Errorf("%d: %s got %v", i, NewScript(test.cmds).Repr(), got)
+		}
+	}
+}
+
+func TestScriptParse(t *testing.T) {
+	h160 := mustHex("bc3b654dca7e56b04dca18f2566cdaf02e8d9ada")
+	script := p2pkhScript(h160)
+	raw := script.serialize()
+	if hex.EncodeToString(raw) != "1976a914bc3b654dca7e56b04dca18f2566cdaf02e8d9ada88ac" {
+		t.Fatalf("serialize %x", raw)
+	}
+	parsed, err := new(Script).parse(bytes.NewReader(raw))
+	if err != nil || !parsed.isP2pkhScriptPubkey() || !bytes.Equal(parsed.serialize(), raw) {
+		t.Fatalf("parse %v %v", err, parsed)
+	}
+	//pushdata1 and pushdata2 round trip
+	for _, size := range []int{75, 76, 255, 256, 520} {
+		element := bytes.Repeat([]byte{7}, size)
+		raw := NewScript([]interface{}{element, 135}).serialize()
+		parsed, err := new(Script).parse(bytes.NewReader(raw))
+		if err != nil || !bytes.Equal(parsed.cmds[0].([]byte), element) || parsed.cmds[1] != 135 {
+			t.Fatalf("%d: %v", size, err)
+		}
+	}
+	//a push past the end of the script
+	for _, bad := range []string{"024c", "024cff", "03030102", "fd"} {
+		if _, err := new(Script).parse(bytes.NewReader(mustHex(bad))); err == nil {
+			t.Errorf("%s parsed", bad)
+		}
+	}
+}
+
+func TestTxParse(t *testing.T) {
+	//the transaction from chapter 5 of Programming Bitcoin
+	rawTx := mustHex("0100000001813f79011acb80925dfe69b3def355fe914bd1d96a3f5f71bf8303c6a989c7d1000000006b483045022100ed81ff192e75a3fd2304004dcadb746fa5e24c5031ccfcf21320b0277457c98f02207a986d955c6e0cb35d446a89d3f56100f4d7f67801c31967743a9c8e10615bed01210349fc4e631e3624a545de3f89f5d8684c7b8138bd94bdd531d2e213bf016b278afeffffff02a135ef01000000001976a914bc3b654dca7e56b04dca18f2566cdaf02e8d9ada88ac99c39800000000001976a9141c4bc762dd5423e332166702cb75f40df79fea1288ac19430600")
+	tx, err := new(Tx).parse(bytes.NewReader(rawTx), MAINNET)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if tx.version != 1 || len(tx.txIns) != 1 || len(tx.txOuts) != 2 || tx.locktime != 410393 {
+		t.Fatalf("fields %s", tx.Repr())
+	}
+	if tx.txIns[0].Repr() != "d1c789a9c60383bf715f3f6ad9d14b91fe55f3deb369fe5d9280cb1a01793f81:0" {
+		t.Fatalf("input %s", tx.txIns[0].Repr())
+	}
+	if tx.txIns[0].sequence != 0xfffffffe || tx.txOuts[0].amount != 32454049 || tx.txOuts[1].amount != 10011545 {
+		t.Fatalf("fields %s", tx.Repr())
+	}
+	if !bytes.Equal(tx.serialize(), rawTx) {
+		t.Fatal("serialize")
+	}
+	//the same transaction with a segwit marker, flag and witness
+	segwitTx := append(append([]byte{}, rawTx[:4]...), 0, 1)
+	segwitTx = append(segwitTx, rawTx[4:len(rawTx)-4]...)
+	segwitTx = append(segwitTx, 2, 1, 0xaa, 0)
+	segwitTx = append(segwitTx, rawTx[len(rawTx)-4:]...)
+	tx2, err := new(Tx).parse(bytes.NewReader(segwitTx), MAINNET)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !tx2.segwit || len(tx2.txIns[0].witness) != 2 || tx2.id() != tx.id() {
+		t.Fatal("segwit")
+	}
+	//truncated anywhere is an error, not a panic
+	for i := 0; i < len(rawTx); i += 7 {
+		if _, err := new(Tx).parse(bytes.NewReader(rawTx[:i]), MAINNET); err == nil {
+			t.Fatalf("parsed %d bytes", i)
+		}
+	}
+}
@@ -0,0 +1,132 @@
+package ecc
+
+import (
+	"fmt"
+	"math/big"
+)
+
+//Tweaking moves a key pair by a public scalar t: the private key becomes
+//d+t (or d*t) and the public key P+t*G (or t*P), so both stay a pair.
+//Pay-to-contract and taproot (BIP341) output keys are built this way.
+
+func checkTweak(tweak *big.Int) error {
+	if tweak == nil || tweak.Sign() < 0 || tweak.Cmp(N) >= 0 {
+		return fmt.Errorf("ValueError: %v", "tweak not in range 0 to N-1")
+	}
+	return nil
+}
+
+//tweakAdd returns the key d+t, the tweak is public but the secret is not
+//so the sum is done on constant time scalars
+func (pk *PrivateKey) tweakAdd(tweak *big.Int) (*PrivateKey, error) {
+	if err := checkTweak(tweak); err != nil {
+		return nil, err
+	}
+	var sum scalarVal
+	d, t := newScalarVal(pk.secret), newScalarVal(tweak)
+	sum.add(&d, &t)
+	secret := sum.big()
+	if secret.Sign() == 0 {
+		return nil, fmt.Errorf("ValueError: %v", "tweaked secret is zero")
+	}
+	return NewPrivateKey(secret), nil
+}
+
+//tweakMul returns the key d*t, a zero tweak would lose the key
+func (pk *PrivateKey) tweakMul(tweak *big.Int) (*PrivateKey, error) {
+	if err := checkTweak(tweak); err != nil {
+		return nil, err
+	}
+	if tweak.Sign() == 0 {
+		return nil, fmt.Errorf("ValueError: %v", "tweak is zero")
+	}
+	var product scalarVal
+	d, t := newScalarVal(pk.secret), newScalarVal(tweak)
+	product.mul(&d, &t)
+	return NewPrivateKey(product.big()), nil
+}
+
+//tweakAdd returns P+t*G, everything involved is public
+func (sp *S256Point) tweakAdd(tweak *big.Int) (*S256Point, error) {
+	if err := checkTweak(tweak); err != nil {
+		return nil, err
+	}
+	if sp.isInfinity() {
+		return nil, fmt.Errorf("ValueError: %v", "cannot tweak the point at infinity")
+	}
+	result := sp.SAdd(G.Rmul2(tweak))
+	if result.isInfinity() {
+		return nil, fmt.Errorf("ValueError: %v", "tweaked point is the point at infinity")
+	}
+	return result, nil
+}
+
+//tweakMul returns t*P
+func (sp *S256Point) tweakMul(tweak *big.Int) (*S256Point, error) {
+	if err := checkTweak(tweak); err != nil {
+		return nil, err
+	}
+	if tweak.Sign() == 0 {
+		return nil, fmt.Errorf("ValueError: %v", "tweak is zero")
+	}
+	if sp.isInfinity() {
+		return nil, fmt.Errorf("ValueError: %v", "cannot tweak the point at infinity")
+	}
+	return sp.Rmul2(tweak), nil
+}
+
+//taprootTweak is hash_TapTweak(P || merkle root) as an integer, a key
+//path only output has no script tree and hashes just P
+func taprootTweak(internalKey []byte, merkleRoot []byte) (*big.Int, error) {
+	if len(internalKey) != 32 {
+		return nil, fmt.Errorf("ValueError: %v", "internal key must be 32 bytes x-only")
+	}
+	if merkleRoot != nil && len(merkleRoot) != 32 {
+		return nil, fmt.Errorf("ValueError: %v", "merkle root must be 32 bytes")
+	}
+	h := taggedHash("TapTweak", internalKey, merkleRoot)
+	t := new(big.Int).SetBytes(h[:])
+	if t.Cmp(N) >= 0 {
+		return nil, fmt.Errorf("ValueError: %v", "taproot tweak not below N")
+	}
+	return t, nil
+}
+
+//taprootOutputKey computes the BIP341 output key Q = lift_x(P) + t*G from
+//the internal key. It returns Q, whose x-only form goes in the ScriptPubKey,
+//and whether Q has an odd y, which script path spends put in the control block.
+func (sp *S256Point) taprootOutputKey(merkleRoot []byte) (*S256Point, bool, error) {
+	if sp.isInfinity() {
+		return nil, false, fmt.Errorf("ValueError: %v", "internal key is the point at infinity")
+	}
+	internalKey := sp.xonly()
+	t, err := taprootTweak(internalKey, merkleRoot)
+	if err != nil {
+		return nil, false, err
+	}
+	//only the x coordinate of the internal key is committed to
+	internal, err := liftX(sp.x.num)
+	if err != nil {
+		return nil, false, err
+	}
+	Q, err := internal.tweakAdd(t)
+	if err != nil {
+		return nil, false, err
+	}
+	return Q, !Q.hasEvenY(), nil
+}
+
+//taprootTweak returns the private key for the BIP341 output key of this
+//key, the key to sign key path spends with. The secret is negated first
+//when our point has an odd y, since the internal key is its even y twin.
+func (pk *PrivateKey) taprootTweak(merkleRoot []byte) (*PrivateKey, error) {
+	t, err := taprootTweak(pk.point.xonly(), merkleRoot)
+	if err != nil {
+		return nil, err
+	}
+	d := pk
+	if !pk.point.hasEvenY() {
+		d = NewPrivateKey(new(big.Int).Sub(N, pk.secret))
+	}
+	return d.tweakAdd(t)
+}
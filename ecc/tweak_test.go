@@ -0,0 +1,158 @@
+package ecc
+
+import (
+	"bytes"
+	"math/big"
+	"testing"
+)
+
+//tapLeafHash is hash_TapLeaf(leaf version || compact size script || script)
+func tapLeafHash(leafVersion byte, script []byte) []byte {
+	h := taggedHash("TapLeaf", []byte{leafVersion}, encodeVarint(len(script)), script)
+	return h[:]
+}
+
+//TestBIP341Wallet runs the single leaf scriptPubKey entries of BIP341's
+//wallet-test-vectors.json. Key path only outputs have no control block,
+//for the others the parity of the output key is bit 0 of its first byte.
+func TestBIP341Wallet(t *testing.T) {
+	tests := []struct {
+		internalPubkey string
+		script         string
+		leafHash       string
+		tweak          string
+		tweakedPubkey  string
+		controlBlock   string
+		address        string
+	}{
+		{
+			"d6889cb081036e0faefa3a35157ad71086b123b2b144b649798b494c300a961d",
+			"",
+			"",
+			"b86e7be8f39bab32a6f2c0443abbc210f0edac0e2c53d501b36b64437d9c6c70",
+			"53a1f6e454df1aa2776a2814a721372d6258050de330b3c6d10ee8f4e0dda343",
+			"",
+			"bc1p2wsldez5mud2yam29q22wgfh9439spgduvct83k3pm50fcxa5dps59h4z5",
+		},
+		{
+			"187791b6f712a8ea41c8ecdd0ee77fab3e85263b37e1ec18a3651926b3a6cf27",
+			"20d85a959b0290bf19bb89ed43c916be835475d013da4b362117393e25a48229b8ac",
+			"5b75adecf53548f3ec6ad7d78383bf84cc57b55a3127c72b9a2481752dd88b21",
+			"cbd8679ba636c1110ea247542cfbd964131a6be84f873f7f3b62a777528ed001",
+			"147c9c57132f6e7ecddba9800bb0c4449251c92a1e60371ee77557b6620f3ea3",
+			"c1187791b6f712a8ea41c8ecdd0ee77fab3e85263b37e1ec18a3651926b3a6cf27",
+			"bc1pz37fc4cn9ah8anwm4xqqhvxygjf9rjf2resrw8h8w4tmvcs0863sa2e586",
+		},
+		{
+			"93478e9488f956df2396be2ce6c5cced75f900dfa18e7dabd2428aae78451820",
+			"20b617298552a72ade070667e86ca63b8f5789a9fe8731ef91202a91c9f3459007ac",
+			"c525714a7f49c28aedbbba78c005931a81c234b2f6c99a73e4d06082adc8bf2b",
+			"6af9e28dbf9d6aaf027696e2598a5b3d056f5fd2355a7fd5a37a0e5008132d30",
+			"e4d810fd50586274face62b8a807eb9719cef49c04177cc6b76a9a4251d5450e",
+			"c093478e9488f956df2396be2ce6c5cced75f900dfa18e7dabd2428aae78451820",
+			"bc1punvppl2stp38f7kwv2u2spltjuvuaayuqsthe34hd2dyy5w4g58qqfuag5",
+		},
+	}
+	for _, test := range tests {
+		internalKey := mustHex(test.internalPubkey)
+		var merkleRoot []byte
+		if test.script != "" {
+			merkleRoot = tapLeafHash(0xc0, mustHex(test.script))
+			if !bytes.Equal(merkleRoot, mustHex(test.leafHash)) {
+				t.Errorf("%s: leaf hash %x, want %s", test.internalPubkey, merkleRoot, test.leafHash)
+			}
+		}
+		tweak, err := taprootTweak(internalKey, merkleRoot)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if tweak.Cmp(hexInt(test.tweak)) != 0 {
+			t.Errorf("%s: tweak %x, want %s", test.internalPubkey, tweak, test.tweak)
+		}
+		internal, err := new(S256Point).parseXonly(internalKey)
+		if err != nil {
+			t.Fatal(err)
+		}
+		Q, odd, err := internal.taprootOutputKey(merkleRoot)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if !bytes.Equal(Q.xonly(), mustHex(test.tweakedPubkey)) {
+			t.Errorf("%s: output key %x, want %s", test.internalPubkey, Q.xonly(), test.tweakedPubkey)
+		}
+		if test.controlBlock != "" {
+			controlBlock := append([]byte{0xc0}, internalKey...)
+			if odd {
+				controlBlock[0] |= 1
+			}
+			if !bytes.Equal(controlBlock, mustHex(test.controlBlock)) {
+				t.Errorf("%s: control block %x, want %s", test.internalPubkey, controlBlock, test.controlBlock)
+			}
+		}
+		address, err := internal.p2trAddress(MAINNET, merkleRoot)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if address != test.address {
+			t.Errorf("%s: address %s, want %s", test.internalPubkey, address, test.address)
+		}
+	}
+}
+
+//TestBIP341KeyPath is the first input of keyPathSpending, a key path only
+//output whose internal key has an odd y
+func TestBIP341KeyPath(t *testing.T) {
+	privateKey := NewPrivateKey(hexInt("6b973d88838f27366ed61c9ad6367663045cb456e28335c109e30717ae0c6baa"))
+	tweaked, err := privateKey.taprootTweak(nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want := hexInt("2405b971772ad26915c8dcdf10f238753a9b837e5f8e6a86fd7c0cce5b7296d9"); tweaked.secret.Cmp(want) != 0 {
+		t.Errorf("tweaked secret %x, want %x", tweaked.secret, want)
+	}
+	Q, _, err := privateKey.point.taprootOutputKey(nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !bytes.Equal(tweaked.point.xonly(), Q.xonly()) {
+		t.Errorf("tweaked key %x does not match the output key %x", tweaked.point.xonly(), Q.xonly())
+	}
+	if _, err := taprootTweak(make([]byte, 31), nil); err == nil {
+		t.Error("31 byte internal key accepted")
+	}
+	if _, err := new(S256Point).tweakAdd(new(big.Int).Set(N)); err == nil {
+		t.Error("tweak of N accepted")
+	}
+}
+
+//TestTweakMul checks both sides of the multiplicative tweak agree,
+//(d*t)*G == t*(d*G), and the tweaks that are refused
+func TestTweakMul(t *testing.T) {
+	for i := 0; i < 20; i++ {
+		privateKey := NewPrivateKey(randomScalar(t))
+		tweak := randomScalar(t)
+		if i == 0 {
+			tweak = new(big.Int).Sub(N, big.NewInt(1))
+		}
+		tweakedKey, err := privateKey.tweakMul(tweak)
+		if err != nil {
+			t.Fatal(err)
+		}
+		tweakedPoint, err := privateKey.point.tweakMul(tweak)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if tweakedKey.point.sec(true) != tweakedPoint.sec(true) {
+			t.Fatalf("tweakMul(%x): (d*t)*G = %s, t*(d*G) = %s", tweak, tweakedKey.point.Repr(), tweakedPoint.Repr())
+		}
+	}
+	privateKey := NewPrivateKey(big.NewInt(0xc0ffee))
+	for _, tweak := range []*big.Int{big.NewInt(0), new(big.Int).Set(N), new(big.Int).Add(N, big.NewInt(1)), big.NewInt(-1)} {
+		if _, err := privateKey.tweakMul(tweak); err == nil {
+			t.Errorf("PrivateKey.tweakMul(%x) accepted", tweak)
+		}
+		if _, err := privateKey.point.tweakMul(tweak); err == nil {
+			t.Errorf("S256Point.tweakMul(%x) accepted", tweak)
+		}
+	}
+}
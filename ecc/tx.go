@@ -2,151 +2,103 @@ package ecc
 
 import (
 	"bytes"
-	"encoding/binary"
+	"crypto/sha256"
 	"encoding/hex"
+	"encoding/json"
 	"fmt"
 	"io/ioutil"
+	"math/big"
 	"net/http"
-	"os"
-	"reflect"
-	"strconv"
 	"strings"
 )
 
 type TxFetcher struct {
-	cache map[int]*Tx
+	cache map[string]*Tx
 }
 
-func (Tf *TxFetcher) getUrl(testnet bool) string {
-	if bool(testnet) {
-		return "https://blockstream.info/testnet/api/"
-	} else {
-		return "https://blockstream.info/api/"
-	}
+func NewTxFetcher() (Tf *TxFetcher) {
+	Tf = new(TxFetcher)
+	Tf.cache = make(map[string]*Tx)
+	return
 }
 
-func (Tf *TxFetcher) fetch(txId int, testnet bool, fresh bool) *Tx {
-	testnet = false
-	fresh = false
+//txFetcher is the shared cache TxIn lookups go through
+var txFetcher = NewTxFetcher()
 
-	if bool(fresh) || func() int {
-		for i, v := range Tf.cache {
-			if v == txId {
-				return i
-			}
+func (Tf *TxFetcher) getUrl(net *NetworkParams) (string, error) {
+	if net.explorerUrl == "" {
+		return "", fmt.Errorf("ValueError: no block explorer for %s", net.name)
+	}
+	return net.explorerUrl, nil
+}
+
+//fetch looks txId up in the cache or else on the network's block explorer
+func (Tf *TxFetcher) fetch(txId string, net *NetworkParams, fresh bool) (*Tx, error) {
+	if _, ok := Tf.cache[txId]; fresh || !ok {
+		explorerUrl, err := Tf.getUrl(net)
+		if err != nil {
+			return nil, err
 		}
-		return -1
-	}() == -1 {
-		url := fmt.Sprintf("%s/tx/%x/hex", Tf.getUrl(testnet), txId)
-		var raw []byte
+		url := fmt.Sprintf("%stx/%s/hex", explorerUrl, txId)
 		response, err := http.Get(url)
 		if err != nil {
-			panic(err)
+			return nil, err
 		}
 		defer response.Body.Close()
-		func() {
-			defer func() {
-				if r := recover(); r != nil {
-					if err, ok := r.(error); ok {
-						if strings.HasPrefix(err.Error(), "ValueError") {
-							panic(
-								fmt.Errorf(
-									"ValueError: %v",
-									"unexpected response: %s", func() string {
-										body, err := ioutil.ReadAll(response.Body)
-										if err != nil {
-											panic(err)
-										}
-										return string(body)
-									}(),
-								),
-							)
-							return
-						}
-					}
-					panic(r)
-				}
-			}()
-			raw = []byte(strings.TrimSpace(func() string {
-				body, err := ioutil.ReadAll(response.Body)
-				if err != nil {
-					panic(err)
-				}
-				return string(body)
-			}()))
-		}()
-		/*data, err := hex.DecodeString(raw)
+		body, err := ioutil.ReadAll(response.Body)
 		if err != nil {
-			panic(err)
+			return nil, err
 		}
-		b := fmt.Sprintf("% x", data) //from hex string to byte array
-		raw = []byte(b)*/
-		if raw[4] == 0 {
-			raw := append(raw[:4], raw[6:]...)
-			tx := new(Tx)
-			tx = tx.parse(raw, testnet)
-			tx.locktime = littleEndianToInt(raw[len(raw)-4:])
-		} else {
-			tx := new(Tx)
-			tx = tx.parse(raw, testnet)
+		raw, err := hex.DecodeString(strings.TrimSpace(string(body)))
+		if err != nil {
+			return nil, fmt.Errorf("ValueError: unexpected response: %s", body)
+		}
+		tx, err := new(Tx).parse(bytes.NewReader(raw), net)
+		if err != nil {
+			return nil, err
 		}
-		tx := new(Tx)
-		if !reflect.DeepEqual(tx.id(), txId) {
-			panic(fmt.Errorf("ValueError: %v", "not the same id: %s vs %d", tx.id(), txId))
+		if tx.id() != txId {
+			return nil, fmt.Errorf("ValueError: not the same id: %s vs %s", tx.id(), txId)
 		}
 		Tf.cache[txId] = tx
 	}
-	Tf.cache[txId].testnet = testnet
-	return Tf.cache[txId]
+	Tf.cache[txId].net = net
+	return Tf.cache[txId], nil
 }
 
-func (Tf *TxFetcher) dumpCache(filename string) {
-	f := func() *os.File {
-		f, err := os.OpenFile(filename, os.O_WRONLY|os.O_TRUNC, 0o777)
-		if err != nil {
-			panic(err)
-		}
-		return f
-	}()
-	defer func() {
-		if err := f.Close(); err != nil {
-			panic(err)
-		}
-	}()
-	to_dump := func() (d map[interface{}]interface{}) {
-		d = make(map[interface{}]interface{})
-		for k, tx := range Tf.cache {
-			d[k] = hex.EncodeToString(tx.serialize())
-		}
-		return
-	}()
-	s := json.dumps(to_dump, true, 4)
-	func() int {
-		n, err := f.WriteString(s)
-		if err != nil {
-			panic(err)
-		}
-		return n
-	}()
+func (Tf *TxFetcher) dumpCache(filename string) error {
+	toDump := make(map[string]string)
+	for k, tx := range Tf.cache {
+		toDump[k] = hex.EncodeToString(tx.serialize())
+	}
+	s, err := json.MarshalIndent(toDump, "", "    ")
+	if err != nil {
+		return err
+	}
+	return ioutil.WriteFile(filename, s, 0o644)
 }
 
-func (cls *TxFetcher) loadCache(filename string) {
-	//need to convert this python line to go
-	//disk_cache = json.loads(open(filename, 'r').read())
+func (Tf *TxFetcher) loadCache(filename string, net *NetworkParams) error {
+	raw, err := ioutil.ReadFile(filename)
+	if err != nil {
+		return err
+	}
+	var diskCache map[string]string
+	if err := json.Unmarshal(raw, &diskCache); err != nil {
+		return err
+	}
 	for k, rawHex := range diskCache {
-		raw := []byte(rawHex)
-		if raw[4] == 0 {
-			raw = raw[:4] + raw[6:]
-			tx := new(Tx)
-			tx = tx.parse(raw)
-			tx.locktime = littleEndianToInt(raw[-4:])
-		} else {
-			tx := new(Tx)
-			tx = tx.parse(raw)
+		b, err := hex.DecodeString(rawHex)
+		if err != nil {
+			return err
+		}
+		tx, err := new(Tx).parse(bytes.NewReader(b), net)
+		if err != nil {
+			return err
 		}
-		tx := new(Tx)
-		cls.cache[k] = tx
+		Tf.cache[k] = tx
 	}
+	return nil
 }
 
 type Tx struct {
@@ -154,39 +106,41 @@ type Tx struct {
 	txIns    []*TxIn
 	txOuts   []*TxOut
 	locktime int64
-	testnet  bool
+	net      *NetworkParams
+	segwit   bool
 }
 
-func NewTx(version int64, txIns []*TxIn, txOuts []*TxOut, locktime int64, testnet bool) (T *Tx) {
+func NewTx(version int64, txIns []*TxIn, txOuts []*TxOut, locktime int64, net *NetworkParams) (T *Tx) {
 	T = new(Tx)
 	T.version = version
 	T.txIns = txIns
 	T.txOuts = txOuts
 	T.locktime = locktime
-	T.testnet = testnet
+	T.net = net
 	return
 }
 
-func (T *Tx) Repr() (string, string, string, string, string, int64) {
+func (T *Tx) Repr() string {
 	txIns := ""
 	for _, txIn := range T.txIns {
-		txIns += fmt.Sprintf("%#v", txIn) + "\n"
+		txIns += txIn.Repr() + "\n"
 	}
 	txOuts := ""
 	for _, txOut := range T.txOuts {
-		txOuts += fmt.Sprintf("%#v", txOut) + "\n"
+		txOuts += txOut.Repr() + "\n"
 	}
-	return "tx: %s\nversion: %s\ntxIns: %s\ntxOuts: %s\nlocktime: %d",
+	return fmt.Sprintf("tx: %s\nversion: %d\ntxIns:\n%stxOuts:\n%slocktime: %d",
 		T.id(),
-		string(T.version),
+		T.version,
 		txIns,
 		txOuts,
-		T.locktime
+		T.locktime)
 }
 
 func (T *Tx) id() string {
-	//Human-readable hexadecimal of the transaction hash"
-	return hex.EncodeToString([]byte(T.hash())) //coverts string to hex string
+	//Human-readable hexadecimal of the transaction hash, byte reversed
+	//like block explorers show it
+	return hex.EncodeToString([]byte(reverse(T.hash())))
 }
 
 func (T *Tx) hash() string {
@@ -194,31 +148,92 @@ func (T *Tx) hash() string {
 	return hash256(string(T.serialize()))
 }
 
-func (T *Tx) parse(s []byte, testnet bool) *Tx {
-	var byt bytes.Buffer
-	byt.Write(s)
-	x, _ := byt.ReadBytes(4)
+//parse reads a transaction, a segwit one has a 0 marker and 1 flag after
+//the version and the witness items after the outputs
+func (T *Tx) parse(s *bytes.Reader, net *NetworkParams) (*Tx, error) {
+	x, err := readBytes(s, 4)
+	if err != nil {
+		return nil, err
+	}
 	version := littleEndianToInt(x)
-	numInputs := readVarint(s)
+	segwit := false
+	if marker, err := s.ReadByte(); err == nil && marker == 0 {
+		flag, err := s.ReadByte()
+		if err != nil || flag != 1 {
+			return nil, fmt.Errorf("SyntaxError: %v", "bad segwit flag")
+		}
+		segwit = true
+	} else if err == nil {
+		s.UnreadByte()
+	}
+	numInputs, err := readVarint(s)
+	if err != nil {
+		return nil, err
+	}
+	//every input is at least 41 bytes
+	if numInputs > uint64(s.Len()/41) {
+		return nil, fmt.Errorf("SyntaxError: %v", "too many inputs")
+	}
 	var inputs []*TxIn
-	for i := 0; i < int(numInputs); i++ {
-		Ti := new(TxIn)
-		inputs = append(inputs, Ti.parse(s))
+	for i := uint64(0); i < numInputs; i++ {
+		txIn, err := new(TxIn).parse(s)
+		if err != nil {
+			return nil, err
+		}
+		inputs = append(inputs, txIn)
+	}
+	numOutputs, err := readVarint(s)
+	if err != nil {
+		return nil, err
+	}
+	//every output is at least 9 bytes
+	if numOutputs > uint64(s.Len()/9) {
+		return nil, fmt.Errorf("SyntaxError: %v", "too many outputs")
 	}
-	numOutputs := readVarint(s)
 	var outputs []*TxOut
-	for i := 0; i < int(numOutputs); i++ {
-		To := new(TxOut)
-		outputs = append(outputs, To.parse(s))
+	for i := uint64(0); i < numOutputs; i++ {
+		txOut, err := new(TxOut).parse(s)
+		if err != nil {
+			return nil, err
+		}
+		outputs = append(outputs, txOut)
+	}
+	if segwit {
+		for _, txIn := range inputs {
+			numItems, err := readVarint(s)
+			if err != nil {
+				return nil, err
+			}
+			if numItems > uint64(s.Len()) {
+				return nil, fmt.Errorf("SyntaxError: %v", "too many witness items")
+			}
+			txIn.witness = make([][]byte, 0, numItems)
+			for j := uint64(0); j < numItems; j++ {
+				length, err := readVarint(s)
+				if err != nil {
+					return nil, err
+				}
+				item, err := readBytes(s, length)
+				if err != nil {
+					return nil, err
+				}
+				txIn.witness = append(txIn.witness, item)
+			}
+		}
+	}
+	y, err := readBytes(s, 4)
+	if err != nil {
+		return nil, err
 	}
-	testnet = false
-	y, _ := byt.ReadBytes(4)
 	locktime := littleEndianToInt(y)
-	return NewTx(version, inputs, outputs, locktime, testnet)
+	tx := NewTx(version, inputs, outputs, locktime, net)
+	tx.segwit = segwit
+	return tx, nil
 }
 
 func (T *Tx) serialize() []byte {
-	//"Returns the byte serialization of the transaction"
+	//"Returns the legacy byte serialization of the transaction, the witness
+	//is left out so this is what the txid hashes"
 	result := intToLittleEndian(int(T.version), 4)
 	result = append(result, encodeVarint(len(T.txIns))...)
 	for _, txIn := range T.txIns {
@@ -232,19 +247,53 @@ func (T *Tx) serialize() []byte {
 	return result
 }
 
-func (T *Tx) fee(testnet bool) int {
-	testnet = false
+func (T *Tx) fee() (int, error) {
 	inputSum, outputSum := 0, 0
 	for _, txIn := range T.txIns {
-		inputSum += txIn.value(testnet)
+		value, err := txIn.value(T.net)
+		if err != nil {
+			return 0, err
+		}
+		inputSum += value
 	}
 	for _, txOut := range T.txOuts {
 		outputSum += int(txOut.amount)
 	}
-	return inputSum - outputSum
+	return inputSum - outputSum, nil
+}
+
+//p2pkhScriptSigSize is the most bytes a signed p2pkh ScriptSig takes:
+//a push of the signature with its sighash byte and a push of the compressed sec
+func p2pkhScriptSigSize(grindR bool) int {
+	if grindR {
+		return 1 + MAXSIGSIZELOWR + 1 + 33
+	}
+	return 1 + MAXSIGSIZE + 1 + 33
+}
+
+//estimateSize is an upper bound on the serialized size of the transaction
+//once every input is signed as p2pkh with a compressed key
+func (T *Tx) estimateSize(grindR bool) int {
+	scriptSigSize := p2pkhScriptSigSize(grindR)
+	size := 4 + len(encodeVarint(len(T.txIns)))
+	for range T.txIns {
+		//prev tx, prev index, ScriptSig length, ScriptSig and sequence
+		size += 32 + 4 + len(encodeVarint(scriptSigSize)) + scriptSigSize + 4
+	}
+	size += len(encodeVarint(len(T.txOuts)))
+	for _, txOut := range T.txOuts {
+		size += len(txOut.serialize())
+	}
+	//locktime
+	return size + 4
 }
 
-func (T *Tx) sigHash(inputIndex int, redeemScript *Script) int {
+//estimateFee is the fee in satoshis to pay feeRate satoshis per byte
+func (T *Tx) estimateFee(feeRate int, grindR bool) int {
+	return T.estimateSize(grindR) * feeRate
+}
+
+func (T *Tx) sigHash(inputIndex int, redeemScript *Script) (*big.Int, error) {
 	//"Returns the integer representation of the hash that needs to get
 	//signed for index input_index"
 
@@ -255,20 +304,22 @@ func (T *Tx) sigHash(inputIndex int, redeemScript *Script) int {
 	s = append(s, encodeVarint(len(T.txIns))...)
 	//loop through each input using enumerate, so we have the input index
 	for i, tx_in := range T.txIns {
-		scriptSig := new(Script)
+		var scriptSig *Script
 		//if the input index is the one we're signing
-		if reflect.DeepEqual(i, inputIndex) {
+		if i == inputIndex {
 			if redeemScript != nil {
 				//the RedeemScript was passed in, that's the ScriptSig
-				//otherwise the previous tx's ScriptPubkey is the ScriptSig
 				scriptSig = redeemScript
-				//Otherwise, the ScriptSig is empty
 			} else {
-				scriptSig = tx_in.scriptPubkey(T.testnet)
+				//otherwise the previous tx's ScriptPubkey is the ScriptSig
+				scriptPubkey, err := tx_in.scriptPubkey(T.net)
+				if err != nil {
+					return nil, err
+				}
+				scriptSig = scriptPubkey
 			}
-		} else {
-			scriptSig = nil
 		}
+		//Otherwise, the ScriptSig is empty
 		//add the serialization of the input with the ScriptSig we want
 		s = append(s, NewTxIn(tx_in.prevTx, tx_in.prevIndex, scriptSig, tx_in.sequence).serialize()...)
 	}
@@ -285,8 +336,72 @@ func (T *Tx) sigHash(inputIndex int, redeemScript *Script) int {
 	// /hash256 the serialization
 	h256 := hash256(string(s))
 	//bytes to int
-	h := binary.BigEndian.Uint32([]byte(h256))
-	return int(h)
+	return new(big.Int).SetBytes([]byte(h256)), nil
+}
+
+//sigHashBip143 is the segwit v0 SIGHASH_ALL hash of input inputIndex.
+//scriptCode is the p2pkh script for p2wpkh or the WitnessScript for p2wsh
+//and amount is what the output being spent holds.
+func (T *Tx) sigHashBip143(inputIndex int, scriptCode []byte, amount int64) *big.Int {
+	var prevouts, sequences, outputs []byte
+	for _, txIn := range T.txIns {
+		prevouts = append(prevouts, txIn.prevTx...)
+		prevouts = append(prevouts, intToLittleEndian(int(txIn.prevIndex), 4)...)
+		sequences = append(sequences, intToLittleEndian(int(txIn.sequence), 4)...)
+	}
+	for _, txOut := range T.txOuts {
+		outputs = append(outputs, txOut.serialize()...)
+	}
+	txIn := T.txIns[inputIndex]
+	s := intToLittleEndian(int(T.version), 4)
+	s = append(s, hash256(string(prevouts))...)
+	s = append(s, hash256(string(sequences))...)
+	s = append(s, txIn.prevTx...)
+	s = append(s, intToLittleEndian(int(txIn.prevIndex), 4)...)
+	s = append(s, varstr(scriptCode)...)
+	s = append(s, intToLittleEndian(int(amount), 8)...)
+	s = append(s, intToLittleEndian(int(txIn.sequence), 4)...)
+	s = append(s, hash256(string(outputs))...)
+	s = append(s, intToLittleEndian(int(T.locktime), 4)...)
+	s = append(s, intToLittleEndian(SIGHASHALL, 4)...)
+	return new(big.Int).SetBytes([]byte(hash256(string(s))))
+}
+
+//sigHashBip341 is the taproot key path hash of input inputIndex for
+//SIGHASH_DEFAULT (0) or SIGHASH_ALL (1). The amount and ScriptPubKey of
+//every input go into it.
+func (T *Tx) sigHashBip341(inputIndex int, amounts []int64, scriptPubkeys [][]byte, hashType byte) ([]byte, error) {
+	if hashType > byte(SIGHASHALL) {
+		return nil, fmt.Errorf("ValueError: sighash type %d is not supported", hashType)
+	}
+	if len(amounts) != len(T.txIns) || len(scriptPubkeys) != len(T.txIns) {
+		return nil, fmt.Errorf("ValueError: %v", "need the amount and ScriptPubKey of every input")
+	}
+	var prevouts, spentAmounts, spentScripts, sequences, outputs []byte
+	for i, txIn := range T.txIns {
+		prevouts = append(prevouts, txIn.prevTx...)
+		prevouts = append(prevouts, intToLittleEndian(int(txIn.prevIndex), 4)...)
+		spentAmounts = append(spentAmounts, intToLittleEndian(int(amounts[i]), 8)...)
+		spentScripts = append(spentScripts, varstr(scriptPubkeys[i])...)
+		sequences = append(sequences, intToLittleEndian(int(txIn.sequence), 4)...)
+	}
+	for _, txOut := range T.txOuts {
+		outputs = append(outputs, txOut.serialize()...)
+	}
+	//epoch 0, then the hash type
+	msg := []byte{0x00, hashType}
+	msg = append(msg, intToLittleEndian(int(T.version), 4)...)
+	msg = append(msg, intToLittleEndian(int(T.locktime), 4)...)
+	//the BIP341 hashes are single sha256
+	for _, b := range [][]byte{prevouts, spentAmounts, spentScripts, sequences, outputs} {
+		h := sha256.Sum256(b)
+		msg = append(msg, h[:]...)
+	}
+	//spend type 0 is the key path without an annex
+	msg = append(msg, 0x00)
+	msg = append(msg, intToLittleEndian(inputIndex, 4)...)
+	h := taggedHash("TapSighash", msg)
+	return h[:], nil
 }
 
 //check to see if the ScriptPubkey is a p2sh using
@@ -301,39 +416,51 @@ func (T *Tx) sigHash(inputIndex int, redeemScript *Script) int {
 func (T *Tx) verifyInput(inputIndex int) bool {
 	var redeemScript *Script
 	tx_in := T.txIns[inputIndex]
-	scriptPubkey := tx_in.scriptPubkey(T.testnet)
+	scriptPubkey, err := tx_in.scriptPubkey(T.net)
+	if err != nil {
+		return false
+	}
 	if scriptPubkey.isP2shScriptPubkey() {
-		//var cmd []byte
-		cmd := tx_in.scriptSig.cmds[len(tx_in.scriptSig.cmds)-1]
-		rawRedeem := append(encodeVarint(len(cmd.([]byte))), cmd.([]byte)...)
-		s := new(Script)
-		var byt bytes.Buffer
-		byt.Write(rawRedeem)
-		redeemScript = s.parse(rawRedeem)
-	} else {
-		redeemScript = nil
+		if len(tx_in.scriptSig.cmds) == 0 {
+			return false
+		}
+		cmd, ok := tx_in.scriptSig.cmds[len(tx_in.scriptSig.cmds)-1].([]byte)
+		if !ok {
+			return false
+		}
+		rawRedeem := append(encodeVarint(len(cmd)), cmd...)
+		redeemScript, err = new(Script).parse(bytes.NewReader(rawRedeem))
+		if err != nil {
+			return false
+		}
 	}
-	z := T.sigHash(inputIndex, redeemScript)
-	combined := tx_in.scriptSig + scriptPubkey
-	return combined.evaluate(z)
+	z, err := T.sigHash(inputIndex, redeemScript)
+	if err != nil {
+		return false
+	}
+	combined := tx_in.scriptSig.add(scriptPubkey)
+	lock := &LockContext{locktime: T.locktime, sequence: tx_in.sequence, version: T.version}
+	return combined.evaluateWith(nil, z, lock)
 }
 
 func (T *Tx) signInput(inputIndex int, privateKey *PrivateKey) bool {
 	//Signs the input using the private key
 	//get the signature hash (z)
 	var redeemScript *Script
-	z := T.sigHash(inputIndex, redeemScript)
-	//get der signature of z from private key
-	der := privateKey.sign(int64(z)).der()
+	z, err := T.sigHash(inputIndex, redeemScript)
+	if err != nil {
+		return false
+	}
+	//get der signature of z from private key, ground to low r so it
+	//is never bigger than what estimateSize assumed
+	der := privateKey.sign(z, true).der()
 	//append the SIGHASH_ALL to der (use SIGHASH_ALL.to_bytes(1, 'big'))
-	combined := make([]byte, 32)
-	binary.BigEndian.PutUint64(combined, uint64(SIGHASHALL)) //int to bytes
-	sig := der + string(combined)
+	sig := der + string([]byte{byte(SIGHASHALL)})
 	//calculate the sec
 	sec := privateKey.point.sec(true)
 	//initialize a new script with [sig, sec] as the cmds
 	//change input's script_sig to new script
-	T.txIns[inputIndex].scriptSig = NewScript([]interface{}{sig, sec})
+	T.txIns[inputIndex].scriptSig = NewScript([]interface{}{[]byte(sig), []byte(sec)})
 	//return whether sig is valid using self.verify_input
 	return T.verifyInput(inputIndex)
 }
@@ -341,7 +468,8 @@ func (T *Tx) signInput(inputIndex int, privateKey *PrivateKey) bool {
 func (T *Tx) verify() bool {
 	//Verify this transaction
 	//check that we're not creating money
-	if T.fee(false) < 0 {
+	fee, err := T.fee()
+	if err != nil || fee < 0 {
 		return false
 	}
 	//check that each input has a valid ScriptSig
@@ -358,12 +486,7 @@ func (T *Tx) isCoinbase() bool {
 		return false
 	}
 	first_input := T.txIns[0]
-	if !reflect.DeepEqual(first_input.prevTx, func(repeated []byte, n int) (result []byte) {
-		for i := 0; i < n; i++ {
-			result = append(result, repeated...)
-		}
-		return result
-	}([]byte("\u0000"), 32)) {
+	if !bytes.Equal(first_input.prevTx, make([]byte, 32)) {
 		return false
 	}
 	if first_input.prevIndex != 4294967295 {
@@ -373,11 +496,14 @@ func (T *Tx) isCoinbase() bool {
 }
 
 func (T *Tx) coinbaseHeight() int64 {
-	if !T.isCoinbase() {
+	if !T.isCoinbase() || len(T.txIns[0].scriptSig.cmds) == 0 {
+		return 0
+	}
+	element, ok := T.txIns[0].scriptSig.cmds[0].([]byte)
+	if !ok {
 		return 0
 	}
-	element := T.txIns[0].scriptSig.cmds[0]
-	return littleEndianToInt(element.([]byte))
+	return littleEndianToInt(element)
 }
 
 type TxIn struct {
@@ -385,16 +511,17 @@ type TxIn struct {
 	prevIndex int64
 	scriptSig *Script
 	sequence  int64
+	witness   [][]byte
 }
 
-func NewTxIn(prevTx []byte, prevIndex int64, scriptSig *Script, sequence int64) (TI *TxIn) {
-	scriptSig = nil
-	sequence = 4294967295
-	Ti := new(TxIn)
+//NewTxIn spends output prevIndex of prevTx, a nil scriptSig is an empty
+//one. The book's default sequence is 0xffffffff.
+func NewTxIn(prevTx []byte, prevIndex int64, scriptSig *Script, sequence int64) (Ti *TxIn) {
+	Ti = new(TxIn)
 	Ti.prevTx = prevTx
 	Ti.prevIndex = prevIndex
-	if &scriptSig == nil {
-		Ti.scriptSig = new(Script)
+	if scriptSig == nil {
+		Ti.scriptSig = NewScript(nil)
 	} else {
 		Ti.scriptSig = scriptSig
 	}
@@ -403,52 +530,70 @@ func NewTxIn(prevTx []byte, prevIndex int64, scriptSig *Script, sequence int64)
 }
 
 func (Ti *TxIn) Repr() string {
-	return fmt.Sprintf("%s:%d", hex.EncodeToString([]byte(Ti.prevTx)), Ti.prevIndex)
+	return fmt.Sprintf("%s:%d", hex.EncodeToString([]byte(reverse(string(Ti.prevTx)))), Ti.prevIndex)
 }
 
-func (Ti *TxIn) parse(s []byte) *TxIn {
+func (Ti *TxIn) parse(s *bytes.Reader) (*TxIn, error) {
 	//"Takes a byte stream and parses the tx_input at the start.
 	//Returns a TxIn object.
-	var byt bytes.Buffer
-	byt.Write(s)
-	S := new(Script)
-	prevTx, _ := byt.ReadBytes(32)
-	x, _ := byt.ReadBytes(4)
+	prevTx, err := readBytes(s, 32)
+	if err != nil {
+		return nil, err
+	}
+	x, err := readBytes(s, 4)
+	if err != nil {
+		return nil, err
+	}
 	prevIndex := littleEndianToInt(x)
-	scriptSig := S.parse(s)
-	z, _ := byt.ReadBytes(4)
+	scriptSig, err := new(Script).parse(s)
+	if err != nil {
+		return nil, err
+	}
+	z, err := readBytes(s, 4)
+	if err != nil {
+		return nil, err
+	}
 	sequence := littleEndianToInt(z)
-	return NewTxIn(prevTx, prevIndex, scriptSig, sequence)
+	return NewTxIn(prevTx, prevIndex, scriptSig, sequence), nil
 }
 
 func (s *TxIn) serialize() []byte {
 	//"Returns the byte serialization of the transaction input"
-	result := s.prevTx[:]
+	result := append([]byte{}, s.prevTx...)
 	result = append(result, intToLittleEndian(int(s.prevIndex), 4)...)
 	result = append(result, s.scriptSig.serialize()...)
 	result = append(result, intToLittleEndian(int(s.sequence), 4)...)
 	return result
 }
 
-func (Ti *TxIn) fetchTx(testnet bool) *Tx {
-	testnet = false
-	txf := new(TxFetcher)
-	intVar, _ := strconv.Atoi(hex.EncodeToString(Ti.prevTx))
-	return txf.fetch(intVar, testnet, false)
+func (Ti *TxIn) fetchTx(net *NetworkParams) (*Tx, error) {
+	//explorers want the byte reversed hex of the hash
+	txId := hex.EncodeToString([]byte(reverse(string(Ti.prevTx))))
+	return txFetcher.fetch(txId, net, false)
 }
 
-func (Ti *TxIn) value(testnet bool) int {
+func (Ti *TxIn) value(net *NetworkParams) (int, error) {
 	//"Get the output value by looking up the tx hash.Returns the amount in satoshi.
-	testnet = false
-	tx := Ti.fetchTx(false)
-	return int(tx.txOuts[Ti.prevIndex].amount)
+	tx, err := Ti.fetchTx(net)
+	if err != nil {
+		return 0, err
+	}
+	if Ti.prevIndex < 0 || Ti.prevIndex >= int64(len(tx.txOuts)) {
+		return 0, fmt.Errorf("ValueError: %s has no output %d", tx.id(), Ti.prevIndex)
+	}
+	return int(tx.txOuts[Ti.prevIndex].amount), nil
 }
 
-func (Ti *TxIn) scriptPubkey(testnet bool) *Script {
+func (Ti *TxIn) scriptPubkey(net *NetworkParams) (*Script, error) {
 	//"Get the ScriptPubKey by looking up the tx hash.Returns a Script object.
-	testnet = false
-	tx := Ti.fetchTx(false)
-	return tx.txOuts[Ti.prevIndex].scriptPubkey
+	tx, err := Ti.fetchTx(net)
+	if err != nil {
+		return nil, err
+	}
+	if Ti.prevIndex < 0 || Ti.prevIndex >= int64(len(tx.txOuts)) {
+		return nil, fmt.Errorf("ValueError: %s has no output %d", tx.id(), Ti.prevIndex)
+	}
+	return tx.txOuts[Ti.prevIndex].scriptPubkey, nil
 }
 
 type TxOut struct {
@@ -464,18 +609,21 @@ func NewTxOut(amount int64, scriptPubkey *Script) (To *TxOut) {
 }
 
 func (To *TxOut) Repr() string {
-	return fmt.Sprintf("%d:%s", To.amount, To.scriptPubkey)
+	return fmt.Sprintf("%d:%s", To.amount, To.scriptPubkey.Repr())
 }
 
-func (To *TxOut) parse(s []byte) *TxOut {
+func (To *TxOut) parse(s *bytes.Reader) (*TxOut, error) {
 	//"Takes a byte stream and parses the tx_output at the start.Returns a TxOut object.
-	var byt bytes.Buffer
-	byt.Write(s)
-	x, _ := byt.ReadBytes(8)
+	x, err := readBytes(s, 8)
+	if err != nil {
+		return nil, err
+	}
 	amount := littleEndianToInt(x)
-	S := new(Script)
-	scriptPubkey := S.parse(s)
-	return NewTxOut(amount, scriptPubkey)
+	scriptPubkey, err := new(Script).parse(s)
+	if err != nil {
+		return nil, err
+	}
+	return NewTxOut(amount, scriptPubkey), nil
 }
 
 func (To *TxOut) serialize() []byte {
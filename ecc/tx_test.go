@@ -0,0 +1,64 @@
+package ecc
+
+import (
+	"math/big"
+	"testing"
+)
+
+//spendP2PKH returns an unsigned tx spending a p2pkh output to privateKey,
+//the funding tx goes into txFetcher's cache so nothing is fetched
+func spendP2PKH(privateKey *PrivateKey, outputs int) *Tx {
+	h160 := []byte(privateKey.point.hash160(true))
+	coinbase := NewTxIn(make([]byte, 32), 0xffffffff, nil, 0xffffffff)
+	funding := NewTx(1, []*TxIn{coinbase}, []*TxOut{NewTxOut(100000, p2pkhScript(h160))}, 0, MAINNET)
+	txFetcher.cache[funding.id()] = funding
+	var txOuts []*TxOut
+	for i := 0; i < outputs; i++ {
+		txOuts = append(txOuts, NewTxOut(int64(90000/outputs), p2pkhScript(h160)))
+	}
+	txIn := NewTxIn([]byte(funding.hash()), 0, nil, 0xffffffff)
+	return NewTx(1, []*TxIn{txIn}, txOuts, 0, MAINNET)
+}
+
+func TestEstimateSizeGroundR(t *testing.T) {
+	for secret := int64(1); secret <= 20; secret++ {
+		privateKey := NewPrivateKey(big.NewInt(secret))
+		tx := spendP2PKH(privateKey, 1+int(secret%3))
+		if !tx.signInput(0, privateKey) {
+			t.Fatalf("secret %d: signInput failed", secret)
+		}
+		if size := len(tx.serialize()); size > tx.estimateSize(true) {
+			t.Errorf("secret %d: signed size %d > estimateSize(true) %d", secret, size, tx.estimateSize(true))
+		}
+		if fee := tx.estimateFee(10, true); fee != 10*tx.estimateSize(true) {
+			t.Errorf("secret %d: estimateFee(10, true) = %d", secret, fee)
+		}
+	}
+}
+
+//TestEstimateSizeHighR signs without grinding and only keeps the keys whose
+//signature has a high R, those need the bigger estimate
+func TestEstimateSizeHighR(t *testing.T) {
+	found := 0
+	for secret := int64(1); found < 5; secret++ {
+		privateKey := NewPrivateKey(big.NewInt(secret))
+		tx := spendP2PKH(privateKey, 2)
+		z, err := tx.sigHash(0, nil)
+		if err != nil {
+			t.Fatal(err)
+		}
+		sig := privateKey.sign(z, false)
+		if sig.hasLowR() {
+			continue
+		}
+		found++
+		der := append([]byte(sig.der()), byte(SIGHASHALL))
+		tx.txIns[0].scriptSig = NewScript([]interface{}{der, []byte(privateKey.point.sec(true))})
+		if !tx.verifyInput(0) {
+			t.Fatalf("secret %d: high R signature does not verify", secret)
+		}
+		if size := len(tx.serialize()); size > tx.estimateSize(false) {
+			t.Errorf("secret %d: signed size %d > estimateSize(false) %d", secret, size, tx.estimateSize(false))
+		}
+	}
+}
@@ -0,0 +1,56 @@
+package ecc
+
+import (
+	"math/big"
+	"testing"
+)
+
+//naiveVerify is the book's verify, u*G + v*P with the double-and-add
+//Point.Rmul on big.Int field elements. It is the baseline for the
+//precomputed tables and the interleaved wNAF.
+func naiveVerify(point *S256Point, z *big.Int, sig *Signature) bool {
+	sInv := new(big.Int).Exp(sig.s, new(big.Int).Sub(N, big.NewInt(2)), N)
+	u := mod(new(big.Int).Mul(z, sInv), N)
+	v := mod(new(big.Int).Mul(sig.r, sInv), N)
+	total := G.Point.Rmul(u).Add(point.Point.Rmul(v))
+	return !total.isInfinity() && total.x.num.Cmp(sig.r) == 0
+}
+
+func verifyFixture(tb testing.TB) (*S256Point, *big.Int, *Signature) {
+	privateKey := NewPrivateKey(randomScalar(tb))
+	z := randomScalar(tb)
+	return privateKey.point, z, privateKey.sign(z, false)
+}
+
+func TestVerifyMatchesNaive(t *testing.T) {
+	for i := 0; i < 20; i++ {
+		point, z, sig := verifyFixture(t)
+		if !point.verify(z, sig) || !naiveVerify(point, z, sig) {
+			t.Fatalf("valid signature rejected for z %x", z)
+		}
+		z.Add(z, big.NewInt(1))
+		if point.verify(z, sig) || naiveVerify(point, z, sig) {
+			t.Fatalf("signature accepted for the wrong z %x", z)
+		}
+	}
+}
+
+func BenchmarkVerify(b *testing.B) {
+	point, z, sig := verifyFixture(b)
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		if !point.verify(z, sig) {
+			b.Fatal("signature rejected")
+		}
+	}
+}
+
+func BenchmarkVerifyNaive(b *testing.B) {
+	point, z, sig := verifyFixture(b)
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		if !naiveVerify(point, z, sig) {
+			b.Fatal("signature rejected")
+		}
+	}
+}
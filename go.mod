@@ -5,4 +5,5 @@ go 1.16
 require (
 	github.com/btcsuite/btcutil v1.0.2
 	golang.org/x/crypto v0.0.0-20211117183948-ae814b36b871
+	golang.org/x/text v0.3.7
 )